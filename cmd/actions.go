@@ -9,6 +9,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path"
 	"regexp"
 	"sort"
 	"strconv"
@@ -643,9 +644,9 @@ func parseLogFileName(filename string) (jobID int64, stepNum int) {
 	// Log files are typically named like "0_jobname.txt", "1_stepname.txt", etc.
 	// or "jobname/system.txt" for system logs
 
-	// Handle numbered files like "0_jobname.txt", "1_stepname.txt"
-	if strings.Contains(filename, "_") {
-		parts := strings.Split(strings.TrimSuffix(filename, ".txt"), "_")
+	// Handle numbered files like "0_jobname.txt", "1_stepname.txt" or "jobname/1_stepname.txt"
+	if base := path.Base(filename); strings.Contains(base, "_") {
+		parts := strings.Split(strings.TrimSuffix(base, ".txt"), "_")
 		if len(parts) >= 1 {
 			if num, err := strconv.Atoi(parts[0]); err == nil {
 				stepNum = num
@@ -676,6 +677,15 @@ func correlateLogsWithJobs(logFiles []LogFile, jobs []gh.Job) []LogFile {
 		// Try to match by filename patterns
 		filename := strings.ToLower(logFile.Name)
 
+		// Files in a directory like "jobname/1_stepname.txt" belong to the job of the directory
+		if dir, _, ok := strings.Cut(filename, "/"); ok {
+			if job, exists := jobNameMap[dir]; exists {
+				logFile.JobID = job.ID
+				logFile.JobName = job.Name
+				continue
+			}
+		}
+
 		// Check for direct job name matches in filename or path
 		for jobName, job := range jobNameMap {
 			if strings.Contains(filename, jobName) {
@@ -0,0 +1,303 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/container"
+	"github.com/Leapfrog-DevOps/gha/pkg/runner"
+)
+
+func createDebugCommand(ctx context.Context, input *Input, rootCmd *cobra.Command) *cobra.Command {
+	debugCmd := &cobra.Command{
+		Use:   "debug [event name to run] [flags]",
+		Short: "Run the workflows and pause before every step and after a failed step",
+		Long: `Run the workflows like gha, pausing before every step and after a failed step. While the run is
+paused, open a shell in the job container, inspect the contexts of the step, edit the script of the
+step, run the failed step again or continue.
+
+Examples:
+  gha debug
+  gha debug pull_request -j test`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			input.debug = true
+			return newRunCommand(ctx, input)(cmd, args)
+		},
+		SilenceUsage: true,
+	}
+	// the run flags of gha are the flags of the debug command
+	debugCmd.Flags().AddFlagSet(rootCmd.Flags())
+	return debugCmd
+}
+
+// newDebugger pauses the run at the breakpoints on the terminal, one step at a time. It is nil when the
+// terminal isn't interactive.
+func newDebugger(in *os.File, out io.Writer) runner.Debugger {
+	if !term.IsTerminal(int(in.Fd())) {
+		return nil
+	}
+	var mu sync.Mutex
+	input := &terminalInput{in: in, results: make(chan terminalRead, 1)}
+	return func(ctx context.Context, breakpoint *runner.Breakpoint) (runner.DebugAction, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		d := &debugSession{input: input, fd: int(in.Fd()), out: out, breakpoint: breakpoint}
+		return d.run(ctx)
+	}
+}
+
+// terminalRead is the result of a read of the terminal
+type terminalRead struct {
+	b   []byte
+	err error
+}
+
+// terminalInput reads the input of the terminal for the debugger. A read of the terminal only starts when
+// the debugger reads, and a read that is still running when the shell exited is handed to the next read
+// of the debugger: no input is lost and no read competes with the editor for the input.
+type terminalInput struct {
+	in      io.Reader
+	mu      sync.Mutex
+	reading bool
+	results chan terminalRead
+	pending []byte
+}
+
+// read reads the input into p until done is closed
+func (t *terminalInput) read(done <-chan struct{}, p []byte) (int, error) {
+	t.mu.Lock()
+	if len(t.pending) > 0 {
+		n := copy(p, t.pending)
+		t.pending = t.pending[n:]
+		t.mu.Unlock()
+		return n, nil
+	}
+	if !t.reading {
+		t.reading = true
+		go func() {
+			b := make([]byte, 1024)
+			n, err := t.in.Read(b)
+			t.results <- terminalRead{b: b[:n], err: err}
+		}()
+	}
+	t.mu.Unlock()
+
+	select {
+	case r := <-t.results:
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.reading = false
+		n := copy(p, r.b)
+		t.pending = r.b[n:]
+		if n == 0 && r.err != nil {
+			return 0, r.err
+		}
+		return n, nil
+	case <-done:
+		return 0, io.EOF
+	}
+}
+
+// readLine reads a line of the input, without its line ending
+func (t *terminalInput) readLine(ctx context.Context) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := t.read(ctx.Done(), b); err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			return "", err
+		}
+		if b[0] == '\n' {
+			return strings.TrimSuffix(string(line), "\r"), nil
+		}
+		line = append(line, b[0])
+	}
+}
+
+// terminalSession is the input of a shell, it ends when the shell exits
+type terminalSession struct {
+	input *terminalInput
+	done  chan struct{}
+	once  sync.Once
+}
+
+func (s *terminalSession) Read(p []byte) (int, error) {
+	select {
+	case <-s.done:
+		return 0, io.EOF
+	default:
+	}
+	return s.input.read(s.done, p)
+}
+
+func (s *terminalSession) Close() {
+	s.once.Do(func() {
+		close(s.done)
+	})
+}
+
+// debugSession is the menu of the debugger at a breakpoint
+type debugSession struct {
+	input      *terminalInput
+	fd         int
+	out        io.Writer
+	breakpoint *runner.Breakpoint
+}
+
+func (d *debugSession) printf(format string, a ...interface{}) {
+	fmt.Fprintf(d.out, format, a...)
+}
+
+func (d *debugSession) help() {
+	if d.breakpoint.Err != nil {
+		d.printf("  c, continue       continue with the failure of the step\n")
+		d.printf("  r, rerun          run the step again\n")
+	} else {
+		d.printf("  c, continue       run the step\n")
+	}
+	d.printf("  s, shell          open a shell in the job container\n")
+	d.printf("  env               show the env of the step\n")
+	d.printf("  steps, needs      show the steps or needs context\n")
+	d.printf("  = <expression>    evaluate an expression, e.g. = toJSON(matrix)\n")
+	if _, ok := d.breakpoint.Script(); ok {
+		d.printf("  script            show the script of the step\n")
+		d.printf("  edit              edit the script of the step\n")
+	}
+	d.printf("  abort             fail the step\n")
+}
+
+func (d *debugSession) run(ctx context.Context) (runner.DebugAction, error) {
+	b := d.breakpoint
+	if b.Err != nil {
+		d.printf("\n⏸  Paused after the step '%s' (%s) of the job '%s' failed: %v\n", b.Step, b.StepID, b.Job, b.Err)
+	} else {
+		d.printf("\n⏸  Paused before the step '%s' (%s) of the job '%s'\n", b.Step, b.StepID, b.Job)
+	}
+	d.help()
+	for {
+		d.printf("(gha debug) ")
+		line, err := d.input.readLine(ctx)
+		if err != nil {
+			return runner.DebugContinue, err
+		}
+		command, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch command {
+		case "c", "continue":
+			return runner.DebugContinue, nil
+		case "r", "rerun":
+			if b.Err != nil {
+				return runner.DebugRerun, nil
+			}
+			d.printf("The step didn't run yet, continue to run it\n")
+		case "s", "shell":
+			d.shell(ctx)
+		case "env":
+			env := b.Env()
+			for _, k := range slices.Sorted(maps.Keys(env)) {
+				d.printf("%s=%s\n", k, env[k])
+			}
+		case "steps", "needs":
+			d.evaluate(ctx, fmt.Sprintf("toJSON(%s)", command))
+		case "=":
+			d.evaluate(ctx, arg)
+		case "script":
+			script, _ := b.Script()
+			d.printf("%s\n", script)
+		case "edit":
+			d.edit()
+		case "abort":
+			return runner.DebugContinue, fmt.Errorf("the step '%s' was aborted in the debugger", b.Step)
+		case "":
+		default:
+			d.help()
+		}
+	}
+}
+
+func (d *debugSession) evaluate(ctx context.Context, expression string) {
+	value, err := d.breakpoint.Evaluate(ctx, expression)
+	if err != nil {
+		d.printf("%v\n", err)
+		return
+	}
+	d.printf("%s\n", value)
+}
+
+func (d *debugSession) shell(ctx context.Context) {
+	session := &terminalSession{input: d.input, done: make(chan struct{})}
+	defer session.Close()
+	d.printf("Exit the shell to return to the debugger\n")
+	err := d.breakpoint.Shell(ctx, &container.Terminal{
+		In:  session,
+		Out: d.out,
+		Fd:  d.fd,
+	})
+	if err != nil {
+		d.printf("The shell failed: %v\n", err)
+	}
+}
+
+// edit edits the script of the step with $VISUAL or $EDITOR
+func (d *debugSession) edit() {
+	script, ok := d.breakpoint.Script()
+	if !ok {
+		d.printf("The step uses an action and has no script\n")
+		return
+	}
+	f, err := os.CreateTemp("", "gha-step-*.sh")
+	if err != nil {
+		d.printf("%v\n", err)
+		return
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(script)
+	f.Close()
+	if err != nil {
+		d.printf("%v\n", err)
+		return
+	}
+
+	editor := strings.Fields(editorCommand())
+	cmd := exec.Command(editor[0], append(editor[1:], f.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		d.printf("The editor failed: %v\n", err)
+		return
+	}
+	edited, err := os.ReadFile(f.Name())
+	if err != nil {
+		d.printf("%v\n", err)
+		return
+	}
+	if err := d.breakpoint.SetScript(string(edited)); err != nil {
+		d.printf("%v\n", err)
+		return
+	}
+	d.printf("The edited script runs when the step runs\n")
+}
+
+func editorCommand() string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.TrimSpace(os.Getenv(name)); editor != "" {
+			return editor
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vi"
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTerminalInput(t *testing.T) {
+	r, w := io.Pipe()
+	input := &terminalInput{in: r, results: make(chan terminalRead, 1)}
+
+	// the read of the shell is still running when the shell exits
+	session := &terminalSession{input: input, done: make(chan struct{})}
+	read := make(chan error)
+	go func() {
+		_, err := session.Read(make([]byte, 32))
+		read <- err
+	}()
+	require.Eventually(t, func() bool {
+		input.mu.Lock()
+		defer input.mu.Unlock()
+		return input.reading
+	}, time.Second, time.Millisecond)
+	session.Close()
+	assert.Equal(t, io.EOF, <-read)
+	_, err := session.Read(make([]byte, 32))
+	assert.Equal(t, io.EOF, err)
+
+	// the input of the running read goes to the menu
+	go func() {
+		_, _ = w.Write([]byte("= toJSON(needs)\r\nc\n"))
+	}()
+	line, err := input.readLine(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "= toJSON(needs)", line)
+	line, err = input.readLine(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "c", line)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = input.readLine(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -10,6 +10,7 @@ import (
 var (
 	UserHomeDir  string
 	CacheHomeDir string
+	StateHomeDir string
 )
 
 func init() {
@@ -24,4 +25,10 @@ func init() {
 	} else {
 		CacheHomeDir = filepath.Join(UserHomeDir, ".cache")
 	}
+
+	if v := os.Getenv("XDG_STATE_HOME"); v != "" {
+		StateHomeDir = v
+	} else {
+		StateHomeDir = filepath.Join(UserHomeDir, ".local", "state")
+	}
 }
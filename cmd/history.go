@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/gh"
+	"github.com/Leapfrog-DevOps/gha/pkg/history"
+)
+
+// historyLimit is the number of runs kept in the history
+const historyLimit = 50
+
+func createHistoryCommand(input *Input) *cobra.Command {
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "View the local runs of gha",
+		Long: `View the results and logs of the previous local runs, recorded in the history directory.
+
+Examples:
+  gha history
+  gha history show 3
+  gha history logs 3 -j build --step test`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			limit, _ := cmd.Flags().GetInt("limit")
+			return listHistory(cmd.OutOrStdout(), historyStore(input), limit)
+		},
+	}
+	hideGlobalFlags(historyCmd)
+	historyCmd.Flags().IntP("limit", "l", 10, "Limit number of runs to display")
+
+	showCmd := &cobra.Command{
+		Use:   "show <number>",
+		Short: "Show the jobs and steps of a local run",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			run, err := getHistoryRun(historyStore(input), args[0])
+			if err != nil {
+				return err
+			}
+			showHistoryRun(cmd.OutOrStdout(), run)
+			return nil
+		},
+	}
+
+	logsCmd := &cobra.Command{
+		Use:   "logs <number>",
+		Short: "Show the step logs of a local run",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jobFilter, _ := cmd.Flags().GetString("job")
+			stepFilter, _ := cmd.Flags().GetString("step")
+			rawOutput, _ := cmd.Flags().GetBool("raw")
+			showTimestamps, _ := cmd.Flags().GetBool("timestamps")
+			return showHistoryLogs(historyStore(input), args[0], jobFilter, stepFilter, rawOutput, showTimestamps)
+		},
+	}
+	logsCmd.Flags().StringP("job", "j", "", "Show logs for specific job ID, name or index only")
+	logsCmd.Flags().String("step", "", "Show logs for specific step name only")
+	logsCmd.Flags().BoolP("raw", "r", false, "Show raw logs without formatting")
+	logsCmd.Flags().BoolP("timestamps", "t", true, "Show timestamps (default: true)")
+
+	historyCmd.AddCommand(showCmd, logsCmd)
+	return historyCmd
+}
+
+func historyStore(input *Input) *history.Store {
+	return &history.Store{Dir: input.historyDir, Limit: historyLimit}
+}
+
+func getHistoryRun(store *history.Store, arg string) (*history.Run, error) {
+	number, err := strconv.Atoi(strings.TrimPrefix(arg, "#"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid run number '%s'", arg)
+	}
+	run, err := store.Get(number)
+	if err != nil {
+		return nil, fmt.Errorf("run %d not found in the history: %w", number, err)
+	}
+	return run, nil
+}
+
+func listHistory(out io.Writer, store *history.Store, limit int) error {
+	runs, err := store.List()
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Fprintf(out, "No runs recorded in %s\n", store.Dir)
+		return nil
+	}
+
+	fmt.Fprintf(out, "\n Local runs\n\n")
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "#\tEVENT\tWORKFLOWS\tSTATUS\tCONCLUSION\tJOBS\tSTARTED\tDURATION\n")
+	shown := runs
+	if limit > 0 && len(shown) > limit {
+		shown = shown[:limit]
+	}
+	for _, run := range shown {
+		conclusion := run.Conclusion
+		if conclusion == "" {
+			conclusion = "-"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s %s\t%s %s\t%d\t%s\t%s\n",
+			run.Number,
+			run.Event,
+			strings.Join(run.Workflows, ", "),
+			getStatusIcon(run.Status, conclusion),
+			run.Status,
+			getConclusionIcon(conclusion),
+			conclusion,
+			len(run.Jobs),
+			formatTimeAgo(run.StartedAt),
+			calculateDuration(run.StartedAt, run.CompletedAt))
+	}
+	w.Flush()
+	fmt.Fprintf(out, "\n Showing %d of %d runs\n", len(shown), len(runs))
+	return nil
+}
+
+func showHistoryRun(out io.Writer, run *history.Run) {
+	fmt.Fprintf(out, "\n📄 Local Run #%d\n", run.Number)
+	fmt.Fprintf(out, "\n┌─ Run Information\n")
+	fmt.Fprintf(out, "│ Event: %s\n", run.Event)
+	fmt.Fprintf(out, "│ Workflows: %s\n", strings.Join(run.Workflows, ", "))
+	fmt.Fprintf(out, "│ Directory: %s\n", run.Workdir)
+	fmt.Fprintf(out, "│ Status: %s %s\n", getStatusIcon(run.Status, run.Conclusion), run.Status)
+	fmt.Fprintf(out, "│ Conclusion: %s %s\n", getConclusionIcon(run.Conclusion), run.Conclusion)
+	fmt.Fprintf(out, "│ Started: %s (%s)\n", run.StartedAt.Format("2006-01-02 15:04:05"), formatTimeAgo(run.StartedAt))
+	fmt.Fprintf(out, "│ Duration: %s\n", calculateDuration(run.StartedAt, run.CompletedAt))
+	plan := make([]string, 0, len(run.Plan))
+	for _, stage := range run.Plan {
+		plan = append(plan, strings.Join(stage, ", "))
+	}
+	fmt.Fprintf(out, "│ Plan: %s\n", strings.Join(plan, " → "))
+	fmt.Fprintf(out, "└─\n")
+
+	for i, job := range run.Jobs {
+		conclusion := job.Conclusion
+		if conclusion == "" {
+			conclusion = "-"
+		}
+		fmt.Fprintf(out, "\n┌─ Job %d: %s %s (%s)\n", i+1, getStatusIcon(job.Status, conclusion), job.Name, calculateDuration(job.StartedAt, job.CompletedAt))
+		if len(job.Steps) > 0 {
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "│ #\tSTEP\tCONCLUSION\tDURATION\n")
+			for _, step := range job.Steps {
+				fmt.Fprintf(w, "│ %d\t%s\t%s %s\t%s\n",
+					step.Number,
+					step.Name,
+					getConclusionIcon(step.Conclusion),
+					step.Conclusion,
+					calculateDuration(step.StartedAt, step.CompletedAt))
+			}
+			w.Flush()
+		}
+		if job.Environment != "" {
+			fmt.Fprintf(out, "│ \U0001F310 Environment: %s\n", strings.TrimSpace(job.Environment+" "+job.EnvironmentURL))
+		}
+		for _, step := range job.Steps {
+			if step.Substitute != "" {
+				fmt.Fprintf(out, "│ \U0001F3AD #%d: %s\n", step.Number, step.Substitute)
+			}
+		}
+		for _, step := range job.Steps {
+			for _, annotation := range step.Annotations {
+				fmt.Fprintf(out, "│ %s %s: %s\n", getAnnotationIcon(annotation.Level), annotation.Level, annotation)
+			}
+		}
+		for _, step := range job.Steps {
+			for _, name := range slices.Sorted(maps.Keys(step.Outputs)) {
+				fmt.Fprintf(out, "│ steps.%s.outputs.%s = %s\n", step.ID, name, step.Outputs[name])
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(job.Outputs)) {
+			fmt.Fprintf(out, "│ outputs.%s = %s\n", name, job.Outputs[name])
+		}
+		fmt.Fprintf(out, "└─\n")
+	}
+	fmt.Fprintln(out)
+}
+
+func getAnnotationIcon(level string) string {
+	switch level {
+	case "error":
+		return "❌"
+	case "warning":
+		return "⚠️"
+	default:
+		return "ℹ️"
+	}
+}
+
+func showHistoryLogs(store *history.Store, arg string, jobFilter string, stepFilter string, rawOutput bool, showTimestamps bool) error {
+	run, err := getHistoryRun(store, arg)
+	if err != nil {
+		return err
+	}
+	logsData, err := store.Logs(run.Number)
+	if err != nil {
+		return err
+	}
+
+	// the jobs of the run are numbered like the jobs of `gha history show`
+	jobs := make([]gh.Job, 0, len(run.Jobs))
+	for i, job := range run.Jobs {
+		jobs = append(jobs, gh.Job{
+			ID:          int64(i + 1),
+			Name:        job.LogDir(),
+			Status:      job.Status,
+			Conclusion:  job.Conclusion,
+			StartedAt:   job.StartedAt,
+			CompletedAt: job.CompletedAt,
+		})
+	}
+
+	var jobID int64
+	if jobFilter != "" {
+		jobID, err = resolveHistoryJob(run, jobFilter)
+		if err != nil {
+			return err
+		}
+	}
+
+	if jobID != 0 || !rawOutput {
+		jobInfo := ""
+		if jobID != 0 {
+			jobInfo = fmt.Sprintf(", Job %d (%s)", jobID, run.Jobs[jobID-1].Name)
+		}
+		fmt.Printf("Logs for run #%d%s:\n\n", run.Number, jobInfo)
+	}
+	if err := extractAndDisplayLogsImproved(logsData, jobs, jobID, stepFilter, rawOutput, showTimestamps); err != nil {
+		return fmt.Errorf("failed to extract logs: %w", err)
+	}
+	return nil
+}
+
+// resolveHistoryJob returns the number of the job with the index, the ID or the name. The ID of a
+// job with a matrix matches its first leg.
+func resolveHistoryJob(run *history.Run, filter string) (int64, error) {
+	if index, err := strconv.Atoi(filter); err == nil && index >= 1 && index <= len(run.Jobs) {
+		return int64(index), nil
+	}
+	for i, job := range run.Jobs {
+		if job.Name == filter || job.ID == filter {
+			return int64(i + 1), nil
+		}
+	}
+	return 0, fmt.Errorf("job '%s' not found in run #%d", filter, run.Number)
+}
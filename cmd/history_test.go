@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/history"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+func newTestHistoryRun() *history.Run {
+	started := time.Now().Add(-time.Hour)
+	return &history.Run{
+		Event:       "push",
+		Workdir:     "/home/octocat/repo",
+		Workflows:   []string{"CI"},
+		Plan:        [][]string{{"build"}, {"deploy"}},
+		Status:      history.StatusCompleted,
+		Conclusion:  "failure",
+		StartedAt:   started,
+		CompletedAt: started.Add(90 * time.Second),
+		Jobs: []*history.Job{
+			{
+				ID: "build", Name: "CI/build", Status: history.StatusCompleted, Conclusion: "failure",
+				StartedAt: started, CompletedAt: started.Add(80 * time.Second),
+				Steps: []*history.Step{
+					{Number: 1, ID: "version", Name: "Run ./version.sh", Conclusion: "success", StartedAt: started, CompletedAt: started.Add(2 * time.Second), Outputs: map[string]string{"version": "1.2.3"}},
+					{Number: 2, ID: "lint", Name: "Run golangci-lint", Conclusion: "failure", StartedAt: started, CompletedAt: started.Add(75 * time.Second),
+						Annotations: []model.Annotation{{Level: "error", Message: "unused variable", File: "main.go", Line: 10, Col: 5}}},
+				},
+			},
+			{ID: "deploy", Name: "CI/deploy", Status: history.StatusCompleted, Conclusion: "skipped", Steps: []*history.Step{}},
+		},
+	}
+}
+
+func TestListHistory(t *testing.T) {
+	store := &history.Store{Dir: t.TempDir()}
+	out := &bytes.Buffer{}
+	require.NoError(t, listHistory(out, store, 10))
+	assert.Contains(t, out.String(), "No runs recorded")
+
+	for i := 0; i < 3; i++ {
+		run := newTestHistoryRun()
+		require.NoError(t, store.Create(run))
+		require.NoError(t, store.Save(run))
+	}
+
+	out.Reset()
+	require.NoError(t, listHistory(out, store, 2))
+	assert.Contains(t, out.String(), "#  EVENT  WORKFLOWS  STATUS")
+	assert.Regexp(t, `3\s+push\s+CI\s+❌ completed\s+❌ failure\s+2\s+1h ago\s+1m 30s`, out.String())
+	assert.NotRegexp(t, `(?m)^1\s+push`, out.String())
+	assert.Contains(t, out.String(), "Showing 2 of 3 runs")
+}
+
+func TestShowHistoryRun(t *testing.T) {
+	run := newTestHistoryRun()
+	run.Number = 7
+	run.Jobs[0].Steps[0].Substitute = "octo/version@v1 substituted by the outputs of the mock 'octo/*'"
+	run.Jobs[0].Environment = "staging"
+	run.Jobs[0].EnvironmentURL = "https://staging.example.com"
+	out := &bytes.Buffer{}
+	showHistoryRun(out, run)
+
+	assert.Contains(t, out.String(), "📄 Local Run #7")
+	assert.Contains(t, out.String(), "│ Plan: build → deploy")
+	assert.Contains(t, out.String(), "┌─ Job 1: ❌ CI/build (1m 20s)")
+	assert.Regexp(t, `│ 2\s+Run golangci-lint\s+❌ failure\s+1m 15s`, out.String())
+	assert.Contains(t, out.String(), "│ ❌ error: main.go:10:5: unused variable")
+	assert.Contains(t, out.String(), "│ 🎭 #1: octo/version@v1 substituted by the outputs of the mock 'octo/*'")
+	assert.Contains(t, out.String(), "│ 🌐 Environment: staging https://staging.example.com")
+	assert.Contains(t, out.String(), "│ steps.version.outputs.version = 1.2.3")
+	assert.Contains(t, out.String(), "┌─ Job 2: ⏭️  CI/deploy (-)")
+}
+
+func TestResolveHistoryJob(t *testing.T) {
+	run := newTestHistoryRun()
+	for filter, expected := range map[string]int64{"2": 2, "build": 1, "CI/deploy": 2} {
+		id, err := resolveHistoryJob(run, filter)
+		require.NoError(t, err)
+		assert.Equal(t, expected, id, filter)
+	}
+	_, err := resolveHistoryJob(run, "test")
+	assert.Error(t, err)
+}
+
+func TestParseLogFileName(t *testing.T) {
+	_, stepNum := parseLogFileName("CI_build/2_Run tests.txt")
+	assert.Equal(t, 2, stepNum)
+	_, stepNum = parseLogFileName("build/system.txt")
+	assert.Equal(t, -1, stepNum)
+}
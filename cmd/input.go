@@ -2,8 +2,13 @@ package cmd
 
 import (
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/history"
+	"github.com/Leapfrog-DevOps/gha/pkg/runner"
 )
 
 // Input contains the input for the root command
@@ -66,6 +71,32 @@ type Input struct {
 	strict                             bool
 	concurrentJobs                     int
 	domain                             string
+	base                               string
+	environmentApprovals               []string
+	environmentWaitTimers              []string
+	reportDir                          string
+	historyDir                         string
+	noHistory                          bool
+	reports                            []string
+	traceFile                          string
+	events                             string
+	tui                                bool
+	jobStartedHook                     string
+	jobCompletedHook                   string
+	breakBefore                        []string
+	breakOnFailure                     bool
+	debug                              bool
+	resumeRun                          *history.Run
+	fromStep                           string
+	noNeeds                            bool
+	needsOutputs                       []string
+	needsResults                       []string
+	needsFile                          string
+	skipSteps                          []string
+	onlySteps                          []string
+	mockSteps                          []string
+	mocksFile                          string
+	noMocks                            bool
 }
 
 func (i *Input) resolve(path string) string {
@@ -111,6 +142,80 @@ func (i *Input) EventPath() string {
 	return i.resolve(i.eventPath)
 }
 
+// ReportDir returns the path to the directory of the run reports
+func (i *Input) ReportDir() string {
+	return i.resolve(i.reportDir)
+}
+
+// MocksFile returns the path to the file of the mocks of the actions
+func (i *Input) MocksFile() string {
+	return i.resolve(i.mocksFile)
+}
+
+// Events returns the file descriptor or the path of the file the events of the run are written to
+func (i *Input) Events() string {
+	if _, err := strconv.Atoi(i.events); err == nil {
+		return i.events
+	}
+	return i.resolve(i.events)
+}
+
+// JobStartedHook returns the path to the script run before the steps of the jobs, by default the
+// ACTIONS_RUNNER_HOOK_JOB_STARTED script of a self-hosted runner set with --env or in the env file
+func (i *Input) JobStartedHook(envs map[string]string) string {
+	return i.runnerHook(i.jobStartedHook, envs["ACTIONS_RUNNER_HOOK_JOB_STARTED"])
+}
+
+// JobCompletedHook returns the path to the script run after the steps of the jobs, by default the
+// ACTIONS_RUNNER_HOOK_JOB_COMPLETED script of a self-hosted runner set with --env or in the env file
+func (i *Input) JobCompletedHook(envs map[string]string) string {
+	return i.runnerHook(i.jobCompletedHook, envs["ACTIONS_RUNNER_HOOK_JOB_COMPLETED"])
+}
+
+// Debugging returns whether the run is paused at the breakpoints of the steps
+func (i *Input) Debugging() bool {
+	return i.debug || len(i.breakBefore) > 0 || i.breakOnFailure
+}
+
+// BreakBefore returns the IDs of the steps the run is paused before, every step with `gha debug`
+func (i *Input) BreakBefore() []string {
+	if i.debug {
+		return []string{runner.BreakAll}
+	}
+	return i.breakBefore
+}
+
+// BreakOnFailure returns whether the run is paused after a step failed
+func (i *Input) BreakOnFailure() bool {
+	return i.debug || i.breakOnFailure
+}
+
+// Resume returns the run of the history that is resumed, nil to run all the jobs
+func (i *Input) Resume() *runner.Resume {
+	if i.resumeRun == nil {
+		return nil
+	}
+	job, step, _ := strings.Cut(i.fromStep, ":")
+	return &runner.Resume{Run: i.resumeRun, Job: job, Step: step}
+}
+
+func (i *Input) runnerHook(path string, fallback string) string {
+	// the variables of the shell aren't used, a self-hosted runner installed on the machine
+	// doesn't run its hooks in the local runs
+	if path == "" {
+		path = fallback
+	}
+	return i.resolve(path)
+}
+
+// HistoryStore returns the history the run is recorded in, nil if it is not recorded
+func (i *Input) HistoryStore() *history.Store {
+	if i.noHistory || i.historyDir == "" {
+		return nil
+	}
+	return &history.Store{Dir: i.historyDir, Limit: historyLimit}
+}
+
 // Inputfile returns the path to the input file
 func (i *Input) Inputfile() string {
 	return i.resolve(i.inputfile)
@@ -103,5 +103,11 @@ func printList(plan *model.Plan) error {
 	if duplicateJobIDs {
 		fmt.Print("\nDetected multiple jobs with the same job name, use `-W` to specify the path to the specific workflow.\n")
 	}
+	if len(plan.Skipped) > 0 {
+		fmt.Print("\nSkipped workflows:\n")
+		for _, skipped := range plan.Skipped {
+			fmt.Printf("  %s (%s): %s\n", skipped.Workflow.Name, skipped.Workflow.File, skipped.Reason)
+		}
+	}
 	return nil
 }
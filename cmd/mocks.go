@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/runner"
+)
+
+// actionMocksFile is the file of the mocks of the actions, like .github/gha-mocks.yml:
+//
+//	mocks:
+//	  - uses: slackapi/slack-github-action@*
+//	    noop: true
+//	  - uses: softprops/action-gh-release@*
+//	    outputs:
+//	      url: https://github.com/octocat/hello-world/releases/v1.0.0
+//	  - uses: octocat/deploy-action@*
+//	    action: ./.github/mocks/deploy
+type actionMocksFile struct {
+	Mocks []struct {
+		Uses    string            `yaml:"uses"`
+		Action  string            `yaml:"action"`
+		Outputs map[string]string `yaml:"outputs"`
+		Noop    bool              `yaml:"noop"`
+	} `yaml:"mocks"`
+}
+
+// readActionMocks reads the mocks of the actions of the file, a missing file has no mocks unless required
+func readActionMocks(file string, required bool) ([]runner.ActionMock, error) {
+	content, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var parsed actionMocksFile
+	if err := yaml.Unmarshal(content, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid action mocks %s: %w", file, err)
+	}
+	mocks := make([]runner.ActionMock, 0, len(parsed.Mocks))
+	for i, m := range parsed.Mocks {
+		substitutes := 0
+		for _, set := range []bool{m.Action != "", m.Outputs != nil, m.Noop} {
+			if set {
+				substitutes++
+			}
+		}
+		if m.Uses == "" || substitutes != 1 {
+			return nil, fmt.Errorf("invalid action mock %d in %s, expected uses and one of action, outputs or noop", i+1, file)
+		}
+		mocks = append(mocks, runner.ActionMock{Uses: m.Uses, Action: m.Action, Outputs: m.Outputs, Noop: m.Noop})
+	}
+	log.Debugf("Mocking the actions of %d patterns of %s", len(mocks), file)
+	return mocks, nil
+}
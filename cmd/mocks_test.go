@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/runner"
+)
+
+func TestReadActionMocks(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "gha-mocks.yml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+mocks:
+  - uses: slackapi/slack-github-action@*
+    noop: true
+  - uses: softprops/action-gh-release@*
+    outputs:
+      url: https://github.com/octocat/hello-world/releases/v1.0.0
+  - uses: octocat/deploy-action@*
+    action: ./.github/mocks/deploy
+`), 0o600))
+	mocks, err := readActionMocks(file, false)
+	require.NoError(t, err)
+	assert.Equal(t, []runner.ActionMock{
+		{Uses: "slackapi/slack-github-action@*", Noop: true},
+		{Uses: "softprops/action-gh-release@*", Outputs: map[string]string{"url": "https://github.com/octocat/hello-world/releases/v1.0.0"}},
+		{Uses: "octocat/deploy-action@*", Action: "./.github/mocks/deploy"},
+	}, mocks)
+
+	// the default file is optional
+	missing := filepath.Join(t.TempDir(), "gha-mocks.yml")
+	mocks, err = readActionMocks(missing, false)
+	assert.NoError(t, err)
+	assert.Empty(t, mocks)
+	_, err = readActionMocks(missing, true)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	require.NoError(t, os.WriteFile(file, []byte(`
+mocks:
+  - uses: slackapi/slack-github-action@*
+    noop: true
+    action: ./mocks/slack
+`), 0o600))
+	_, err = readActionMocks(file, false)
+	assert.EqualError(t, err, "invalid action mock 1 in "+file+", expected uses and one of action, outputs or noop")
+}
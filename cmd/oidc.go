@@ -3,8 +3,7 @@ package cmd
 import (
 	"context"
 	"crypto/rand"
-	"crypto/rsa"
-	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,30 +11,26 @@ import (
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/Leapfrog-DevOps/gha/pkg/common/git"
+	"github.com/Leapfrog-DevOps/gha/pkg/oidc"
 	"github.com/adrg/xdg"
 	"github.com/golang-jwt/jwt/v5"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
-type OIDCStatus struct {
-	Running   bool   `json:"running"`
-	PID       int    `json:"pid,omitempty"`
-	Port      int    `json:"port,omitempty"`
-	NgrokURL  string `json:"ngrok_url,omitempty"`
-	NgrokPID  int    `json:"ngrok_pid,omitempty"`
-	StartTime string `json:"start_time,omitempty"`
-	Password  string `json:"password,omitempty"`
-}
+// OIDCStatus is the status of the OIDC server, the jobs read it too
+type OIDCStatus = oidc.Status
 
-func createOIDCCommand() *cobra.Command {
+func createOIDCCommand(input *Input) *cobra.Command {
 	oidcCmd := &cobra.Command{
 		Use:   "oidc",
 		Short: "Manage OIDC server for local GitHub Actions",
-		Long: `Start, stop, and check status of OIDC server with ngrok forwarding
+		Long: `Start, stop, and check status of OIDC server with ngrok, cloudflared or your own reverse proxy
 
 The OIDC server supports custom ngrok domains using the global --domain flag:
   gha oidc start --domain my-custom-domain.ngrok.io
@@ -57,7 +52,9 @@ Or configure the domain in .gharc for persistent use:
 	oidcCmd.AddCommand(createOIDCStatusCommand())
 	oidcCmd.AddCommand(createOIDCStopCommand())
 	oidcCmd.AddCommand(createOIDCRestartCommand())
+	oidcCmd.AddCommand(createOIDCRotateKeysCommand())
 	oidcCmd.AddCommand(createOIDCSetupCommand())
+	oidcCmd.AddCommand(createOIDCVerifyCommand(input))
 	oidcCmd.AddCommand(createOIDCCleanupCommand())
 
 	return oidcCmd
@@ -66,13 +63,16 @@ Or configure the domain in .gharc for persistent use:
 func createOIDCStartCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "start",
-		Short: "Start OIDC server and ngrok forwarding",
-		Long: `Starts a local OIDC server on port 8080 and creates an ngrok tunnel for external access.
+		Short: "Start OIDC server and its tunnel",
+		Long: `Starts a local OIDC server on port 8080 and exposes it as the issuer of its tokens.
 The server provides JWT tokens for GitHub Actions OIDC authentication.
 
 Examples:
   gha oidc start                                    # Use random ngrok domain
   gha oidc start --domain my-domain.ngrok.io       # Use custom domain
+  gha oidc start --tunnel cloudflared               # Use a cloudflared quick tunnel
+  gha oidc start --issuer-url https://oidc.example  # Use an existing reverse proxy
+  gha oidc start --tunnel local                     # Only reachable from job containers
 
 Configure domain in .gharc for persistent use:
   echo "--domain my-domain.ngrok.io" >> .gharc`,
@@ -82,16 +82,24 @@ Configure domain in .gharc for persistent use:
 			if domain == "" {
 				domain, _ = cmd.Root().PersistentFlags().GetString("domain")
 			}
-			return startOIDCServerWithDomain(domain)
+			provider, _ := cmd.Flags().GetString("tunnel")
+			issuerURL, _ := cmd.Flags().GetString("issuer-url")
+			tunnel, err := newTunnel(provider, domain, issuerURL)
+			if err != nil {
+				return err
+			}
+			return startOIDCServerWithTunnel(tunnel)
 		},
 	}
+	cmd.Flags().String("tunnel", "", "Tunnel exposing the issuer (ngrok, cloudflared, local), defaults to ngrok")
+	cmd.Flags().String("issuer-url", "", "Issuer URL of an existing reverse proxy forwarding to the OIDC server")
 	return cmd
 }
 
 func createOIDCStatusCommand() *cobra.Command {
 	return &cobra.Command{
 		Use:   "status",
-		Short: "Show OIDC server status",
+		Short: "Show OIDC server status and check its health",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return showOIDCStatus()
 		},
@@ -101,7 +109,7 @@ func createOIDCStatusCommand() *cobra.Command {
 func createOIDCStopCommand() *cobra.Command {
 	return &cobra.Command{
 		Use:   "stop",
-		Short: "Stop OIDC server and ngrok forwarding",
+		Short: "Stop OIDC server and its tunnel",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return stopOIDCServer()
 		},
@@ -111,28 +119,90 @@ func createOIDCStopCommand() *cobra.Command {
 func createOIDCRestartCommand() *cobra.Command {
 	return &cobra.Command{
 		Use:   "restart",
-		Short: "Restart OIDC server (keeps ngrok running)",
+		Short: "Restart OIDC server (keeps the tunnel running)",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return restartOIDCServer()
 		},
 	}
 }
 
+func createOIDCRotateKeysCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rotate-keys",
+		Short: "Rotate the OIDC signing key",
+		Long: `Generates the next signing key for the OIDC tokens. It is published in /.well-known/jwks
+right away and signs the tokens after the overlap window, so identity providers caching the JWKS
+know it before they get tokens it signed. The key published by a previous rotation that doesn't
+sign yet signs from now on. A replaced key stays published for the overlap window after its
+successor started signing, so tokens it signed keep working. A running server picks up the keys
+right away.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			overlap, _ := cmd.Flags().GetDuration("overlap")
+			return rotateOIDCKeys(overlap)
+		},
+	}
+	cmd.Flags().Duration("overlap", 24*time.Hour, "How long the new key is published before it signs, and the replaced key stays published after")
+	return cmd
+}
+
 func createOIDCSetupCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "setup",
 		Short: "Setup cloud provider OIDC integration",
-		Long:  "Automatically configure cloud provider OIDC identity provider and IAM roles",
+		Long: `Automatically configure cloud provider OIDC identity provider and IAM roles
+
+With --output the identity provider, trust policy and role binding are rendered as files for
+review instead, without calling the cloud provider. The subject conditions are derived from
+the current repository and branch, unless --subject is given.
+
+Examples:
+  gha oidc setup --provider aws                                  # Create the AWS resources with the AWS CLI
+  gha oidc setup --provider aws --output cloudformation          # Render a CloudFormation template
+  gha oidc setup --provider gcp --output terraform --dir infra   # Render Terraform into infra/
+  gha oidc setup --provider azure --output json --subject repo:octo-org/octo-repo:environment:production`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			provider, _ := cmd.Flags().GetString("provider")
 			roleName, _ := cmd.Flags().GetString("role-name")
 			policy, _ := cmd.Flags().GetString("policy")
-			return setupOIDCProvider(provider, roleName, policy)
+			output, _ := cmd.Flags().GetString("output")
+			if output == "" {
+				return setupOIDCProvider(provider, roleName, policy)
+			}
+
+			dir, _ := cmd.Flags().GetString("dir")
+			issuerURL, _ := cmd.Flags().GetString("issuer-url")
+			thumbprint, _ := cmd.Flags().GetString("thumbprint")
+			subjects, _ := cmd.Flags().GetStringArray("subject")
+			if !cmd.Flags().Changed("policy") {
+				policy = ""
+			}
+			files, err := renderOIDCSetup(cmd.Context(), &oidcSetupInput{
+				provider:   provider,
+				output:     output,
+				dir:        dir,
+				roleName:   roleName,
+				policy:     policy,
+				issuerURL:  issuerURL,
+				thumbprint: thumbprint,
+				subjects:   subjects,
+			})
+			if err != nil {
+				return err
+			}
+			for _, file := range files {
+				fmt.Printf("Wrote %s\n", file)
+			}
+			return nil
 		},
 	}
-	cmd.Flags().StringP("provider", "p", "", "Cloud provider (aws)")
+	cmd.Flags().StringP("provider", "p", "", "Cloud provider (aws, gcp, azure)")
 	cmd.Flags().StringP("role-name", "r", "gha-oidc-role", "IAM role name to create")
-	cmd.Flags().StringP("policy", "", "ReadOnlyAccess", "AWS managed policy to attach (e.g., ReadOnlyAccess, PowerUserAccess)")
+	cmd.Flags().StringP("policy", "", "ReadOnlyAccess", "Policy or role to grant (e.g., ReadOnlyAccess for aws, roles/viewer for gcp, Reader for azure)")
+	cmd.Flags().StringP("output", "o", "", "Render files instead of calling the cloud provider (terraform, cloudformation, json)")
+	cmd.Flags().String("dir", ".", "Directory the rendered files are written to")
+	cmd.Flags().String("issuer-url", "", "Issuer URL of the OIDC server, defaults to the running server")
+	cmd.Flags().String("thumbprint", "", "SSL thumbprint of the issuer for aws, fetched from the issuer if not given")
+	cmd.Flags().StringArray("subject", []string{}, "Subject allowed to assume the role, defaults to the current repository and branch")
 	cmd.MarkFlagRequired("provider")
 	return cmd
 }
@@ -160,6 +230,10 @@ func getOIDCStatusFile() (string, error) {
 	return xdg.StateFile("gha/oidc-status.json")
 }
 
+func getOIDCKeysFile() (string, error) {
+	return xdg.StateFile("gha/oidc-keys.json")
+}
+
 func saveOIDCStatus(status *OIDCStatus) error {
 	statusFile, err := getOIDCStatusFile()
 	if err != nil {
@@ -180,17 +254,7 @@ func loadOIDCStatus() (*OIDCStatus, error) {
 		return nil, err
 	}
 
-	if _, err := os.Stat(statusFile); os.IsNotExist(err) {
-		return &OIDCStatus{Running: false}, nil
-	}
-
-	data, err := os.ReadFile(statusFile)
-	if err != nil {
-		return nil, err
-	}
-
-	var status OIDCStatus
-	err = json.Unmarshal(data, &status)
+	status, err := oidc.LoadStatus(statusFile)
 	if err != nil {
 		return nil, err
 	}
@@ -201,16 +265,16 @@ func loadOIDCStatus() (*OIDCStatus, error) {
 			status.Running = false
 			status.PID = 0
 			status.Port = 0
-			status.NgrokURL = ""
-			status.NgrokPID = 0
+			status.IssuerURL = ""
+			status.TunnelPID = 0
 			status.StartTime = ""
-		} else if status.NgrokPID > 0 && !isProcessRunning(status.NgrokPID) {
-			status.NgrokURL = ""
-			status.NgrokPID = 0
+		} else if status.TunnelPID > 0 && !isProcessRunning(status.TunnelPID) {
+			status.IssuerURL = ""
+			status.TunnelPID = 0
 		}
 	}
 
-	return &status, nil
+	return status, nil
 }
 
 func isProcessRunning(pid int) bool {
@@ -226,28 +290,65 @@ func isProcessRunning(pid int) bool {
 }
 
 type OIDCServerImpl struct {
-	privateKey    *rsa.PrivateKey
-	publicKey     *rsa.PublicKey
+	keysFile      string
+	keys          *oidc.KeySet
+	keysModTime   time.Time
+	keysMu        sync.Mutex
 	issuer        string
 	port          int
 	server        *http.Server
 	expectedToken string
 }
 
-func NewOIDCServerImpl(port int, password string) (*OIDCServerImpl, error) {
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+func NewOIDCServerImpl(port int, password string, keysFile string) (*OIDCServerImpl, error) {
+	s := &OIDCServerImpl{
+		keysFile:      keysFile,
+		issuer:        fmt.Sprintf("http://localhost:%d", port),
+		port:          port,
+		expectedToken: password,
+	}
+	if _, err := s.keySet(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// keySet returns the signing keys, reloading them after 'gha oidc rotate-keys' changed the keys file
+func (s *OIDCServerImpl) keySet() (*oidc.KeySet, error) {
+	s.keysMu.Lock()
+	defer s.keysMu.Unlock()
+
+	info, err := os.Stat(s.keysFile)
+	if err == nil && s.keys != nil && info.ModTime().Equal(s.keysModTime) {
+		return s.keys, nil
+	}
+	keys, err := oidc.LoadKeySet(s.keysFile)
 	if err != nil {
 		return nil, err
 	}
+	if info, err := os.Stat(s.keysFile); err == nil {
+		s.keysModTime = info.ModTime()
+	}
+	s.keys = keys
+	return keys, nil
+}
 
-	issuer := fmt.Sprintf("http://localhost:%d", port)
-	return &OIDCServerImpl{
-		privateKey:    privateKey,
-		publicKey:     &privateKey.PublicKey,
-		issuer:        issuer,
-		port:          port,
-		expectedToken: password,
-	}, nil
+// mintToken signs the token of the claims for the audience with the current signing key
+func (s *OIDCServerImpl) mintToken(claims *oidc.Claims, audience string) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate token id: %w", err)
+	}
+	keys, err := s.keySet()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	signingKey := keys.SigningKey(now)
+
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims.ForAudience(s.issuer, audience, hex.EncodeToString(id), now))
+	jwtToken.Header["kid"] = signingKey.ID
+	return jwtToken.SignedString(signingKey.PrivateKey)
 }
 
 func (s *OIDCServerImpl) handleToken(w http.ResponseWriter, r *http.Request) {
@@ -268,8 +369,9 @@ func (s *OIDCServerImpl) handleToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token := auth[7:]
-	if s.expectedToken != "" && token != s.expectedToken {
+	// the request token of the job carries the claims of the minted token
+	claims, err := oidc.ParseRequestToken(s.expectedToken, auth[7:])
+	if err != nil {
 		http.Error(w, "Invalid token", http.StatusUnauthorized)
 		return
 	}
@@ -289,18 +391,9 @@ func (s *OIDCServerImpl) handleToken(w http.ResponseWriter, r *http.Request) {
 		audience = "https://github.com/actions"
 	}
 
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
-		"iss": s.issuer,
-		"sub": "github-actions",
-		"aud": audience,
-		"exp": time.Now().Add(time.Hour).Unix(),
-		"iat": time.Now().Unix(),
-		"nbf": time.Now().Unix(),
-	})
-	jwtToken.Header["kid"] = "1"
-
-	tokenString, err := jwtToken.SignedString(s.privateKey)
+	tokenString, err := s.mintToken(claims, audience)
 	if err != nil {
+		log.Errorf("Failed to mint OIDC token: %v", err)
 		http.Error(w, "Failed to sign token", http.StatusInternalServerError)
 		return
 	}
@@ -314,32 +407,16 @@ func (s *OIDCServerImpl) handleToken(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *OIDCServerImpl) handleJWKS(w http.ResponseWriter, r *http.Request) {
-	n := s.publicKey.N.Bytes()
-	e := s.publicKey.E
-
-	// Convert to base64url
-	nBase64 := base64.RawURLEncoding.EncodeToString(n)
-	eBytes := make([]byte, 4)
-	eBytes[0] = byte(e >> 24)
-	eBytes[1] = byte(e >> 16)
-	eBytes[2] = byte(e >> 8)
-	eBytes[3] = byte(e)
-	// Remove leading zeros
-	for len(eBytes) > 1 && eBytes[0] == 0 {
-		eBytes = eBytes[1:]
-	}
-	eBase64 := base64.RawURLEncoding.EncodeToString(eBytes)
+	keys, err := s.keySet()
+	if err != nil {
+		log.Errorf("Failed to load OIDC signing keys: %v", err)
+		http.Error(w, "Failed to load signing keys", http.StatusInternalServerError)
+		return
+	}
 
+	// rotated out keys stay published during their overlap window
 	response := map[string]interface{}{
-		"keys": []map[string]interface{}{
-			{
-				"kty": "RSA",
-				"use": "sig",
-				"kid": "1",
-				"n":   nBase64,
-				"e":   eBase64,
-			},
-		},
+		"keys": keys.JWKS(time.Now()),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -353,7 +430,7 @@ func (s *OIDCServerImpl) handleWellKnown(w http.ResponseWriter, r *http.Request)
 		"jwks_uri":                              s.issuer + "/.well-known/jwks",
 		"subject_types_supported":               []string{"public"},
 		"response_types_supported":              []string{"id_token"},
-		"claims_supported":                      []string{"sub", "aud", "exp", "iat", "iss"},
+		"claims_supported":                      oidc.SupportedClaims,
 		"id_token_signing_alg_values_supported": []string{"RS256"},
 	}
 
@@ -402,7 +479,12 @@ var globalOIDCServer *OIDCServerImpl
 
 func startOIDCServerProcess(port int) {
 	password := os.Getenv("GHA_OIDC_PASSWORD")
-	server, err := NewOIDCServerImpl(port, password)
+	keysFile, err := getOIDCKeysFile()
+	if err != nil {
+		log.Errorf("Failed to locate OIDC keys: %v", err)
+		return
+	}
+	server, err := NewOIDCServerImpl(port, password, keysFile)
 	if err != nil {
 		log.Errorf("Failed to create OIDC server: %v", err)
 		return
@@ -416,10 +498,10 @@ func startOIDCServerProcess(port int) {
 }
 
 func startOIDCServer() error {
-	return startOIDCServerWithDomain("")
+	return startOIDCServerWithTunnel(&ngrokTunnel{})
 }
 
-func startOIDCServerWithDomain(domain string) error {
+func startOIDCServerWithTunnel(tunnel Tunnel) error {
 	status, err := loadOIDCStatus()
 	if err != nil {
 		return fmt.Errorf("failed to load status: %w", err)
@@ -427,8 +509,8 @@ func startOIDCServerWithDomain(domain string) error {
 
 	if status.Running {
 		fmt.Printf("OIDC server is already running (PID: %d, Port: %d)\n", status.PID, status.Port)
-		if status.NgrokURL != "" {
-			fmt.Printf("Ngrok URL: %s\n", status.NgrokURL)
+		if status.IssuerURL != "" {
+			fmt.Printf("Issuer URL: %s (%s)\n", status.IssuerURL, status.Provider)
 		}
 		return nil
 	}
@@ -436,13 +518,17 @@ func startOIDCServerWithDomain(domain string) error {
 	// Check if running in server mode
 	if os.Getenv("GHA_OIDC_MODE") == "server" {
 		port, _ := strconv.Atoi(os.Getenv("GHA_PORT"))
-		ngrokURL := os.Getenv("GHA_NGROK_URL")
+		issuerURL := os.Getenv("GHA_ISSUER_URL")
 		password := os.Getenv("GHA_OIDC_PASSWORD")
-		server, err := NewOIDCServerImpl(port, password)
+		keysFile, err := getOIDCKeysFile()
+		if err != nil {
+			return fmt.Errorf("failed to locate OIDC keys: %w", err)
+		}
+		server, err := NewOIDCServerImpl(port, password, keysFile)
 		if err != nil {
 			return fmt.Errorf("failed to create OIDC server: %w", err)
 		}
-		server.issuer = ngrokURL
+		server.issuer = issuerURL
 		return server.Start()
 	}
 
@@ -456,50 +542,31 @@ func startOIDCServerWithDomain(domain string) error {
 	}
 	password := fmt.Sprintf("%x", passwordBytes)
 
-	// Start ngrok first - validate port
+	// Start the tunnel first - validate port
 	if port <= 0 || port > 65535 {
 		return fmt.Errorf("invalid port number: %d", port)
 	}
 
-	// Build ngrok command with optional domain
-	var ngrokCmd *exec.Cmd
-	if domain != "" {
-		// Use custom domain
-		ngrokCmd = exec.Command("ngrok", "http", strconv.Itoa(port), "--domain", domain)
-		fmt.Printf("Starting ngrok with custom domain: %s\n", domain)
-	} else {
-		// Use default (random) domain
-		ngrokCmd = exec.Command("ngrok", "http", strconv.Itoa(port))
-		fmt.Println("Starting ngrok with random domain")
-	}
-
-	if err := ngrokCmd.Start(); err != nil {
-		return fmt.Errorf("failed to start ngrok: %w", err)
-	}
-
-	// Wait for ngrok to establish tunnel
-	time.Sleep(3 * time.Second)
-
-	// Get ngrok URL
-	ngrokURL, err := getNgrokURL()
+	issuerURL, tunnelProcess, err := tunnel.Start(port)
 	if err != nil {
-		if killErr := ngrokCmd.Process.Kill(); killErr != nil {
-			_ = killErr // explicitly ignore kill error
+		return err
+	}
+	stopTunnel := func() {
+		if tunnelProcess != nil {
+			_ = tunnelProcess.Kill()
 		}
-		return fmt.Errorf("failed to get ngrok URL: %w", err)
 	}
 
-	// Now start server with ngrok URL - validate executable path
+	// Now start server with the issuer URL - validate executable path
 	if len(os.Args) == 0 || os.Args[0] == "" {
+		stopTunnel()
 		return fmt.Errorf("invalid executable path")
 	}
 	serverCmd := exec.Command(os.Args[0], "oidc", "start")
-	serverCmd.Env = append(os.Environ(), "GHA_OIDC_MODE=server", fmt.Sprintf("GHA_NGROK_URL=%s", ngrokURL), fmt.Sprintf("GHA_PORT=%d", port), fmt.Sprintf("GHA_OIDC_PASSWORD=%s", password))
+	serverCmd.Env = append(os.Environ(), "GHA_OIDC_MODE=server", fmt.Sprintf("GHA_ISSUER_URL=%s", issuerURL), fmt.Sprintf("GHA_PORT=%d", port), fmt.Sprintf("GHA_OIDC_PASSWORD=%s", password))
 
 	if err := serverCmd.Start(); err != nil {
-		if killErr := ngrokCmd.Process.Kill(); killErr != nil {
-			_ = killErr // explicitly ignore kill error
-		}
+		stopTunnel()
 		return fmt.Errorf("failed to start OIDC server: %w", err)
 	}
 
@@ -510,11 +577,14 @@ func startOIDCServerWithDomain(domain string) error {
 		Running:   true,
 		PID:       serverCmd.Process.Pid,
 		Port:      port,
-		NgrokURL:  ngrokURL,
-		NgrokPID:  ngrokCmd.Process.Pid,
+		Provider:  tunnel.Name(),
+		IssuerURL: issuerURL,
 		StartTime: time.Now().Format(time.RFC3339),
 		Password:  password,
 	}
+	if tunnelProcess != nil {
+		status.TunnelPID = tunnelProcess.Pid
+	}
 
 	if err := saveOIDCStatus(status); err != nil {
 		log.Warnf("Failed to save status: %v", err)
@@ -523,37 +593,42 @@ func startOIDCServerWithDomain(domain string) error {
 	fmt.Printf("OIDC server started successfully!\n")
 	fmt.Printf("PID: %d\n", status.PID)
 	fmt.Printf("Port: %d\n", status.Port)
-	if status.NgrokURL != "" {
-		fmt.Printf("Ngrok URL: %s\n", status.NgrokURL)
-	}
+	fmt.Printf("Issuer URL: %s (%s)\n", status.IssuerURL, status.Provider)
 	fmt.Println("Server running in background. Use 'gha oidc stop' to stop.")
 
 	return nil
 }
 
-func getNgrokURL() (string, error) {
-	// Try to get ngrok URL from API
-	cmd := exec.Command("curl", "-s", "http://localhost:4040/api/tunnels")
-	output, err := cmd.Output()
+func rotateOIDCKeys(overlap time.Duration) error {
+	keysFile, err := getOIDCKeysFile()
 	if err != nil {
-		return "", err
+		return err
 	}
-
-	var response struct {
-		Tunnels []struct {
-			PublicURL string `json:"public_url"`
-		} `json:"tunnels"`
+	keys, err := oidc.LoadKeySet(keysFile)
+	if err != nil {
+		return err
 	}
-
-	if err := json.Unmarshal(output, &response); err != nil {
-		return "", err
+	now := time.Now()
+	previous := keys.SigningKey(now)
+	key, err := keys.Rotate(now, overlap)
+	if err != nil {
+		return fmt.Errorf("failed to generate signing key: %w", err)
 	}
-
-	if len(response.Tunnels) > 0 {
-		return response.Tunnels[0].PublicURL, nil
+	if err := keys.Save(keysFile); err != nil {
+		return fmt.Errorf("failed to save signing keys: %w", err)
 	}
 
-	return "", fmt.Errorf("no tunnels found")
+	signing := keys.SigningKey(now)
+	fmt.Printf("New signing key %s is published and signs the tokens from %s\n", key.ID, key.ActivatesAt.Format(time.RFC3339))
+	if signing != previous {
+		fmt.Printf("Signing key %s, published by the previous rotation, signs the tokens now\n", signing.ID)
+	}
+	fmt.Printf("Previous signing key %s is published until %s\n", previous.ID, previous.ExpiresAt.Format(time.RFC3339))
+	fmt.Printf("Published keys:\n")
+	for _, jwk := range keys.JWKS(now) {
+		fmt.Printf("  %s\n", jwk.Kid)
+	}
+	return nil
 }
 
 func showOIDCStatus() error {
@@ -572,17 +647,68 @@ func showOIDCStatus() error {
 	fmt.Printf("  PID: %d\n", status.PID)
 	fmt.Printf("  Port: %d\n", status.Port)
 	fmt.Printf("  Started: %s\n", status.StartTime)
+	fmt.Printf("  Provider: %s\n", status.Provider)
 
-	if status.NgrokURL != "" {
-		fmt.Printf("  Ngrok URL: %s\n", status.NgrokURL)
-		fmt.Printf("  Ngrok PID: %d\n", status.NgrokPID)
+	if status.IssuerURL != "" {
+		fmt.Printf("  Issuer URL: %s\n", status.IssuerURL)
+		if status.TunnelPID > 0 {
+			fmt.Printf("  Tunnel PID: %d\n", status.TunnelPID)
+		}
 	} else {
-		fmt.Printf("  Ngrok: Not available\n")
+		fmt.Printf("  Tunnel: Not available\n")
+	}
+
+	fmt.Printf("Health:\n")
+	for _, check := range checkOIDCHealth(status) {
+		if check.err != nil {
+			fmt.Printf("  ❌ %s: %v\n", check.name, check.err)
+		} else {
+			fmt.Printf("  ✅ %s\n", check.name)
+		}
 	}
 
 	return nil
 }
 
+type oidcHealthCheck struct {
+	name string
+	err  error
+}
+
+// checkOIDCHealth checks the local server, and whether the issuer URL reaches it through the tunnel
+func checkOIDCHealth(status *OIDCStatus) []oidcHealthCheck {
+	client := &http.Client{Timeout: 5 * time.Second}
+	checks := []oidcHealthCheck{}
+
+	localURL := fmt.Sprintf("http://localhost:%d/health", status.Port)
+	check := oidcHealthCheck{name: "Server responds at " + localURL}
+	if resp, err := client.Get(localURL); err != nil {
+		check.err = err
+	} else {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			check.err = fmt.Errorf("unexpected status %s", resp.Status)
+		}
+	}
+	checks = append(checks, check)
+
+	// the local-only issuer is only reachable from job containers
+	if status.IssuerURL == "" || status.Provider == "local" {
+		return checks
+	}
+	discoveryURL := status.IssuerURL + "/.well-known/openid-configuration"
+	check = oidcHealthCheck{name: fmt.Sprintf("Issuer is reachable through %s at %s", status.Provider, discoveryURL)}
+	var config struct {
+		Issuer string `json:"issuer"`
+	}
+	if err := getJSON(discoveryURL, &config); err != nil {
+		check.err = err
+	} else if config.Issuer != status.IssuerURL {
+		check.err = fmt.Errorf("the discovery document has the issuer '%s'", config.Issuer)
+	}
+	return append(checks, check)
+}
+
 func stopOIDCServer() error {
 	status, err := loadOIDCStatus()
 	if err != nil {
@@ -610,17 +736,17 @@ func stopOIDCServer() error {
 		}
 	}
 
-	// Stop ngrok
-	if status.NgrokPID > 0 {
-		fmt.Printf("Attempting to stop ngrok (PID: %d)\n", status.NgrokPID)
-		if process, err := os.FindProcess(status.NgrokPID); err == nil {
+	// Stop the tunnel
+	if status.TunnelPID > 0 {
+		fmt.Printf("Attempting to stop %s (PID: %d)\n", status.Provider, status.TunnelPID)
+		if process, err := os.FindProcess(status.TunnelPID); err == nil {
 			if err := process.Kill(); err != nil {
-				errors = append(errors, fmt.Sprintf("Failed to kill ngrok process: %v", err))
+				errors = append(errors, fmt.Sprintf("Failed to kill %s process: %v", status.Provider, err))
 			} else {
-				fmt.Printf("Stopped ngrok (PID: %d)\n", status.NgrokPID)
+				fmt.Printf("Stopped %s (PID: %d)\n", status.Provider, status.TunnelPID)
 			}
 		} else {
-			errors = append(errors, fmt.Sprintf("Failed to find ngrok process: %v", err))
+			errors = append(errors, fmt.Sprintf("Failed to find %s process: %v", status.Provider, err))
 		}
 	}
 
@@ -637,7 +763,7 @@ func stopOIDCServer() error {
 		}
 		fmt.Println("You may need to manually kill remaining processes")
 	} else {
-		fmt.Println("OIDC server and ngrok stopped successfully")
+		fmt.Println("OIDC server and its tunnel stopped successfully")
 	}
 	return nil
 }
@@ -653,7 +779,7 @@ func restartOIDCServer() error {
 		return nil
 	}
 
-	// Stop only OIDC server, keep ngrok running
+	// Stop only OIDC server, keep the tunnel running
 	if status.PID > 0 {
 		fmt.Printf("Stopping OIDC server (PID: %d)\n", status.PID)
 		if process, err := os.FindProcess(status.PID); err == nil {
@@ -663,22 +789,26 @@ func restartOIDCServer() error {
 		}
 	}
 
-	// Get existing ngrok URL
-	ngrokURL := status.NgrokURL
-	if ngrokURL == "" {
+	// Get existing issuer URL
+	issuerURL := status.IssuerURL
+	if issuerURL == "" {
+		if status.Provider != "ngrok" {
+			return fmt.Errorf("the %s tunnel is not available, run 'gha oidc stop' and 'gha oidc start' again", status.Provider)
+		}
 		var err error
-		ngrokURL, err = getNgrokURL()
+		issuerURL, err = getNgrokURL()
 		if err != nil {
 			return fmt.Errorf("failed to get ngrok URL: %w", err)
 		}
+		status.IssuerURL = issuerURL
 	}
 
-	// Start new server with existing ngrok URL and password - validate executable path
+	// Start new server with existing issuer URL and password - validate executable path
 	if len(os.Args) == 0 || os.Args[0] == "" {
 		return fmt.Errorf("invalid executable path")
 	}
 	serverCmd := exec.Command(os.Args[0], "oidc", "start")
-	serverCmd.Env = append(os.Environ(), "GHA_OIDC_MODE=server", fmt.Sprintf("GHA_NGROK_URL=%s", ngrokURL), fmt.Sprintf("GHA_PORT=%d", status.Port), fmt.Sprintf("GHA_OIDC_PASSWORD=%s", status.Password))
+	serverCmd.Env = append(os.Environ(), "GHA_OIDC_MODE=server", fmt.Sprintf("GHA_ISSUER_URL=%s", issuerURL), fmt.Sprintf("GHA_PORT=%d", status.Port), fmt.Sprintf("GHA_OIDC_PASSWORD=%s", status.Password))
 
 	if err := serverCmd.Start(); err != nil {
 		return fmt.Errorf("failed to restart OIDC server: %w", err)
@@ -693,15 +823,18 @@ func restartOIDCServer() error {
 		log.Warnf("Failed to save status: %v", err)
 	}
 
+	fmt.Printf("\nOIDC server restarted successfully!\n")
+	fmt.Printf("PID: %d\n", status.PID)
+	fmt.Printf("Issuer URL: %s (%s)\n", issuerURL, status.Provider)
+
 	// Get and display thumbprint
-	thumbprint, err := getThumbprint(ngrokURL)
-	if err != nil {
-		log.Warnf("Failed to get thumbprint: %v", err)
-	} else {
-		fmt.Printf("\nOIDC server restarted successfully!\n")
-		fmt.Printf("PID: %d\n", status.PID)
-		fmt.Printf("Ngrok URL: %s\n", ngrokURL)
-		fmt.Printf("Thumbprint: %s\n", thumbprint)
+	if strings.HasPrefix(issuerURL, "https://") {
+		thumbprint, err := getThumbprint(issuerURL)
+		if err != nil {
+			log.Warnf("Failed to get thumbprint: %v", err)
+		} else {
+			fmt.Printf("Thumbprint: %s\n", thumbprint)
+		}
 	}
 
 	return nil
@@ -750,7 +883,7 @@ func getThumbprint(url string) (string, error) {
 
 func setupOIDCProvider(provider, roleName, policy string) error {
 	if provider != "aws" {
-		return fmt.Errorf("unsupported provider: %s. Currently supported: aws", provider)
+		return fmt.Errorf("unsupported provider: %s. Currently supported: aws, use --output to render the setup of other providers", provider)
 	}
 
 	// Check if OIDC server is running
@@ -759,21 +892,24 @@ func setupOIDCProvider(provider, roleName, policy string) error {
 		return fmt.Errorf("failed to load OIDC status: %w", err)
 	}
 
-	if !status.Running || status.NgrokURL == "" {
-		return fmt.Errorf("OIDC server is not running or ngrok URL not available. Please run 'gha oidc start' first")
+	if !status.Running || status.IssuerURL == "" {
+		return fmt.Errorf("OIDC server is not running or its issuer URL not available. Please run 'gha oidc start' first")
+	}
+	if status.Provider == "local" {
+		return fmt.Errorf("the local-only OIDC server is not reachable from AWS, start it with a tunnel or --issuer-url")
 	}
 
 	fmt.Printf("Setting up AWS OIDC integration...\n")
-	fmt.Printf("OIDC Provider URL: %s\n", status.NgrokURL)
+	fmt.Printf("OIDC Provider URL: %s\n", status.IssuerURL)
 	fmt.Printf("Role Name: %s\n", roleName)
 	fmt.Printf("Policy: %s\n", policy)
 
-	return setupAWSProvider(status.NgrokURL, roleName, policy)
+	return setupAWSProvider(status.IssuerURL, roleName, policy)
 }
 
-func setupAWSProvider(ngrokURL, roleName, policy string) error {
-	// Extract domain from ngrok URL
-	domain := strings.TrimPrefix(ngrokURL, "https://")
+func setupAWSProvider(issuerURL, roleName, policy string) error {
+	// Extract domain from the issuer URL
+	domain := strings.TrimPrefix(issuerURL, "https://")
 	domain = strings.TrimPrefix(domain, "http://")
 
 	// Get AWS account ID
@@ -788,7 +924,7 @@ func setupAWSProvider(ngrokURL, roleName, policy string) error {
 
 	// Get SSL certificate thumbprint
 	fmt.Println("Getting SSL certificate thumbprint...")
-	thumbprint, err := getThumbprint(ngrokURL)
+	thumbprint, err := getThumbprint(issuerURL)
 	if err != nil {
 		return fmt.Errorf("failed to get SSL thumbprint: %w", err)
 	}
@@ -799,7 +935,7 @@ func setupAWSProvider(ngrokURL, roleName, policy string) error {
 	providerArn := fmt.Sprintf("arn:aws:iam::%s:oidc-provider/%s", accountID, domain)
 
 	createProviderCmd := exec.Command("aws", "iam", "create-open-id-connect-provider",
-		"--url", ngrokURL,
+		"--url", issuerURL,
 		"--thumbprint-list", thumbprint,
 		"--client-id-list", "sts.amazonaws.com")
 
@@ -814,7 +950,11 @@ func setupAWSProvider(ngrokURL, roleName, policy string) error {
 		fmt.Println("OIDC Identity Provider created successfully")
 	}
 
-	// Create trust policy
+	// Create trust policy, the subject of the tokens is scoped to the repository like GitHub's
+	subject := "repo:*"
+	if repo, err := git.FindGithubRepo(context.Background(), ".", "github.com", "origin"); err == nil && repo != "" {
+		subject = fmt.Sprintf("repo:%s:*", repo)
+	}
 	trustPolicy := fmt.Sprintf(`{
   "Version": "2012-10-17",
   "Statement": [
@@ -826,13 +966,15 @@ func setupAWSProvider(ngrokURL, roleName, policy string) error {
       "Action": "sts:AssumeRoleWithWebIdentity",
       "Condition": {
         "StringEquals": {
-          "%s:aud": "sts.amazonaws.com",
-          "%s:sub": "github-actions"
+          "%s:aud": "sts.amazonaws.com"
+        },
+        "StringLike": {
+          "%s:sub": "%s"
         }
       }
     }
   ]
-}`, providerArn, domain, domain)
+}`, providerArn, domain, domain, subject)
 
 	// Write trust policy to temp file
 	trustPolicyFile := "/tmp/gha-trust-policy.json"
@@ -873,10 +1015,11 @@ func setupAWSProvider(ngrokURL, roleName, policy string) error {
 
 	fmt.Println("\n✅ AWS OIDC Setup Complete!")
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-	fmt.Printf("OIDC Provider: %s\n", ngrokURL)
+	fmt.Printf("OIDC Provider: %s\n", issuerURL)
 	fmt.Printf("Provider ARN:  %s\n", providerArn)
 	fmt.Printf("Role ARN:      %s\n", roleArn)
 	fmt.Printf("Policy:        %s\n", policyArn)
+	fmt.Printf("Subject:       %s\n", subject)
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	fmt.Println("\nUse this in your workflow:")
 	fmt.Printf(`      - name: Configure AWS Credentials
@@ -915,8 +1058,8 @@ func cleanupAWSProvider(roleName string, force bool) error {
 	// Try to get the OIDC provider URL from running server or ask user
 	var domain string
 	status, err := loadOIDCStatus()
-	if err == nil && status.Running && status.NgrokURL != "" {
-		domain = strings.TrimPrefix(status.NgrokURL, "https://")
+	if err == nil && status.Running && status.IssuerURL != "" {
+		domain = strings.TrimPrefix(status.IssuerURL, "https://")
 		domain = strings.TrimPrefix(domain, "http://")
 		fmt.Printf("Found running OIDC server with domain: %s\n", domain)
 	} else {
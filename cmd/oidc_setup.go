@@ -0,0 +1,437 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common/git"
+	"github.com/Leapfrog-DevOps/gha/pkg/oidc"
+	log "github.com/sirupsen/logrus"
+)
+
+// oidcSetupInput configures the infrastructure-as-code rendered by 'gha oidc setup --output'
+type oidcSetupInput struct {
+	provider   string
+	output     string
+	dir        string
+	roleName   string
+	policy     string
+	issuerURL  string
+	thumbprint string
+	subjects   []string
+}
+
+// oidcSetup is the trust between a cloud provider and the OIDC server, rendered as files for review
+type oidcSetup struct {
+	Provider           string                     `json:"provider"`
+	Issuer             string                     `json:"issuer"`
+	Audience           string                     `json:"audience,omitempty"`
+	Thumbprints        []string                   `json:"thumbprints,omitempty"`
+	Subjects           []string                   `json:"subjects"`
+	Role               string                     `json:"role"`
+	Policy             string                     `json:"policy"`
+	TrustPolicy        map[string]interface{}     `json:"trust_policy,omitempty"`
+	AttributeMapping   map[string]string          `json:"attribute_mapping,omitempty"`
+	AttributeCondition string                     `json:"attribute_condition,omitempty"`
+	Credentials        []azureFederatedCredential `json:"federated_credentials,omitempty"`
+}
+
+type azureFederatedCredential struct {
+	Name     string   `json:"name"`
+	Issuer   string   `json:"issuer"`
+	Subject  string   `json:"subject"`
+	Audience []string `json:"audiences"`
+}
+
+// defaultOIDCSetupPolicies are the permissions granted to the role, unless --policy is given
+var defaultOIDCSetupPolicies = map[string]string{
+	"aws":   "ReadOnlyAccess",
+	"gcp":   "roles/viewer",
+	"azure": "Reader",
+}
+
+var oidcSetupAudiences = map[string]string{
+	"aws":   "sts.amazonaws.com",
+	"gcp":   "", // the default audience of the workload identity provider
+	"azure": "api://AzureADTokenExchange",
+}
+
+// renderOIDCSetup writes the identity provider, trust policy and role binding of the provider as
+// files, without calling the cloud provider
+func renderOIDCSetup(ctx context.Context, input *oidcSetupInput) ([]string, error) {
+	setup, err := newOIDCSetup(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	var name string
+	var content []byte
+	switch input.output {
+	case "terraform":
+		name = fmt.Sprintf("gha-oidc-%s.tf", setup.Provider)
+		content, err = setup.terraform()
+	case "cloudformation":
+		if setup.Provider != "aws" {
+			return nil, fmt.Errorf("the cloudformation output only supports the aws provider")
+		}
+		name = "gha-oidc-aws.cfn.json"
+		content, err = setup.cloudFormation()
+	case "json":
+		name = fmt.Sprintf("gha-oidc-%s.json", setup.Provider)
+		content, err = json.MarshalIndent(setup, "", "  ")
+		content = append(content, '\n')
+	default:
+		return nil, fmt.Errorf("unsupported output: %s. Supported: terraform, cloudformation, json", input.output)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(input.dir, 0o755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(input.dir, name)
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		return nil, err
+	}
+	return []string{path}, nil
+}
+
+func newOIDCSetup(ctx context.Context, input *oidcSetupInput) (*oidcSetup, error) {
+	audience, ok := oidcSetupAudiences[input.provider]
+	if !ok {
+		return nil, fmt.Errorf("unsupported provider: %s. Supported: aws, gcp, azure", input.provider)
+	}
+
+	issuer := strings.TrimSuffix(input.issuerURL, "/")
+	if issuer == "" {
+		if status, err := loadOIDCStatus(); err == nil && status.Running && status.Provider != "local" {
+			issuer = status.IssuerURL
+		}
+	}
+	if issuer == "" {
+		return nil, fmt.Errorf("no issuer URL, run 'gha oidc start' with a tunnel or pass --issuer-url")
+	}
+	if !strings.HasPrefix(issuer, "https://") {
+		return nil, fmt.Errorf("the issuer URL '%s' must use https to be trusted by %s", issuer, input.provider)
+	}
+
+	subjects := input.subjects
+	if len(subjects) == 0 {
+		subject, err := currentOIDCSubject(ctx, ".")
+		if err != nil {
+			return nil, fmt.Errorf("unable to derive the subject from the current repository, pass --subject: %w", err)
+		}
+		subjects = []string{subject}
+	}
+
+	policy := input.policy
+	if policy == "" {
+		policy = defaultOIDCSetupPolicies[input.provider]
+	}
+
+	setup := &oidcSetup{
+		Provider: input.provider,
+		Issuer:   issuer,
+		Audience: audience,
+		Subjects: subjects,
+		Role:     input.roleName,
+		Policy:   policy,
+	}
+
+	switch input.provider {
+	case "aws":
+		// the thumbprint is optional for AWS, a tunnel without a reachable certificate still renders
+		thumbprint := input.thumbprint
+		if thumbprint == "" {
+			var err error
+			if thumbprint, err = getThumbprint(issuer); err != nil {
+				log.Warnf("Failed to get the SSL thumbprint of %s, the identity provider is rendered without it: %v", issuer, err)
+			}
+		}
+		if thumbprint != "" {
+			setup.Thumbprints = []string{thumbprint}
+		}
+		// the account is only known when the policy is applied
+		providerArn := fmt.Sprintf("arn:aws:iam::ACCOUNT_ID:oidc-provider/%s", strings.TrimPrefix(issuer, "https://"))
+		setup.TrustPolicy = awsTrustPolicy(providerArn, issuer, audience, subjects)
+	case "gcp":
+		setup.AttributeMapping = map[string]string{
+			"google.subject":             "assertion.sub",
+			"attribute.repository":       "assertion.repository",
+			"attribute.repository_owner": "assertion.repository_owner",
+			"attribute.ref":              "assertion.ref",
+		}
+		setup.AttributeCondition = gcpAttributeCondition(subjects)
+	case "azure":
+		for i, subject := range subjects {
+			if strings.ContainsAny(subject, "*?") {
+				return nil, fmt.Errorf("azure federated credentials need exact subjects, '%s' has a wildcard", subject)
+			}
+			setup.Credentials = append(setup.Credentials, azureFederatedCredential{
+				Name:     fmt.Sprintf("%s-%d", input.roleName, i),
+				Issuer:   issuer,
+				Subject:  subject,
+				Audience: []string{audience},
+			})
+		}
+	}
+	return setup, nil
+}
+
+// currentOIDCSubject is the subject of the tokens for the checked out repository and branch
+func currentOIDCSubject(ctx context.Context, dir string) (string, error) {
+	repo, err := git.FindGithubRepo(ctx, dir, "github.com", "origin")
+	if err != nil {
+		return "", err
+	}
+	ref, err := git.FindGitRef(ctx, dir)
+	if err != nil {
+		return "", err
+	}
+	claims := &oidc.Claims{Repository: repo, Ref: ref}
+	return claims.DefaultSubject(), nil
+}
+
+func awsTrustPolicy(providerArn interface{}, issuer string, audience string, subjects []string) map[string]interface{} {
+	host := strings.TrimPrefix(issuer, "https://")
+	return map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{
+				"Effect":    "Allow",
+				"Principal": map[string]interface{}{"Federated": providerArn},
+				"Action":    "sts:AssumeRoleWithWebIdentity",
+				"Condition": map[string]interface{}{
+					"StringEquals": map[string]string{host + ":aud": audience},
+					"StringLike":   map[string][]string{host + ":sub": subjects},
+				},
+			},
+		},
+	}
+}
+
+// gcpAttributeCondition is the CEL condition accepting the subjects, the subjects with the wildcards of
+// the aws StringLike operator are matched with a regular expression
+func gcpAttributeCondition(subjects []string) string {
+	var exact, conditions []string
+	for _, subject := range subjects {
+		if strings.ContainsAny(subject, "*?") {
+			conditions = append(conditions, "assertion.sub.matches("+celString(globRegexp(subject))+")")
+		} else {
+			exact = append(exact, celString(subject))
+		}
+	}
+	switch len(exact) {
+	case 0:
+	case 1:
+		conditions = append([]string{"assertion.sub == " + exact[0]}, conditions...)
+	default:
+		conditions = append([]string{"assertion.sub in [" + strings.Join(exact, ", ") + "]"}, conditions...)
+	}
+	return strings.Join(conditions, " || ")
+}
+
+// celString quotes a string for CEL
+func celString(s string) string {
+	return "'" + strings.NewReplacer("\\", "\\\\", "'", "\\'").Replace(s) + "'"
+}
+
+// globRegexp is the regular expression matching the whole subject, `*` matches any characters and `?` one
+func globRegexp(glob string) string {
+	b := &strings.Builder{}
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// hclString quotes a string for HCL, JSON strings are valid HCL strings apart from the template sequences
+func hclString(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	s := strings.ReplaceAll(string(data), "${", "$${")
+	return strings.ReplaceAll(s, "%{", "%%{"), nil
+}
+
+var terraformTemplates = map[string]*template.Template{
+	"aws": template.Must(template.New("aws").Funcs(template.FuncMap{"hcl": hclString}).Parse(`# Generated by 'gha oidc setup --provider aws --output terraform'
+resource "aws_iam_openid_connect_provider" "gha" {
+  url             = {{ hcl .Issuer }}
+  client_id_list  = [{{ hcl .Audience }}]
+  thumbprint_list = {{ hcl .Thumbprints }}
+}
+
+resource "aws_iam_role" "gha" {
+  name = {{ hcl .Role }}
+  assume_role_policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [{
+      Effect    = "Allow"
+      Principal = { Federated = aws_iam_openid_connect_provider.gha.arn }
+      Action    = "sts:AssumeRoleWithWebIdentity"
+      Condition = {
+        StringEquals = { {{ hcl (printf "%s:aud" .Host) }} = {{ hcl .Audience }} }
+        StringLike   = { {{ hcl (printf "%s:sub" .Host) }} = {{ hcl .Subjects }} }
+      }
+    }]
+  })
+}
+
+resource "aws_iam_role_policy_attachment" "gha" {
+  role       = aws_iam_role.gha.name
+  policy_arn = {{ hcl (printf "arn:aws:iam::aws:policy/%s" .Policy) }}
+}
+
+output "role_arn" {
+  value = aws_iam_role.gha.arn
+}
+`)),
+	"gcp": template.Must(template.New("gcp").Funcs(template.FuncMap{"hcl": hclString}).Parse(`# Generated by 'gha oidc setup --provider gcp --output terraform'
+variable "project_id" {
+  type = string
+}
+
+resource "google_iam_workload_identity_pool" "gha" {
+  project                   = var.project_id
+  workload_identity_pool_id = "gha-oidc"
+  display_name              = "gha OIDC"
+}
+
+resource "google_iam_workload_identity_pool_provider" "gha" {
+  project                            = var.project_id
+  workload_identity_pool_id          = google_iam_workload_identity_pool.gha.workload_identity_pool_id
+  workload_identity_pool_provider_id = "gha"
+  attribute_mapping = {
+{{- range $key, $value := .AttributeMapping }}
+    {{ hcl $key }} = {{ hcl $value }}
+{{- end }}
+  }
+  attribute_condition = {{ hcl .AttributeCondition }}
+
+  oidc {
+    issuer_uri = {{ hcl .Issuer }}
+  }
+}
+
+resource "google_service_account" "gha" {
+  project    = var.project_id
+  account_id = {{ hcl .Role }}
+}
+
+resource "google_service_account_iam_member" "gha" {
+  service_account_id = google_service_account.gha.name
+  role               = "roles/iam.workloadIdentityUser"
+  member             = "principalSet://iam.googleapis.com/${google_iam_workload_identity_pool.gha.name}/*"
+}
+
+resource "google_project_iam_member" "gha" {
+  project = var.project_id
+  role    = {{ hcl .Policy }}
+  member  = "serviceAccount:${google_service_account.gha.email}"
+}
+
+output "workload_identity_provider" {
+  value = google_iam_workload_identity_pool_provider.gha.name
+}
+
+output "service_account" {
+  value = google_service_account.gha.email
+}
+`)),
+	"azure": template.Must(template.New("azure").Funcs(template.FuncMap{"hcl": hclString}).Parse(`# Generated by 'gha oidc setup --provider azure --output terraform'
+variable "subscription_id" {
+  type = string
+}
+
+resource "azuread_application" "gha" {
+  display_name = {{ hcl .Role }}
+}
+
+resource "azuread_service_principal" "gha" {
+  client_id = azuread_application.gha.client_id
+}
+{{ range $i, $credential := .Credentials }}
+resource "azuread_application_federated_identity_credential" "gha_{{ $i }}" {
+  application_id = azuread_application.gha.id
+  display_name   = {{ hcl $credential.Name }}
+  issuer         = {{ hcl $credential.Issuer }}
+  subject        = {{ hcl $credential.Subject }}
+  audiences      = {{ hcl $credential.Audience }}
+}
+{{ end }}
+resource "azurerm_role_assignment" "gha" {
+  scope                = "/subscriptions/${var.subscription_id}"
+  role_definition_name = {{ hcl .Policy }}
+  principal_id         = azuread_service_principal.gha.object_id
+}
+
+output "client_id" {
+  value = azuread_application.gha.client_id
+}
+`)),
+}
+
+func (setup *oidcSetup) terraform() ([]byte, error) {
+	var buf bytes.Buffer
+	err := terraformTemplates[setup.Provider].Execute(&buf, struct {
+		*oidcSetup
+		Host string
+	}{setup, strings.TrimPrefix(setup.Issuer, "https://")})
+	return buf.Bytes(), err
+}
+
+func (setup *oidcSetup) cloudFormation() ([]byte, error) {
+	// the trust policy refers to the provider created by the template
+	providerArn := map[string]string{"Ref": "OIDCProvider"}
+	provider := map[string]interface{}{
+		"Url":          setup.Issuer,
+		"ClientIdList": []string{setup.Audience},
+	}
+	if len(setup.Thumbprints) > 0 {
+		provider["ThumbprintList"] = setup.Thumbprints
+	}
+	template := map[string]interface{}{
+		"AWSTemplateFormatVersion": "2010-09-09",
+		"Description":              "Generated by 'gha oidc setup --provider aws --output cloudformation'",
+		"Resources": map[string]interface{}{
+			"OIDCProvider": map[string]interface{}{
+				"Type":       "AWS::IAM::OIDCProvider",
+				"Properties": provider,
+			},
+			"Role": map[string]interface{}{
+				"Type": "AWS::IAM::Role",
+				"Properties": map[string]interface{}{
+					"RoleName":                 setup.Role,
+					"AssumeRolePolicyDocument": awsTrustPolicy(providerArn, setup.Issuer, setup.Audience, setup.Subjects),
+					"ManagedPolicyArns":        []string{"arn:aws:iam::aws:policy/" + setup.Policy},
+				},
+			},
+		},
+		"Outputs": map[string]interface{}{
+			"RoleArn": map[string]interface{}{
+				"Value": map[string][]string{"Fn::GetAtt": {"Role", "Arn"}},
+			},
+		},
+	}
+	data, err := json.MarshalIndent(template, "", "  ")
+	return append(data, '\n'), err
+}
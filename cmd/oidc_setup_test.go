@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderOIDCSetup(t *testing.T) {
+	table := []struct {
+		provider string
+		output   string
+		file     string
+		contains []string
+	}{
+		{"aws", "terraform", "gha-oidc-aws.tf", []string{
+			`url             = "https://oidc.example.com"`,
+			`thumbprint_list = ["ABCDEF"]`,
+			`"oidc.example.com:sub" = ["repo:octo-org/octo-repo:ref:refs/heads/main"]`,
+			`policy_arn = "arn:aws:iam::aws:policy/ReadOnlyAccess"`,
+		}},
+		{"aws", "cloudformation", "gha-oidc-aws.cfn.json", []string{`"AWS::IAM::OIDCProvider"`, `"Ref": "OIDCProvider"`, `"repo:octo-org/octo-repo:ref:refs/heads/main"`}},
+		{"aws", "json", "gha-oidc-aws.json", []string{`"arn:aws:iam::ACCOUNT_ID:oidc-provider/oidc.example.com"`, `"sts.amazonaws.com"`}},
+		{"gcp", "terraform", "gha-oidc-gcp.tf", []string{
+			`issuer_uri = "https://oidc.example.com"`,
+			`attribute_condition = "assertion.sub == 'repo:octo-org/octo-repo:ref:refs/heads/main'"`,
+			`"google.subject" = "assertion.sub"`,
+			`role    = "roles/viewer"`,
+		}},
+		{"gcp", "json", "gha-oidc-gcp.json", []string{`"attribute_condition": "assertion.sub == 'repo:octo-org/octo-repo:ref:refs/heads/main'"`}},
+		{"azure", "terraform", "gha-oidc-azure.tf", []string{
+			`subject        = "repo:octo-org/octo-repo:ref:refs/heads/main"`,
+			`audiences      = ["api://AzureADTokenExchange"]`,
+			`role_definition_name = "Reader"`,
+		}},
+		{"azure", "json", "gha-oidc-azure.json", []string{`"federated_credentials"`}},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.provider+"-"+tt.output, func(t *testing.T) {
+			dir := t.TempDir()
+			files, err := renderOIDCSetup(context.Background(), &oidcSetupInput{
+				provider:   tt.provider,
+				output:     tt.output,
+				dir:        dir,
+				roleName:   "gha-oidc-role",
+				issuerURL:  "https://oidc.example.com/",
+				thumbprint: "ABCDEF",
+				subjects:   []string{"repo:octo-org/octo-repo:ref:refs/heads/main"},
+			})
+			require.NoError(t, err)
+			require.Equal(t, []string{dir + string(os.PathSeparator) + tt.file}, files)
+
+			content, err := os.ReadFile(files[0])
+			require.NoError(t, err)
+			for _, s := range tt.contains {
+				assert.Contains(t, string(content), s)
+			}
+			if tt.output != "terraform" {
+				assert.True(t, json.Valid(content))
+			}
+		})
+	}
+}
+
+func TestRenderOIDCSetupErrors(t *testing.T) {
+	table := []struct {
+		input *oidcSetupInput
+		err   string
+	}{
+		{&oidcSetupInput{provider: "oci", output: "json"}, "unsupported provider: oci. Supported: aws, gcp, azure"},
+		{&oidcSetupInput{provider: "gcp", output: "cloudformation", issuerURL: "https://oidc.example.com", subjects: []string{"repo:a/b:pull_request"}}, "the cloudformation output only supports the aws provider"},
+		{&oidcSetupInput{provider: "gcp", output: "pulumi", issuerURL: "https://oidc.example.com", subjects: []string{"repo:a/b:pull_request"}}, "unsupported output: pulumi. Supported: terraform, cloudformation, json"},
+		{&oidcSetupInput{provider: "gcp", output: "json", issuerURL: "http://host.docker.internal:8080"}, "the issuer URL 'http://host.docker.internal:8080' must use https to be trusted by gcp"},
+		{&oidcSetupInput{provider: "azure", output: "json", issuerURL: "https://oidc.example.com", subjects: []string{"repo:a/b:*"}}, "azure federated credentials need exact subjects, 'repo:a/b:*' has a wildcard"},
+	}
+
+	for _, tt := range table {
+		tt.input.dir = t.TempDir()
+		_, err := renderOIDCSetup(context.Background(), tt.input)
+		assert.EqualError(t, err, tt.err)
+	}
+}
+
+func TestGCPAttributeCondition(t *testing.T) {
+	assert.Equal(t, "assertion.sub == 'repo:a/b:ref:refs/heads/main'", gcpAttributeCondition([]string{"repo:a/b:ref:refs/heads/main"}))
+	assert.Equal(t, "assertion.sub in ['repo:a/b:pull_request', 'repo:it\\'s/b:pull_request']", gcpAttributeCondition([]string{"repo:a/b:pull_request", "repo:it's/b:pull_request"}))
+	// the wildcards of the subjects are matched like by the aws StringLike operator
+	assert.Equal(t, `assertion.sub == 'repo:a/b:pull_request' || assertion.sub.matches('^repo:a/b\\.js:ref:refs/heads/.*$') || assertion.sub.matches('^repo:a/c:environment:prod.$')`,
+		gcpAttributeCondition([]string{"repo:a/b.js:ref:refs/heads/*", "repo:a/b:pull_request", "repo:a/c:environment:prod?"}))
+}
+
+func TestHCLString(t *testing.T) {
+	s, err := hclString("${var.x} %{if}")
+	require.NoError(t, err)
+	assert.Equal(t, `"$${var.x} %%{if}"`, s)
+
+	s, err = hclString([]string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, `["a","b"]`, s)
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/oidc"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOIDCServerHandleToken(t *testing.T) {
+	server, err := NewOIDCServerImpl(8080, "password", filepath.Join(t.TempDir(), "oidc-keys.json"))
+	require.NoError(t, err)
+
+	requestToken, err := oidc.NewRequestToken("password", oidc.Claims{
+		Repository:  "octo-org/octo-repo",
+		Ref:         "refs/heads/main",
+		EventName:   "push",
+		Environment: "production",
+	}, time.Now())
+	require.NoError(t, err)
+
+	request := func(token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/token?audience=sts.amazonaws.com", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		server.handleToken(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, request("password").Code)
+
+	w := request(requestToken)
+	require.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Value string `json:"value"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+
+	keys, err := server.keySet()
+	require.NoError(t, err)
+	claims := &oidc.Claims{}
+	token, err := jwt.ParseWithClaims(response.Value, claims, func(token *jwt.Token) (interface{}, error) {
+		key, _ := keys.PublicKey(token.Header["kid"].(string), time.Now())
+		return key, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, keys.SigningKey(time.Now()).ID, token.Header["kid"])
+	assert.Equal(t, "repo:octo-org/octo-repo:environment:production", claims.Subject)
+	assert.Equal(t, "sts.amazonaws.com", claims.Audience)
+	assert.Equal(t, "http://localhost:8080", claims.Issuer)
+	assert.Equal(t, "refs/heads/main", claims.Ref)
+	assert.NotEmpty(t, claims.ID)
+}
+
+func TestOIDCServerReloadsRotatedKeys(t *testing.T) {
+	keysFile := filepath.Join(t.TempDir(), "oidc-keys.json")
+	server, err := NewOIDCServerImpl(8080, "password", keysFile)
+	require.NoError(t, err)
+
+	jwks := func() []oidc.JWK {
+		w := httptest.NewRecorder()
+		server.handleJWKS(w, httptest.NewRequest(http.MethodGet, "/.well-known/jwks", nil))
+		var response struct {
+			Keys []oidc.JWK `json:"keys"`
+		}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		return response.Keys
+	}
+
+	before := jwks()
+	require.Len(t, before, 1)
+
+	keys, err := oidc.LoadKeySet(keysFile)
+	require.NoError(t, err)
+	key, err := keys.Rotate(time.Now(), time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, keys.Save(keysFile))
+	// make sure the modification time changes on file systems with a coarse resolution
+	require.NoError(t, os.Chtimes(keysFile, time.Now(), time.Now().Add(time.Second)))
+
+	after := jwks()
+	require.Len(t, after, 2)
+	assert.Equal(t, key.ID, after[0].Kid)
+	assert.Equal(t, before[0].Kid, after[1].Kid)
+}
+
+func TestNewTunnel(t *testing.T) {
+	table := []struct {
+		provider  string
+		domain    string
+		issuerURL string
+		name      string
+		err       string
+	}{
+		{provider: "", name: "ngrok"},
+		{provider: "ngrok", domain: "oidc.ngrok.app", name: "ngrok"},
+		{provider: "cloudflared", name: "cloudflared"},
+		{provider: "cloudflared", domain: "oidc.example.com", err: "cloudflared quick tunnels don't support custom domains, use --issuer-url with a named tunnel instead"},
+		{provider: "local", name: "local"},
+		{issuerURL: "https://oidc.example.com/", name: "url"},
+		{provider: "ngrok", issuerURL: "https://oidc.example.com", err: "--issuer-url can't be combined with the 'ngrok' tunnel"},
+		{issuerURL: "oidc.example.com", err: "invalid issuer URL 'oidc.example.com'"},
+		{provider: "localtunnel", err: "unsupported tunnel: localtunnel. Supported: ngrok, cloudflared, local"},
+	}
+
+	for _, tt := range table {
+		tunnel, err := newTunnel(tt.provider, tt.domain, tt.issuerURL)
+		if tt.err != "" {
+			assert.EqualError(t, err, tt.err)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tt.name, tunnel.Name())
+	}
+
+	issuerURL, process, err := (&urlTunnel{url: "https://oidc.example.com"}).Start(8080)
+	require.NoError(t, err)
+	assert.Equal(t, "https://oidc.example.com", issuerURL)
+	assert.Nil(t, process)
+
+	issuerURL, _, err = (&localTunnel{}).Start(8080)
+	require.NoError(t, err)
+	assert.Equal(t, "http://host.docker.internal:8080", issuerURL)
+}
+
+func TestCheckOIDCHealth(t *testing.T) {
+	server, err := NewOIDCServerImpl(0, "password", filepath.Join(t.TempDir(), "oidc-keys.json"))
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", server.handleWellKnown)
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	server.issuer = ts.URL
+
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.NoError(t, err)
+
+	checks := checkOIDCHealth(&OIDCStatus{Port: port, Provider: "url", IssuerURL: ts.URL})
+	require.Len(t, checks, 2)
+	assert.NoError(t, checks[0].err)
+	assert.NoError(t, checks[1].err)
+
+	checks = checkOIDCHealth(&OIDCStatus{Port: port, Provider: "url", IssuerURL: ts.URL + "/other"})
+	require.Len(t, checks, 2)
+	assert.Error(t, checks[1].err)
+
+	checks = checkOIDCHealth(&OIDCStatus{Port: port, Provider: "local", IssuerURL: "http://host.docker.internal:8080"})
+	assert.Len(t, checks, 1)
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/oidc"
+)
+
+// tunnelStartTimeout is how long a tunnel gets to report its public URL
+var tunnelStartTimeout = 30 * time.Second
+
+// cloudflaredMetricsAddr serves the hostname of the cloudflared quick tunnel
+const cloudflaredMetricsAddr = "127.0.0.1:20241"
+
+// Tunnel exposes the local OIDC server at the issuer URL of its tokens
+type Tunnel interface {
+	// Name is the provider recorded in the OIDC status
+	Name() string
+	// Start exposes the port and returns the issuer URL, and the process serving it if there is one
+	Start(port int) (string, *os.Process, error)
+}
+
+// newTunnel returns the tunnel of the provider, an issuer URL selects an existing reverse proxy
+func newTunnel(provider string, domain string, issuerURL string) (Tunnel, error) {
+	if issuerURL != "" {
+		if provider != "" && provider != "url" {
+			return nil, fmt.Errorf("--issuer-url can't be combined with the '%s' tunnel", provider)
+		}
+		u, err := url.Parse(issuerURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, fmt.Errorf("invalid issuer URL '%s'", issuerURL)
+		}
+		return &urlTunnel{url: strings.TrimSuffix(issuerURL, "/")}, nil
+	}
+
+	switch provider {
+	case "", "ngrok":
+		return &ngrokTunnel{domain: domain}, nil
+	case "cloudflared":
+		if domain != "" {
+			return nil, fmt.Errorf("cloudflared quick tunnels don't support custom domains, use --issuer-url with a named tunnel instead")
+		}
+		return &cloudflaredTunnel{}, nil
+	case "local":
+		return &localTunnel{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported tunnel: %s. Supported: ngrok, cloudflared, local", provider)
+	}
+}
+
+type ngrokTunnel struct {
+	domain string
+}
+
+func (t *ngrokTunnel) Name() string {
+	return "ngrok"
+}
+
+func (t *ngrokTunnel) Start(port int) (string, *os.Process, error) {
+	// Build ngrok command with optional domain
+	var ngrokCmd *exec.Cmd
+	if t.domain != "" {
+		ngrokCmd = exec.Command("ngrok", "http", strconv.Itoa(port), "--domain", t.domain)
+		fmt.Printf("Starting ngrok with custom domain: %s\n", t.domain)
+	} else {
+		ngrokCmd = exec.Command("ngrok", "http", strconv.Itoa(port))
+		fmt.Println("Starting ngrok with random domain")
+	}
+
+	if err := ngrokCmd.Start(); err != nil {
+		return "", nil, fmt.Errorf("failed to start ngrok: %w", err)
+	}
+
+	ngrokURL, err := waitForTunnelURL(getNgrokURL)
+	if err != nil {
+		_ = ngrokCmd.Process.Kill()
+		return "", nil, fmt.Errorf("failed to get ngrok URL: %w", err)
+	}
+	return ngrokURL, ngrokCmd.Process, nil
+}
+
+func getNgrokURL() (string, error) {
+	var response struct {
+		Tunnels []struct {
+			PublicURL string `json:"public_url"`
+		} `json:"tunnels"`
+	}
+	if err := getJSON("http://localhost:4040/api/tunnels", &response); err != nil {
+		return "", err
+	}
+	for _, tunnel := range response.Tunnels {
+		if strings.HasPrefix(tunnel.PublicURL, "https://") {
+			return tunnel.PublicURL, nil
+		}
+	}
+	if len(response.Tunnels) > 0 {
+		return response.Tunnels[0].PublicURL, nil
+	}
+	return "", fmt.Errorf("no tunnels found")
+}
+
+type cloudflaredTunnel struct{}
+
+func (t *cloudflaredTunnel) Name() string {
+	return "cloudflared"
+}
+
+func (t *cloudflaredTunnel) Start(port int) (string, *os.Process, error) {
+	fmt.Println("Starting cloudflared quick tunnel")
+	cloudflaredCmd := exec.Command("cloudflared", "tunnel", "--no-autoupdate",
+		"--url", fmt.Sprintf("http://localhost:%d", port),
+		"--metrics", cloudflaredMetricsAddr)
+	if err := cloudflaredCmd.Start(); err != nil {
+		return "", nil, fmt.Errorf("failed to start cloudflared: %w", err)
+	}
+
+	tunnelURL, err := waitForTunnelURL(func() (string, error) {
+		var response struct {
+			Hostname string `json:"hostname"`
+		}
+		if err := getJSON("http://"+cloudflaredMetricsAddr+"/quicktunnel", &response); err != nil {
+			return "", err
+		}
+		if response.Hostname == "" {
+			return "", fmt.Errorf("no quick tunnel found")
+		}
+		return "https://" + response.Hostname, nil
+	})
+	if err != nil {
+		_ = cloudflaredCmd.Process.Kill()
+		return "", nil, fmt.Errorf("failed to get cloudflared URL: %w", err)
+	}
+	return tunnelURL, cloudflaredCmd.Process, nil
+}
+
+// urlTunnel is a reverse proxy the user runs, forwarding the issuer URL to the OIDC server
+type urlTunnel struct {
+	url string
+}
+
+func (t *urlTunnel) Name() string {
+	return "url"
+}
+
+func (t *urlTunnel) Start(port int) (string, *os.Process, error) {
+	fmt.Printf("Using issuer URL %s, make sure it forwards to port %d\n", t.url, port)
+	return t.url, nil, nil
+}
+
+// localTunnel doesn't expose the OIDC server, the job containers and their services reach it
+// over the docker network, e.g. for a Vault dev server or LocalStack STS
+type localTunnel struct{}
+
+func (t *localTunnel) Name() string {
+	return "local"
+}
+
+func (t *localTunnel) Start(port int) (string, *os.Process, error) {
+	fmt.Println("Running local-only, the issuer is only reachable from job containers")
+	return fmt.Sprintf("http://%s:%d", oidc.LocalIssuerHost, port), nil, nil
+}
+
+func waitForTunnelURL(getURL func() (string, error)) (string, error) {
+	deadline := time.Now().Add(tunnelStartTimeout)
+	for {
+		tunnelURL, err := getURL()
+		if err == nil {
+			return tunnelURL, nil
+		}
+		if time.Now().After(deadline) {
+			return "", err
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+func getJSON(u string, v interface{}) error {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
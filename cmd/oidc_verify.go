@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+	"github.com/Leapfrog-DevOps/gha/pkg/oidc"
+	"github.com/Leapfrog-DevOps/gha/pkg/runner"
+)
+
+type oidcVerifyInput struct {
+	policy    string
+	job       string
+	event     string
+	audience  string
+	issuerURL string
+	keysFile  string
+}
+
+func createOIDCVerifyCommand(input *Input) *cobra.Command {
+	verifyInput := &oidcVerifyInput{}
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Evaluate a trust policy against the OIDC token of a job",
+		Long: `Mints the OIDC token a job would receive, decodes it and evaluates an AWS trust policy
+against it, printing which conditions pass and which fail.
+
+Examples:
+  gha oidc verify --policy trust.json
+  gha oidc verify --policy trust.json --job deploy --event workflow_dispatch`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if verifyInput.keysFile == "" {
+				keysFile, err := getOIDCKeysFile()
+				if err != nil {
+					return err
+				}
+				verifyInput.keysFile = keysFile
+			}
+			if verifyInput.issuerURL == "" {
+				if status, err := loadOIDCStatus(); err == nil && status.Running {
+					verifyInput.issuerURL = status.IssuerURL
+				}
+			}
+			return verifyOIDCPolicy(cmd.Context(), input, verifyInput, os.Stdout)
+		},
+	}
+
+	cmd.Flags().StringVar(&verifyInput.policy, "policy", "", "Trust policy to evaluate, or the output of 'gha oidc setup --provider aws -o json'")
+	cmd.Flags().StringVarP(&verifyInput.job, "job", "j", "", "Job to mint the token of, required if the event triggers several jobs")
+	cmd.Flags().StringVar(&verifyInput.event, "event", "push", "Event triggering the job")
+	cmd.Flags().StringVar(&verifyInput.audience, "audience", "sts.amazonaws.com", "Audience requested by the job")
+	cmd.Flags().StringVar(&verifyInput.issuerURL, "issuer-url", "", "Issuer of the token (default is the issuer of the running OIDC server)")
+	cmd.Flags().StringVarP(&input.eventPath, "eventpath", "e", "", "path to event JSON file")
+	_ = cmd.MarkFlagRequired("policy")
+
+	return cmd
+}
+
+// verifyOIDCPolicy mints the token of the job and evaluates the trust policy against it
+func verifyOIDCPolicy(ctx context.Context, input *Input, v *oidcVerifyInput, out io.Writer) error {
+	if v.issuerURL == "" {
+		return fmt.Errorf("no OIDC server is running, start one with 'gha oidc start' or set the issuer with --issuer-url")
+	}
+	data, err := os.ReadFile(v.policy)
+	if err != nil {
+		return err
+	}
+	// the json output of 'gha oidc setup' holds the trust policy with the rest of the setup
+	var setup struct {
+		TrustPolicy json.RawMessage `json:"trust_policy"`
+	}
+	if json.Unmarshal(data, &setup) == nil && len(setup.TrustPolicy) > 0 {
+		data = setup.TrustPolicy
+	}
+	policy, err := oidc.ParseTrustPolicy(data)
+	if err != nil {
+		return err
+	}
+
+	run, err := planOIDCVerifyJob(input, v)
+	if err != nil {
+		return err
+	}
+	vars := map[string]string{}
+	_ = readEnvs(input.Varfile(), vars)
+	envs := map[string]string{}
+	_ = readEnvs(input.Envfile(), envs)
+	claims, err := runner.OIDCClaims(ctx, &runner.Config{
+		Actor:           input.actor,
+		EventName:       v.event,
+		EventPath:       input.EventPath(),
+		Workdir:         input.Workdir(),
+		Env:             envs,
+		Vars:            vars,
+		Secrets:         map[string]string{},
+		GitHubInstance:  input.githubInstance,
+		RemoteName:      input.remoteName,
+		EnvironmentVars: readEnvironmentFiles(input.Varfile(), false),
+	}, run)
+	if err != nil {
+		return err
+	}
+
+	// mint and decode the token the way the OIDC server and the cloud provider do
+	server, err := NewOIDCServerImpl(0, "", v.keysFile)
+	if err != nil {
+		return err
+	}
+	server.issuer = strings.TrimSuffix(v.issuerURL, "/")
+	token, err := server.mintToken(&claims, v.audience)
+	if err != nil {
+		return err
+	}
+	decoded := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(token, decoded, func(t *jwt.Token) (interface{}, error) {
+		keys, err := server.keySet()
+		if err != nil {
+			return nil, err
+		}
+		kid, _ := t.Header["kid"].(string)
+		key, ok := keys.PublicKey(kid, time.Now())
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key '%s'", kid)
+		}
+		return key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Name}), jwt.WithJSONNumber()); err != nil {
+		return fmt.Errorf("unable to decode the minted token: %w", err)
+	}
+
+	fmt.Fprintf(out, "Token of job '%s':\n", run.JobID)
+	names := make([]string, 0, len(decoded))
+	for name := range decoded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-22s %v\n", name+":", decoded[name])
+	}
+
+	evaluation := policy.Evaluate(server.issuer, decoded)
+	for _, statement := range evaluation.Statements {
+		title := fmt.Sprintf("Statement %d", statement.Index)
+		if statement.Sid != "" {
+			title = fmt.Sprintf("Statement '%s'", statement.Sid)
+		}
+		fmt.Fprintf(out, "\n%s (%s):\n", title, statement.Effect)
+		if statement.Reason != "" {
+			fmt.Fprintf(out, "  ⏭️  doesn't apply, %s\n", statement.Reason)
+			continue
+		}
+		for _, condition := range statement.Conditions {
+			mark := "✅"
+			if !condition.Passed {
+				mark = "❌"
+			}
+			fmt.Fprintf(out, "  %s %s %s %v", mark, condition.Operator, condition.Key, condition.Values)
+			if condition.Reason != "" {
+				fmt.Fprintf(out, ": %s", condition.Reason)
+			}
+			fmt.Fprintln(out)
+		}
+	}
+	fmt.Fprintln(out)
+
+	if !evaluation.Allowed {
+		return fmt.Errorf("the trust policy doesn't allow job '%s' to assume the role", run.JobID)
+	}
+	fmt.Fprintf(out, "✅ The trust policy allows job '%s' to assume the role\n", run.JobID)
+	return nil
+}
+
+// planOIDCVerifyJob returns the run of the job to mint the token of
+func planOIDCVerifyJob(input *Input, v *oidcVerifyInput) (*model.Run, error) {
+	planner, err := model.NewWorkflowPlanner(input.WorkflowsPath(), input.noWorkflowRecurse, input.strict)
+	if err != nil {
+		return nil, err
+	}
+	var plan *model.Plan
+	if v.job != "" {
+		plan, err = planner.PlanJob(v.job)
+	} else {
+		plan, err = planner.PlanEvent(v.event)
+	}
+	if plan == nil {
+		return nil, err
+	}
+
+	var runs []*model.Run
+	for _, stage := range plan.Stages {
+		for _, run := range stage.Runs {
+			if v.job == "" || run.JobID == v.job {
+				runs = append(runs, run)
+			}
+		}
+	}
+	switch {
+	case len(runs) == 0 && v.job != "":
+		return nil, fmt.Errorf("unable to find job '%s'", v.job)
+	case len(runs) == 0:
+		return nil, fmt.Errorf("no job is triggered by the '%s' event", v.event)
+	case len(runs) > 1:
+		jobs := make([]string, 0, len(runs))
+		for _, run := range runs {
+			jobs = append(jobs, run.JobID)
+		}
+		sort.Strings(jobs)
+		if v.job != "" {
+			return nil, fmt.Errorf("several workflows have a job '%s', select the workflow with --workflows", v.job)
+		}
+		return nil, fmt.Errorf("the '%s' event triggers several jobs (%s), select one with --job", v.event, strings.Join(jobs, ", "))
+	}
+	log.Debugf("Minting the OIDC token of job '%s' in %s", runs[0].JobID, runs[0].Workflow.File)
+	return runs[0], nil
+}
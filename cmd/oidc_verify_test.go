@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyOIDCPolicy(t *testing.T) {
+	dir := t.TempDir()
+	workflows := filepath.Join(dir, ".github", "workflows")
+	require.NoError(t, os.MkdirAll(workflows, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(workflows, "deploy.yml"), []byte(`on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - run: echo build
+  deploy:
+    runs-on: ubuntu-latest
+    environment: ${{ vars.ENVIRONMENT }}
+    steps:
+      - run: echo deploy
+`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".vars"), []byte("ENVIRONMENT=production\n"), 0o600))
+	policy := filepath.Join(dir, "trust.json")
+	require.NoError(t, os.WriteFile(policy, []byte(`{
+  "Version": "2012-10-17",
+  "Statement": [{
+    "Effect": "Allow",
+    "Principal": {"Federated": "arn:aws:iam::ACCOUNT_ID:oidc-provider/oidc.example.com"},
+    "Action": "sts:AssumeRoleWithWebIdentity",
+    "Condition": {
+      "StringEquals": {"oidc.example.com:aud": "sts.amazonaws.com"},
+      "StringLike": {"oidc.example.com:sub": "repo:*:environment:production"}
+    }
+  }]
+}`), 0o600))
+
+	input := &Input{workdir: dir, workflowsPath: ".github/workflows", varfile: ".vars", envfile: ".env", actor: "octocat"}
+	verifyInput := &oidcVerifyInput{
+		policy:    policy,
+		event:     "push",
+		audience:  "sts.amazonaws.com",
+		issuerURL: "https://oidc.example.com",
+		keysFile:  filepath.Join(dir, "oidc-keys.json"),
+	}
+
+	out := &bytes.Buffer{}
+	err := verifyOIDCPolicy(context.Background(), input, verifyInput, out)
+	assert.EqualError(t, err, "the 'push' event triggers several jobs (build, deploy), select one with --job")
+
+	verifyInput.job = "deploy"
+	require.NoError(t, verifyOIDCPolicy(context.Background(), input, verifyInput, out))
+	assert.Contains(t, out.String(), "environment:           production")
+	assert.Contains(t, out.String(), "✅ The trust policy allows job 'deploy' to assume the role")
+
+	out.Reset()
+	verifyInput.job = "build"
+	err = verifyOIDCPolicy(context.Background(), input, verifyInput, out)
+	assert.EqualError(t, err, "the trust policy doesn't allow job 'build' to assume the role")
+	assert.Contains(t, out.String(), "❌ StringLike oidc.example.com:sub [repo:*:environment:production]: 'sub' is [repo:")
+
+	// the json output of the setup
+	setup := filepath.Join(dir, "gha-oidc-aws.json")
+	data, err := os.ReadFile(policy)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(setup, []byte(`{"provider": "aws", "trust_policy": `+string(data)+`}`), 0o600))
+	out.Reset()
+	verifyInput.policy = setup
+	verifyInput.job = "deploy"
+	verifyInput.audience = "api://AzureADTokenExchange"
+	err = verifyOIDCPolicy(context.Background(), input, verifyInput, out)
+	assert.Error(t, err)
+	assert.Contains(t, out.String(), "❌ StringEquals oidc.example.com:aud [sts.amazonaws.com]: 'aud' is [api://AzureADTokenExchange], which doesn't match [sts.amazonaws.com]")
+}
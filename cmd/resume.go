@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/history"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+func createResumeCommand(ctx context.Context, input *Input, rootCmd *cobra.Command) *cobra.Command {
+	resumeCmd := &cobra.Command{
+		Use:   "resume [run number] [flags]",
+		Short: "Resume a failed run of the history from its failed jobs",
+		Long: `Run the failed jobs of a run of the history and the jobs that need them again, by default of the
+last run in the working directory. The jobs that succeeded don't run again, the jobs that need them
+get their recorded outputs. With --from-step, the failed job restarts at the step in its container
+kept with --reuse.
+
+Examples:
+  gha resume
+  gha resume 3
+  gha resume --reuse --from-step test:integration`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if input.fromStep != "" {
+				if job, step, ok := strings.Cut(input.fromStep, ":"); !ok || job == "" || step == "" {
+					return fmt.Errorf("invalid --from-step '%s', expected <job-id>:<step-id>", input.fromStep)
+				}
+			}
+			run, err := getResumedRun(historyStore(input), input.Workdir(), args)
+			if err != nil {
+				return err
+			}
+			log.Infof("⏯  Resuming run #%d of the event %s", run.Number, run.Event)
+			input.resumeRun = run
+			return newRunCommand(ctx, input)(cmd, []string{run.Event})
+		},
+		SilenceUsage: true,
+	}
+	// the run flags of gha are the flags of the resume command
+	resumeCmd.Flags().AddFlagSet(rootCmd.Flags())
+	resumeCmd.Flags().StringVar(&input.fromStep, "from-step", "", "restart the failed job at the step in its container kept with --reuse (e.g. --from-step test:integration)")
+	return resumeCmd
+}
+
+// getResumedRun returns the run of the history with the number of the args, by default the last run in the workdir
+func getResumedRun(store *history.Store, workdir string, args []string) (*history.Run, error) {
+	var run *history.Run
+	if len(args) > 0 {
+		var err error
+		if run, err = getHistoryRun(store, args[0]); err != nil {
+			return nil, err
+		}
+	} else {
+		runs, err := store.List()
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range runs {
+			if r.Workdir == workdir {
+				run = r
+				break
+			}
+		}
+		if run == nil {
+			return nil, fmt.Errorf("no run in %s recorded in the history", workdir)
+		}
+	}
+	if run.Conclusion == "success" {
+		return nil, fmt.Errorf("run #%d succeeded, there is nothing to resume", run.Number)
+	}
+	return run, nil
+}
+
+// resumePlan returns the plan of the jobs of the resumed run
+func resumePlan(plan *model.Plan, run *history.Run) *model.Plan {
+	resumed := &model.Plan{Skipped: plan.Skipped}
+	for _, stage := range plan.Stages {
+		runs := []*model.Run{}
+		for _, r := range stage.Runs {
+			planned := slices.ContainsFunc(run.Plan, func(jobIDs []string) bool {
+				return slices.Contains(jobIDs, r.JobID)
+			})
+			if planned && slices.Contains(run.Workflows, r.Workflow.Name) {
+				runs = append(runs, r)
+			}
+		}
+		if len(runs) > 0 {
+			resumed.Stages = append(resumed.Stages, &model.Stage{Runs: runs})
+		}
+	}
+	return resumed
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/history"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+func TestGetResumedRun(t *testing.T) {
+	store := &history.Store{Dir: t.TempDir()}
+	failed := newTestHistoryRun()
+	require.NoError(t, store.Create(failed))
+	other := newTestHistoryRun()
+	other.Workdir = "/home/octocat/other"
+	require.NoError(t, store.Create(other))
+
+	// the last run in the workdir
+	run, err := getResumedRun(store, "/home/octocat/repo", nil)
+	require.NoError(t, err)
+	assert.Equal(t, failed.Number, run.Number)
+
+	_, err = getResumedRun(store, "/home/octocat/new", nil)
+	assert.ErrorContains(t, err, "no run in /home/octocat/new recorded in the history")
+
+	succeeded := newTestHistoryRun()
+	succeeded.Conclusion = "success"
+	require.NoError(t, store.Create(succeeded))
+	_, err = getResumedRun(store, "/home/octocat/repo", nil)
+	assert.ErrorContains(t, err, "run #3 succeeded, there is nothing to resume")
+
+	run, err = getResumedRun(store, "/home/octocat/repo", []string{"#1"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, run.Number)
+}
+
+func TestResumePlan(t *testing.T) {
+	ci := &model.Workflow{Name: "CI"}
+	release := &model.Workflow{Name: "Release"}
+	plan := &model.Plan{Stages: []*model.Stage{
+		{Runs: []*model.Run{{Workflow: ci, JobID: "build"}, {Workflow: release, JobID: "build"}, {Workflow: ci, JobID: "lint"}}},
+		{Runs: []*model.Run{{Workflow: release, JobID: "publish"}}},
+		{Runs: []*model.Run{{Workflow: ci, JobID: "deploy"}}},
+	}}
+
+	// the jobs that weren't planned in the resumed run don't run
+	resumed := resumePlan(plan, newTestHistoryRun())
+	require.Len(t, resumed.Stages, 2)
+	assert.Equal(t, []*model.Run{{Workflow: ci, JobID: "build"}}, resumed.Stages[0].Runs)
+	assert.Equal(t, []*model.Run{{Workflow: ci, JobID: "deploy"}}, resumed.Stages[1].Runs)
+}
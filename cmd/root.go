@@ -14,6 +14,8 @@ import (
 	"runtime"
 	"runtime/debug"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/adrg/xdg"
@@ -25,15 +27,20 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/cobra/doc"
 	"github.com/spf13/pflag"
+	"golang.org/x/term"
 	"gopkg.in/yaml.v3"
 
 	"github.com/Leapfrog-DevOps/gha/pkg/artifactcache"
 	"github.com/Leapfrog-DevOps/gha/pkg/artifacts"
 	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/common/git"
 	"github.com/Leapfrog-DevOps/gha/pkg/container"
+	runevents "github.com/Leapfrog-DevOps/gha/pkg/events"
 	"github.com/Leapfrog-DevOps/gha/pkg/gh"
 	"github.com/Leapfrog-DevOps/gha/pkg/model"
 	"github.com/Leapfrog-DevOps/gha/pkg/runner"
+	"github.com/Leapfrog-DevOps/gha/pkg/tracing"
+	"github.com/Leapfrog-DevOps/gha/pkg/tui"
 )
 
 func generateRequestToken() string {
@@ -98,6 +105,7 @@ func createRootCommand(ctx context.Context, input *Input, version string) *cobra
 	rootCmd.Flags().BoolVarP(&input.autodetectEvent, "detect-event", "", false, "Use first event type from workflow as event that triggered the workflow")
 	rootCmd.Flags().StringVarP(&input.eventPath, "eventpath", "e", "", "path to event JSON file")
 	rootCmd.Flags().StringVar(&input.defaultBranch, "defaultbranch", "", "the name of the main branch")
+	rootCmd.Flags().StringVar(&input.base, "base", "", "git ref to compute the changed files against for the paths filters of workflows (e.g. --base origin/main)")
 	rootCmd.Flags().BoolVar(&input.privileged, "privileged", false, "use privileged mode")
 	rootCmd.Flags().StringVar(&input.usernsMode, "userns", "", "user namespace to use")
 	rootCmd.Flags().BoolVar(&input.useGitIgnore, "use-gitignore", true, "Controls whether paths specified in .gitignore should be copied into container")
@@ -107,6 +115,27 @@ func createRootCommand(ctx context.Context, input *Input, version string) *cobra
 	rootCmd.Flags().StringArrayVarP(&input.replaceGheActionWithGithubCom, "replace-ghe-action-with-github-com", "", []string{}, "If you are using GitHub Enterprise Server and allow specified actions from GitHub (github.com), you can set actions on this. (e.g. --replace-ghe-action-with-github-com =github/super-linter)")
 	rootCmd.Flags().StringVar(&input.replaceGheActionTokenWithGithubCom, "replace-ghe-action-token-with-github-com", "", "If you are using replace-ghe-action-with-github-com  and you want to use private actions on GitHub, you have to set personal access token")
 	rootCmd.Flags().StringArrayVarP(&input.matrix, "matrix", "", []string{}, "specify which matrix configuration to include (e.g. --matrix java:13")
+	rootCmd.Flags().StringArrayVar(&input.environmentApprovals, "environment-approval", []string{}, "require an interactive approval before jobs deploy to the environment (e.g. --environment-approval production)")
+	rootCmd.Flags().StringArrayVar(&input.environmentWaitTimers, "environment-wait-timer", []string{}, "wait before jobs deploy to the environment (e.g. --environment-wait-timer production=5m)")
+	rootCmd.Flags().StringVar(&input.reportDir, "report-dir", "", "directory to write the job summaries of GITHUB_STEP_SUMMARY to as summary.md and summary.html")
+	rootCmd.Flags().StringArrayVar(&input.reports, "report", []string{}, "export the results of the run at its end (e.g. --report junit=results.xml --report sarif=annotations.sarif)")
+	rootCmd.Flags().BoolVar(&input.noHistory, "no-history", false, "don't record the run in the history of `gha history`")
+	rootCmd.Flags().StringVar(&input.events, "events", "", "write the lifecycle events of the run as NDJSON to the file or the file descriptor (e.g. --events events.ndjson or --events 3)")
+	rootCmd.Flags().StringVar(&input.jobStartedHook, "job-started-hook", "", "script to run in the job container before the steps of every job, defaults to the ACTIONS_RUNNER_HOOK_JOB_STARTED of --env or the env file")
+	rootCmd.Flags().StringVar(&input.jobCompletedHook, "job-completed-hook", "", "script to run in the job container after the steps of every job, defaults to the ACTIONS_RUNNER_HOOK_JOB_COMPLETED of --env or the env file")
+	rootCmd.Flags().StringArrayVar(&input.breakBefore, "break-before", []string{}, "pause the run before the step with the ID to debug it in an interactive terminal, '*' for every step (e.g. --break-before build)")
+	rootCmd.Flags().BoolVar(&input.breakOnFailure, "break-on-failure", false, "pause the run after a step failed to debug it in an interactive terminal")
+	rootCmd.Flags().BoolVar(&input.noNeeds, "no-needs", false, "run the job of --job without the jobs it needs, their result and outputs in the needs context are stubbed")
+	rootCmd.Flags().StringArrayVar(&input.needsOutputs, "needs-output", []string{}, "stub an output of a needed job that doesn't run with --no-needs (e.g. --needs-output build.version=1.2.3)")
+	rootCmd.Flags().StringArrayVar(&input.needsResults, "needs-result", []string{}, "stub the result of a needed job that doesn't run with --no-needs, success by default (e.g. --needs-result build=failure)")
+	rootCmd.Flags().StringVar(&input.needsFile, "needs-file", "", "JSON file with the result and outputs of the needed jobs that don't run with --no-needs, in the shape of the needs context")
+	rootCmd.Flags().StringArrayVar(&input.skipSteps, "skip-step", []string{}, "don't run the step of the job, the step is skipped (e.g. --skip-step deploy.apply)")
+	rootCmd.Flags().StringSliceVar(&input.onlySteps, "only-steps", []string{}, "run only these steps in their jobs, the other steps of the jobs are skipped (e.g. --only-steps test.setup,test.unit)")
+	rootCmd.Flags().StringArrayVar(&input.mockSteps, "mock-step", []string{}, "set the outputs of the JSON file and the outcome of the step of the job instead of running it (e.g. --mock-step deploy.plan=outputs.json,outcome=failure)")
+	rootCmd.Flags().StringVar(&input.mocksFile, "mocks-file", ".github/gha-mocks.yml", "file mapping the uses patterns of the actions to a local action, outputs or a no-op substituting them")
+	rootCmd.Flags().BoolVar(&input.noMocks, "no-mocks", false, "run the actions mocked in the file of --mocks-file")
+	rootCmd.Flags().BoolVar(&input.tui, "tui", false, "show a live dashboard of the stages, jobs and steps of the run with the log of the selected job, when the terminal is interactive and the run is not watched")
+	rootCmd.Flags().StringVar(&input.traceFile, "trace-file", "", "write the OpenTelemetry spans of the stages, jobs and steps of the run to the file as OTLP/JSON")
 	rootCmd.PersistentFlags().StringVarP(&input.actor, "actor", "a", "Leapfrog-DevOps/gha", "user that triggered the event")
 	rootCmd.PersistentFlags().StringVarP(&input.workflowsPath, "workflows", "W", "./.github/workflows/", "path to workflow file(s)")
 	rootCmd.PersistentFlags().BoolVarP(&input.noWorkflowRecurse, "no-recurse", "", false, "Flag to disable running workflows from subdirectories of specified path in '--workflows'/'-W' flag")
@@ -142,13 +171,23 @@ func createRootCommand(ctx context.Context, input *Input, version string) *cobra
 	rootCmd.PersistentFlags().BoolVar(&input.listOptions, "list-options", false, "Print a json structure of compatible options")
 	rootCmd.PersistentFlags().IntVar(&input.concurrentJobs, "concurrent-jobs", 0, "Maximum number of concurrent jobs to run. Default is the number of CPUs available.")
 	rootCmd.PersistentFlags().StringVarP(&input.domain, "domain", "d", "", "Custom ngrok domain to use for OIDC server (e.g. myapp.ngrok.io)")
+	rootCmd.PersistentFlags().StringVar(&input.historyDir, "history-dir", filepath.Join(StateHomeDir, "gha", "history"), "Defines the path where the runs are recorded for `gha history`.")
 
 	// Add OIDC command
-	rootCmd.AddCommand(createOIDCCommand())
+	rootCmd.AddCommand(createOIDCCommand(input))
 
 	// Add Actions command
 	rootCmd.AddCommand(createActionsCommand())
 
+	// Add History command
+	rootCmd.AddCommand(createHistoryCommand(input))
+
+	// Add Debug command
+	rootCmd.AddCommand(createDebugCommand(ctx, input, rootCmd))
+
+	// Add Resume command
+	rootCmd.AddCommand(createResumeCommand(ctx, input, rootCmd))
+
 	rootCmd.SetArgs(args())
 	return rootCmd
 }
@@ -397,6 +436,187 @@ func readEnvsEx(path string, envs map[string]string, caseInsensitive bool) bool
 	return false
 }
 
+// readEnvironmentFiles reads the secrets or vars of the deployment environment a job names from the file next to path,
+// e.g. .secrets.production, other files like .secrets.bak are never read
+func readEnvironmentFiles(path string, caseInsensitive bool) runner.EnvironmentValues {
+	var mu sync.Mutex
+	environments := map[string]map[string]string{}
+	return func(name string) map[string]string {
+		mu.Lock()
+		defer mu.Unlock()
+		if values, ok := environments[name]; ok {
+			return values
+		}
+		var values map[string]string
+		// the name must not point to a file elsewhere
+		if name != "" && !strings.ContainsAny(name, `/\`) && name != "." && name != ".." {
+			file := path + "." + name
+			values = map[string]string{}
+			if readEnvsEx(file, values, caseInsensitive) {
+				log.Debugf("Loaded environment '%s' from %s", name, file)
+			}
+		}
+		environments[name] = values
+		return values
+	}
+}
+
+func parseEnvironmentProtections(approvals []string, waitTimers []string) (map[string]runner.EnvironmentProtection, error) {
+	protections := map[string]runner.EnvironmentProtection{}
+	for _, name := range approvals {
+		protection := protections[name]
+		protection.RequiredReviewers = true
+		protections[name] = protection
+	}
+	for _, waitTimer := range waitTimers {
+		name, value, ok := strings.Cut(waitTimer, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid environment wait timer '%s', expected <environment>=<duration>", waitTimer)
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid environment wait timer '%s': %w", waitTimer, err)
+		}
+		protection := protections[name]
+		protection.WaitTimer = duration
+		protections[name] = protection
+	}
+	return protections, nil
+}
+
+// parseReports parses the reports of --report, e.g. junit=results.xml, resolving their paths
+func parseReports(reports []string, resolve func(string) string) ([]runner.Report, error) {
+	parsed := make([]runner.Report, 0, len(reports))
+	for _, report := range reports {
+		format, path, ok := strings.Cut(report, "=")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("invalid report '%s', expected <format>=<path>", report)
+		}
+		switch format {
+		case runner.ReportFormatJUnit, runner.ReportFormatSARIF:
+		default:
+			return nil, fmt.Errorf("invalid report '%s', the format must be %s or %s", report, runner.ReportFormatJUnit, runner.ReportFormatSARIF)
+		}
+		parsed = append(parsed, runner.Report{Format: format, Path: resolve(path)})
+	}
+	return parsed, nil
+}
+
+// parseNeedsStubs parses the stubbed needs of the JSON file of --needs-file, overridden by the outputs of
+// --needs-output, e.g. build.version=1.2.3, and the results of --needs-result, e.g. build=success
+func parseNeedsStubs(file string, outputs []string, results []string) (map[string]runner.NeedsStub, error) {
+	stubs := map[string]runner.NeedsStub{}
+	if file != "" {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(content, &stubs); err != nil {
+			return nil, fmt.Errorf("invalid needs file %s: %w", file, err)
+		}
+	}
+	for _, output := range outputs {
+		name, value, ok := strings.Cut(output, "=")
+		job, key, _ := strings.Cut(name, ".")
+		if !ok || job == "" || key == "" {
+			return nil, fmt.Errorf("invalid needs output '%s', expected <job-id>.<output>=<value>", output)
+		}
+		stub := stubs[job]
+		if stub.Outputs == nil {
+			stub.Outputs = map[string]string{}
+		}
+		stub.Outputs[key] = value
+		stubs[job] = stub
+	}
+	for _, result := range results {
+		job, value, ok := strings.Cut(result, "=")
+		if !ok || job == "" {
+			return nil, fmt.Errorf("invalid needs result '%s', expected <job-id>=<result>", result)
+		}
+		stub := stubs[job]
+		stub.Result = value
+		stubs[job] = stub
+	}
+	for job, stub := range stubs {
+		switch stub.Result {
+		case "", "success", "failure", "cancelled", "skipped":
+		default:
+			return nil, fmt.Errorf("invalid result '%s' of the needed job '%s', the result must be success, failure, cancelled or skipped", stub.Result, job)
+		}
+	}
+	return stubs, nil
+}
+
+// parseStepRefs checks the steps of --skip-step and --only-steps are <job-id>.<step-id>
+func parseStepRefs(flag string, refs []string) ([]string, error) {
+	for _, ref := range refs {
+		if job, step, ok := strings.Cut(ref, "."); !ok || job == "" || step == "" {
+			return nil, fmt.Errorf("invalid %s '%s', expected <job-id>.<step-id>", flag, ref)
+		}
+	}
+	return refs, nil
+}
+
+// parseStepMocks parses the mocked steps of --mock-step, e.g. deploy.plan=outputs.json,outcome=failure, resolving
+// the paths of the JSON files of their outputs
+func parseStepMocks(mocks []string, resolve func(string) string) (map[string]runner.StepMock, error) {
+	parsed := map[string]runner.StepMock{}
+	for _, m := range mocks {
+		ref, value, ok := strings.Cut(m, "=")
+		job, step, _ := strings.Cut(ref, ".")
+		if !ok || job == "" || step == "" {
+			return nil, fmt.Errorf("invalid mocked step '%s', expected <job-id>.<step-id>=[<outputs.json>][,outcome=<outcome>]", m)
+		}
+		mock := runner.StepMock{}
+		for _, option := range strings.Split(value, ",") {
+			if outcome, ok := strings.CutPrefix(option, "outcome="); ok {
+				if outcome != "success" && outcome != "failure" {
+					return nil, fmt.Errorf("invalid mocked step '%s', the outcome must be success or failure", m)
+				}
+				mock.Outcome = outcome
+			} else if option != "" {
+				content, err := os.ReadFile(resolve(option))
+				if err != nil {
+					return nil, err
+				}
+				var outputs map[string]interface{}
+				if err := json.Unmarshal(content, &outputs); err != nil {
+					return nil, fmt.Errorf("invalid outputs of the mocked step '%s' in %s: %w", ref, option, err)
+				}
+				// the outputs of steps are strings, the other values are their JSON
+				mock.Outputs = map[string]string{}
+				for k, v := range outputs {
+					if s, ok := v.(string); ok {
+						mock.Outputs[k] = s
+					} else {
+						j, _ := json.Marshal(v)
+						mock.Outputs[k] = string(j)
+					}
+				}
+			}
+		}
+		parsed[ref] = mock
+	}
+	return parsed, nil
+}
+
+// newDeploymentApprover asks for the approval of protected deployments on the terminal, one job at a time
+func newDeploymentApprover() runner.DeploymentApprover {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil
+	}
+	var mu sync.Mutex
+	return func(_ context.Context, job string, environment string) (bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		approved := false
+		err := survey.AskOne(&survey.Confirm{
+			Message: fmt.Sprintf("Approve the deployment of job '%s' to environment '%s'?", job, environment),
+		}, &approved)
+		return approved, err
+	}
+}
+
 func parseMatrix(matrix []string) map[string]map[string]bool {
 	// each matrix entry should be of the form - string:string
 	r := regexp.MustCompile(":")
@@ -414,6 +634,78 @@ func parseMatrix(matrix []string) map[string]map[string]bool {
 	return matrixes
 }
 
+// eventFilterPlanner is a planner evaluating the `on.<event>` filters, like the planners of model.NewWorkflowPlanner
+type eventFilterPlanner interface {
+	SetEventFilter(filter *model.EventFilter)
+}
+
+// noNeedsPlanner is a planner running a job without the jobs it needs, like the planners of model.NewWorkflowPlanner
+type noNeedsPlanner interface {
+	PlanJobWithoutNeeds(jobName string) (*model.Plan, error)
+}
+
+// newEventFilter collects the git ref and the changed files the `on.<event>` filters of the workflows are evaluated against
+func newEventFilter(ctx context.Context, input *Input) *model.EventFilter {
+	var payload struct {
+		Ref         string `json:"ref"`
+		Before      string `json:"before"`
+		After       string `json:"after"`
+		PullRequest struct {
+			Base struct {
+				Ref string `json:"ref"`
+				Sha string `json:"sha"`
+			} `json:"base"`
+			Head struct {
+				Sha string `json:"sha"`
+			} `json:"head"`
+		} `json:"pull_request"`
+	}
+	if input.eventPath != "" {
+		if content, err := os.ReadFile(input.EventPath()); err == nil {
+			if err := json.Unmarshal(content, &payload); err != nil {
+				log.Warnf("Unable to parse event payload '%s': %v", input.EventPath(), err)
+			}
+		}
+	}
+
+	filter := &model.EventFilter{
+		Ref:     payload.Ref,
+		BaseRef: payload.PullRequest.Base.Ref,
+	}
+	if filter.Ref == "" {
+		ref, err := git.FindGitRef(ctx, input.Workdir())
+		if err != nil {
+			log.Debugf("Unable to determine the git ref for the event filters: %v", err)
+		}
+		filter.Ref = ref
+	}
+	if filter.BaseRef == "" && input.base != "" {
+		filter.BaseRef = strings.TrimPrefix(input.base, input.remoteName+"/")
+	}
+
+	var from, to string
+	mergeBase := false
+	switch {
+	case input.base != "":
+		from, to, mergeBase = input.base, "HEAD", true
+	case payload.PullRequest.Base.Sha != "" && payload.PullRequest.Head.Sha != "":
+		from, to, mergeBase = payload.PullRequest.Base.Sha, payload.PullRequest.Head.Sha, true
+	case strings.Trim(payload.Before, "0") != "" && payload.After != "":
+		// a before SHA of only zeros means the branch was created, there is no previous revision to compare to
+		from, to = payload.Before, payload.After
+	}
+	if from != "" {
+		files, err := git.FindChangedFiles(ctx, input.Workdir(), from, to, mergeBase)
+		if err != nil {
+			log.Warnf("Unable to determine the changed files, ignoring the paths filters: %v", err)
+		} else {
+			log.Debugf("Changed files: %v", files)
+			filter.ChangedFiles = files
+		}
+	}
+	return filter
+}
+
 //nolint:gocyclo
 func newRunCommand(ctx context.Context, input *Input) func(*cobra.Command, []string) error {
 	return func(cmd *cobra.Command, args []string) error {
@@ -474,6 +766,46 @@ func newRunCommand(ctx context.Context, input *Input) func(*cobra.Command, []str
 		vars := newSecrets(input.vars)
 		_ = readEnvs(input.Varfile(), vars)
 
+		environmentSecrets := readEnvironmentFiles(input.Secretfile(), true)
+		environmentVars := readEnvironmentFiles(input.Varfile(), false)
+		environmentProtections, err := parseEnvironmentProtections(input.environmentApprovals, input.environmentWaitTimers)
+		if err != nil {
+			return err
+		}
+		reports, err := parseReports(input.reports, input.resolve)
+		if err != nil {
+			return err
+		}
+
+		needsFile := ""
+		if input.needsFile != "" {
+			needsFile = input.resolve(input.needsFile)
+		}
+		needsStubs, err := parseNeedsStubs(needsFile, input.needsOutputs, input.needsResults)
+		if err != nil {
+			return err
+		}
+
+		skipSteps, err := parseStepRefs("--skip-step", input.skipSteps)
+		if err != nil {
+			return err
+		}
+		onlySteps, err := parseStepRefs("--only-steps", input.onlySteps)
+		if err != nil {
+			return err
+		}
+		mockSteps, err := parseStepMocks(input.mockSteps, input.resolve)
+		if err != nil {
+			return err
+		}
+
+		var actionMocks []runner.ActionMock
+		if !input.noMocks {
+			if actionMocks, err = readActionMocks(input.MocksFile(), cmd.Flags().Changed("mocks-file")); err != nil {
+				return err
+			}
+		}
+
 		matrixes := parseMatrix(input.matrix)
 		log.Debugf("Evaluated matrix inclusions: %v", matrixes)
 
@@ -481,11 +813,28 @@ func newRunCommand(ctx context.Context, input *Input) func(*cobra.Command, []str
 		if err != nil {
 			return err
 		}
+		if p, ok := planner.(eventFilterPlanner); ok {
+			p.SetEventFilter(newEventFilter(ctx, input))
+		}
 
 		jobID, err := cmd.Flags().GetString("job")
 		if err != nil {
 			return err
 		}
+		if input.noNeeds && jobID == "" {
+			return fmt.Errorf("--no-needs requires the job to run with --job")
+		}
+		if !input.noNeeds && (len(input.needsOutputs) > 0 || len(input.needsResults) > 0 || input.needsFile != "") {
+			return fmt.Errorf("--needs-output, --needs-result and --needs-file require --no-needs")
+		}
+		planJob := planner.PlanJob
+		if input.noNeeds {
+			p, ok := planner.(noNeedsPlanner)
+			if !ok {
+				return fmt.Errorf("--no-needs is not supported by the workflow planner")
+			}
+			planJob = p.PlanJobWithoutNeeds
+		}
 
 		// check if we should just list the workflows
 		list, err := cmd.Flags().GetBool("list")
@@ -526,7 +875,7 @@ func newRunCommand(ctx context.Context, input *Input) func(*cobra.Command, []str
 		var plannerErr error
 		if jobID != "" {
 			log.Debugf("Preparing plan with a job: %s", jobID)
-			filterPlan, plannerErr = planner.PlanJob(jobID)
+			filterPlan, plannerErr = planJob(jobID)
 		} else if filterEventName != "" {
 			log.Debugf("Preparing plan for a event: %s", filterEventName)
 			filterPlan, plannerErr = planner.PlanEvent(filterEventName)
@@ -579,12 +928,18 @@ func newRunCommand(ctx context.Context, input *Input) func(*cobra.Command, []str
 		// build the plan for this run
 		if jobID != "" {
 			log.Debugf("Planning job: %s", jobID)
-			plan, plannerErr = planner.PlanJob(jobID)
+			plan, plannerErr = planJob(jobID)
 		} else {
 			log.Debugf("Planning jobs for event: %s", eventName)
 			plan, plannerErr = planner.PlanEvent(eventName)
 		}
+		if plan != nil && input.resumeRun != nil {
+			plan = resumePlan(plan, input.resumeRun)
+		}
 		if plan != nil {
+			for _, skipped := range plan.Skipped {
+				log.Infof("Skipping workflow '%s': %s", skipped.Workflow.File, skipped.Reason)
+			}
 			if len(plan.Stages) == 0 {
 				plannerErr = fmt.Errorf("Could not find any stages to run. View the valid jobs with `gha --list`. Use `gha --help` to find how to filter by Job ID/Workflow/Event Name")
 			}
@@ -631,50 +986,27 @@ func newRunCommand(ctx context.Context, input *Input) func(*cobra.Command, []str
 			log.Warnf(deprecationWarning, "container-cap-drop", fmt.Sprintf("--cap-drop=%s", input.containerCapDrop))
 		}
 
-		// Check if OIDC server is running and configure environment
-		if oidcStatus, err := loadOIDCStatus(); err == nil && oidcStatus.Running && oidcStatus.NgrokURL != "" {
-			log.Infof("OIDC server detected, configuring environment variables")
-			oidcURL := oidcStatus.NgrokURL + "/token"
-
-			// Use the stored password from OIDC status
-			requestToken := oidcStatus.Password
-			log.Infof("Using OIDC password: [REDACTED]")
-			if requestToken == "" {
-				log.Warnf("No OIDC password found in status")
-				return nil
-			}
-
-			// Set OIDC environment variables
-			envs["ACTIONS_ID_TOKEN_REQUEST_URL"] = oidcURL
-			envs["ACTIONS_ID_TOKEN_REQUEST_TOKEN"] = requestToken
-			log.Infof("Set ACTIONS_ID_TOKEN_REQUEST_TOKEN in envs: [REDACTED]")
+		// jobs get their own OIDC request token when the OIDC server is running
+		if oidcStatus, err := loadOIDCStatus(); err == nil && oidcStatus.Running && oidcStatus.IssuerURL != "" {
+			log.Infof("OIDC server detected at %s (%s)", oidcStatus.IssuerURL, oidcStatus.Provider)
+		}
 
-			// Set required GitHub environment variables for OIDC
-			envs["GITHUB_ACTIONS"] = "true"
-			if envs["GITHUB_REPOSITORY"] == "" {
-				envs["GITHUB_REPOSITORY"] = "Leapfrog-DevOps/gha"
-			}
-			if envs["GITHUB_WORKFLOW"] == "" {
-				envs["GITHUB_WORKFLOW"] = "Test OIDC"
-			}
-			if envs["GITHUB_ACTION"] == "" {
-				envs["GITHUB_ACTION"] = "configure-aws-credentials"
-			}
-			if envs["GITHUB_ACTOR"] == "" {
-				envs["GITHUB_ACTOR"] = "Leapfrog-DevOps/gha"
-			}
-			if envs["GITHUB_SHA"] == "" {
-				envs["GITHUB_SHA"] = "e374ef962fc15812c175ff9ad6c33d77b8684e05"
+		// the debugger pauses the run at the breakpoints of the steps in an interactive terminal
+		var debugger runner.Debugger
+		if input.Debugging() {
+			if debugger = newDebugger(os.Stdin, os.Stdout); debugger == nil {
+				return fmt.Errorf("the debugger requires an interactive terminal")
 			}
+		}
 
-			// Also set in process environment for container inheritance
-			if err := os.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", oidcURL); err != nil {
-				log.Warnf("Failed to set ACTIONS_ID_TOKEN_REQUEST_URL: %v", err)
-			}
-			if err := os.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", requestToken); err != nil {
-				log.Warnf("Failed to set ACTIONS_ID_TOKEN_REQUEST_TOKEN: %v", err)
+		// the dashboard replaces the lines of the log in an interactive terminal
+		var dashboard *tui.Dashboard
+		if watch, _ := cmd.Flags().GetBool("watch"); input.tui && !watch && debugger == nil {
+			if tui.IsTerminal(os.Stdin, os.Stdout) {
+				dashboard = tui.New(os.Stdin, os.Stdout, eventName)
+			} else {
+				log.Debugf("The terminal is not interactive, showing the log instead of the dashboard")
 			}
-			log.Infof("Set ACTIONS_ID_TOKEN_REQUEST_TOKEN in os.Setenv: [REDACTED]")
 		}
 
 		// run the plan
@@ -720,6 +1052,26 @@ func newRunCommand(ctx context.Context, input *Input) func(*cobra.Command, []str
 			Matrix:                             matrixes,
 			ContainerNetworkMode:               docker_container.NetworkMode(input.networkName),
 			ConcurrentJobs:                     input.concurrentJobs,
+			EnvironmentSecrets:                 environmentSecrets,
+			EnvironmentVars:                    environmentVars,
+			EnvironmentProtections:             environmentProtections,
+			DeploymentApprover:                 newDeploymentApprover(),
+			ReportDir:                          input.ReportDir(),
+			History:                            input.HistoryStore(),
+			Reports:                            reports,
+			TraceFile:                          input.resolve(input.traceFile),
+			TraceEndpoint:                      tracing.EndpointFromEnv(os.Getenv),
+			JobStartedHook:                     input.JobStartedHook(envs),
+			JobCompletedHook:                   input.JobCompletedHook(envs),
+			Debugger:                           debugger,
+			BreakBefore:                        input.BreakBefore(),
+			BreakOnFailure:                     input.BreakOnFailure(),
+			Resume:                             input.Resume(),
+			NeedsStubs:                         needsStubs,
+			SkipSteps:                          skipSteps,
+			OnlySteps:                          onlySteps,
+			MockSteps:                          mockSteps,
+			ActionMocks:                        actionMocks,
 		}
 		if input.useNewActionCache || len(input.localRepository) > 0 {
 			if input.actionOfflineMode {
@@ -746,11 +1098,24 @@ func newRunCommand(ctx context.Context, input *Input) func(*cobra.Command, []str
 				}
 			}
 		}
+		if dashboard != nil {
+			config.Hooks = dashboard
+		}
 		r, err := runner.New(config)
 		if err != nil {
 			return err
 		}
 
+		var eventWriter *runevents.Writer
+		if input.events != "" {
+			eventWriter, err = runevents.Open(input.Events())
+			if err != nil {
+				return fmt.Errorf("unable to open the events: %w", err)
+			}
+			defer eventWriter.Close()
+			ctx = runevents.WithWriter(ctx, eventWriter)
+		}
+
 		cancel := artifacts.Serve(ctx, input.artifactServerPath, input.artifactServerAddr, input.artifactServerPort)
 
 		const cacheURLKey = "ACTIONS_CACHE_URL"
@@ -761,6 +1126,9 @@ func newRunCommand(ctx context.Context, input *Input) func(*cobra.Command, []str
 			if err != nil {
 				return err
 			}
+			if eventWriter != nil {
+				cacheHandler.SetEventWriter(eventWriter)
+			}
 			envs[cacheURLKey] = cacheHandler.ExternalURL() + "/"
 		}
 
@@ -775,6 +1143,24 @@ func newRunCommand(ctx context.Context, input *Input) func(*cobra.Command, []str
 			return plannerErr
 		}
 
+		if dashboard != nil {
+			// q or Ctrl+C in the dashboard cancel the run like Ctrl+C does without it
+			var forceCancel context.CancelFunc
+			ctx, forceCancel = context.WithCancel(ctx)
+			defer forceCancel()
+			parent := common.JobCancelContext(ctx)
+			if parent == nil {
+				parent = context.Background()
+			}
+			cancelCtx, cancelRun := context.WithCancel(parent)
+			defer cancelRun()
+			ctx = runner.WithJobLoggerFactory(common.WithJobCancelContext(ctx, cancelCtx), dashboard)
+			if err := dashboard.Start(cancelRun, forceCancel); err != nil {
+				return err
+			}
+			defer dashboard.Stop()
+		}
+
 		executor := r.NewPlanExecutor(plan).Finally(func(_ context.Context) error {
 			cancel()
 			_ = cacheHandler.Close()
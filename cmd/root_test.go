@@ -2,10 +2,15 @@ package cmd
 
 import (
 	"context"
+	"os"
 	"path"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/runner"
 )
 
 func TestReadSecrets(t *testing.T) {
@@ -28,6 +33,94 @@ line3
 `, secrets["mysecret"])
 }
 
+func TestReadEnvironmentFiles(t *testing.T) {
+	environments := readEnvironmentFiles(path.Join("testdata", ".secrets"), true)
+	assert.Equal(t, map[string]string{"TOKEN": "production-token"}, environments("production"))
+	assert.Equal(t, map[string]string{"TOKEN": "staging-token"}, environments("staging"))
+	assert.Empty(t, environments("development"))
+	// the name of the environment can't point to another file
+	assert.Nil(t, environments("../secrets"))
+	assert.Nil(t, environments(""))
+}
+
+func TestParseEnvironmentProtections(t *testing.T) {
+	protections, err := parseEnvironmentProtections([]string{"production"}, []string{"production=5m", "staging=30s"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]runner.EnvironmentProtection{
+		"production": {RequiredReviewers: true, WaitTimer: 5 * time.Minute},
+		"staging":    {WaitTimer: 30 * time.Second},
+	}, protections)
+
+	_, err = parseEnvironmentProtections(nil, []string{"production"})
+	assert.EqualError(t, err, "invalid environment wait timer 'production', expected <environment>=<duration>")
+}
+
+func TestParseReports(t *testing.T) {
+	input := &Input{workdir: "/home/octocat/repo"}
+	reports, err := parseReports([]string{"junit=reports/results.xml", "sarif=/tmp/annotations.sarif"}, input.resolve)
+	assert.NoError(t, err)
+	assert.Equal(t, []runner.Report{
+		{Format: runner.ReportFormatJUnit, Path: "/home/octocat/repo/reports/results.xml"},
+		{Format: runner.ReportFormatSARIF, Path: "/tmp/annotations.sarif"},
+	}, reports)
+
+	_, err = parseReports([]string{"results.xml"}, input.resolve)
+	assert.EqualError(t, err, "invalid report 'results.xml', expected <format>=<path>")
+	_, err = parseReports([]string{"html=results.html"}, input.resolve)
+	assert.EqualError(t, err, "invalid report 'html=results.html', the format must be junit or sarif")
+}
+
+func TestParseNeedsStubs(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "needs.json")
+	assert.NoError(t, os.WriteFile(file, []byte(`{"build": {"result": "success", "outputs": {"version": "1.2.2", "sha": "abc"}}, "lint": {"result": "failure"}}`), 0o600))
+	stubs, err := parseNeedsStubs(file, []string{"build.version=1.2.3", "test.report=a=b"}, []string{"lint=success"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]runner.NeedsStub{
+		"build": {Result: "success", Outputs: map[string]string{"version": "1.2.3", "sha": "abc"}},
+		"lint":  {Result: "success"},
+		"test":  {Outputs: map[string]string{"report": "a=b"}},
+	}, stubs)
+
+	_, err = parseNeedsStubs("", []string{"build=1.2.3"}, nil)
+	assert.EqualError(t, err, "invalid needs output 'build=1.2.3', expected <job-id>.<output>=<value>")
+	_, err = parseNeedsStubs("", nil, []string{"build=passed"})
+	assert.EqualError(t, err, "invalid result 'passed' of the needed job 'build', the result must be success, failure, cancelled or skipped")
+}
+
+func TestParseStepMocks(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "plan.json"), []byte(`{"changes": "true", "count": 3}`), 0o600))
+	input := &Input{workdir: dir}
+	mocks, err := parseStepMocks([]string{"deploy.plan=plan.json", "deploy.apply=outcome=failure", "test.unit=plan.json,outcome=success"}, input.resolve)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]runner.StepMock{
+		"deploy.plan":  {Outputs: map[string]string{"changes": "true", "count": "3"}},
+		"deploy.apply": {Outcome: "failure"},
+		"test.unit":    {Outputs: map[string]string{"changes": "true", "count": "3"}, Outcome: "success"},
+	}, mocks)
+
+	_, err = parseStepMocks([]string{"plan=plan.json"}, input.resolve)
+	assert.EqualError(t, err, "invalid mocked step 'plan=plan.json', expected <job-id>.<step-id>=[<outputs.json>][,outcome=<outcome>]")
+	_, err = parseStepMocks([]string{"deploy.plan=outcome=cancelled"}, input.resolve)
+	assert.EqualError(t, err, "invalid mocked step 'deploy.plan=outcome=cancelled', the outcome must be success or failure")
+	_, err = parseStepRefs("--skip-step", []string{"deploy.apply", "apply"})
+	assert.EqualError(t, err, "invalid --skip-step 'apply', expected <job-id>.<step-id>")
+}
+
+func TestRunnerHooks(t *testing.T) {
+	// the hooks of a self-hosted runner installed on the machine don't run
+	t.Setenv("ACTIONS_RUNNER_HOOK_JOB_STARTED", "/opt/runner/hooks/started.sh")
+	input := &Input{workdir: "/home/octocat/repo"}
+	assert.Empty(t, input.JobStartedHook(map[string]string{}))
+
+	envs := map[string]string{"ACTIONS_RUNNER_HOOK_JOB_STARTED": "hooks/started.sh", "ACTIONS_RUNNER_HOOK_JOB_COMPLETED": "/opt/completed.sh"}
+	assert.Equal(t, "/home/octocat/repo/hooks/started.sh", input.JobStartedHook(envs))
+	assert.Equal(t, "/opt/completed.sh", input.JobCompletedHook(envs))
+
+	input.jobStartedHook = "started.sh"
+	assert.Equal(t, "/home/octocat/repo/started.sh", input.JobStartedHook(envs))
+}
+
 func TestListOptions(t *testing.T) {
 	rootCmd := createRootCommand(context.Background(), &Input{}, "")
 	err := newRunCommand(context.Background(), &Input{
@@ -21,6 +21,7 @@ import (
 	"go.etcd.io/bbolt"
 
 	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/events"
 )
 
 const (
@@ -40,6 +41,8 @@ type Handler struct {
 
 	outboundIP        string
 	customExternalURL string
+
+	events *events.Writer
 }
 
 func StartHandler(dir, customExternalURL string, outboundIP string, port uint16, logger logrus.FieldLogger) (*Handler, error) {
@@ -116,6 +119,11 @@ func StartHandler(dir, customExternalURL string, outboundIP string, port uint16,
 	return h, nil
 }
 
+// SetEventWriter sets the writer the hits of the cache are emitted to as events
+func (h *Handler) SetEventWriter(w *events.Writer) {
+	h.events = w
+}
+
 func (h *Handler) GetActualPort() int {
 	return h.listener.Addr().(*net.TCPAddr).Port
 }
@@ -198,6 +206,11 @@ func (h *Handler) find(w http.ResponseWriter, r *http.Request, _ httprouter.Para
 		h.responseJSON(w, r, 204)
 		return
 	}
+	if h.events != nil {
+		if err := h.events.Write(events.Event{Type: events.CacheHit, Cache: &events.Cache{Key: cache.Key, Version: version}}); err != nil {
+			h.logger.Debugf("unable to write the cache hit event: %v", err)
+		}
+	}
 	h.responseJSON(w, r, 200, map[string]any{
 		"result":          "hit",
 		"archiveLocation": fmt.Sprintf("%s%s/artifacts/%d", h.ExternalURL(), urlBase, cache.ID),
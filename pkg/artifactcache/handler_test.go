@@ -16,6 +16,8 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/timshannon/bolthold"
 	"go.etcd.io/bbolt"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/events"
 )
 
 func TestHandler(t *testing.T) {
@@ -423,6 +425,27 @@ func TestHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("cache hit event", func(t *testing.T) {
+		version := "c19da02a2bd7e77277f1ac29ab45c09b7d46a4ee758284e26bb3045ad11d9d20"
+		key := strings.ToLower(t.Name())
+		content := make([]byte, 100)
+		_, err := rand.Read(content)
+		require.NoError(t, err)
+		uploadCacheNormally(t, base, key, version, content)
+
+		buf := &bytes.Buffer{}
+		handler.SetEventWriter(events.NewWriter(buf))
+		defer handler.SetEventWriter(nil)
+		resp, err := http.Get(fmt.Sprintf("%s/cache?keys=%s,%s_other&version=%s", base, key, key, version))
+		require.NoError(t, err)
+		require.Equal(t, 200, resp.StatusCode)
+
+		var event events.Event
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &event))
+		assert.Equal(t, events.CacheHit, event.Type)
+		assert.Equal(t, &events.Cache{Key: key, Version: version}, event.Cache)
+	})
+
 	t.Run("exact keys are preferred (key 0)", func(t *testing.T) {
 		version := "c19da02a2bd7e77277f1ac29ab45c09b7d46a4ee758284e26bb3045ad11d9d20"
 		key := strings.ToLower(t.Name())
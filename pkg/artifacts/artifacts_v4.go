@@ -100,6 +100,8 @@ import (
 	"google.golang.org/protobuf/encoding/protojson"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/events"
 )
 
 const (
@@ -347,6 +349,12 @@ func (r *artifactV4Routes) finalizeArtifact(ctx *ArtifactContext) {
 		return
 	}
 
+	events.Emit(ctx.Req.Context(), events.Event{Type: events.ArtifactUploaded, Artifact: &events.Artifact{
+		Name:  req.Name,
+		Size:  req.Size,
+		RunID: req.WorkflowRunBackendId,
+	}})
+
 	respData := FinalizeArtifactResponse{
 		Ok:         true,
 		ArtifactId: artifactNameToID(req.Name),
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -16,6 +17,7 @@ import (
 	"github.com/julienschmidt/httprouter"
 
 	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/events"
 )
 
 type FileContainerResourceURL struct {
@@ -160,7 +162,20 @@ func uploads(router *httprouter.Router, baseDir string, fsys WriteFS) {
 		}
 	})
 
-	router.PATCH("/_apis/pipelines/workflows/:runId/artifacts", func(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	router.PATCH("/_apis/pipelines/workflows/:runId/artifacts", func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		// the upload of the artifact is finalized with its size
+		if events.FromContext(req.Context()) != nil && req.Body != nil {
+			var body struct {
+				Size int64 `json:"Size"`
+			}
+			_ = json.NewDecoder(req.Body).Decode(&body)
+			events.Emit(req.Context(), events.Event{Type: events.ArtifactUploaded, Artifact: &events.Artifact{
+				Name:  req.URL.Query().Get("artifactName"),
+				Size:  body.Size,
+				RunID: params.ByName("runId"),
+			}})
+		}
+
 		json, err := json.Marshal(ResponseMessage{
 			Message: "success",
 		})
@@ -295,6 +310,8 @@ func Serve(ctx context.Context, artifactPath string, addr string, port string) c
 		Addr:              fmt.Sprintf("%s:%s", addr, port),
 		ReadHeaderTimeout: 2 * time.Second,
 		Handler:           router,
+		// the requests have the values of the context, e.g. the writer of the events
+		BaseContext: func(net.Listener) context.Context { return context.WithoutCancel(serverContext) },
 	}
 
 	// run server
@@ -1,6 +1,7 @@
 package artifacts
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -17,6 +18,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/Leapfrog-DevOps/gha/pkg/events"
 	"github.com/Leapfrog-DevOps/gha/pkg/model"
 	"github.com/Leapfrog-DevOps/gha/pkg/runner"
 )
@@ -139,6 +141,26 @@ func TestFinalizeArtifactUpload(t *testing.T) {
 	assert.Equal("success", response.Message)
 }
 
+func TestFinalizeArtifactUploadEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	router := httprouter.New()
+	uploads(router, "artifact/server/path", writeMapFS{fstest.MapFS{}})
+
+	buf := &bytes.Buffer{}
+	ctx := events.WithWriter(context.Background(), events.NewWriter(buf))
+	req, _ := http.NewRequestWithContext(ctx, "PATCH", "http://localhost/_apis/pipelines/workflows/1/artifacts?artifactName=dist", strings.NewReader(`{"Size": 42}`))
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(http.StatusOK, rr.Code)
+	var event events.Event
+	assert.NoError(json.Unmarshal(buf.Bytes(), &event))
+	assert.Equal(events.ArtifactUploaded, event.Type)
+	assert.Equal(&events.Artifact{Name: "dist", Size: 42, RunID: "1"}, event.Artifact)
+}
+
 func TestListArtifacts(t *testing.T) {
 	assert := assert.New(t)
 
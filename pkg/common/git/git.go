@@ -14,6 +14,7 @@ import (
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/mattn/go-isatty"
@@ -160,6 +161,76 @@ func FindGitRef(ctx context.Context, file string) (string, error) {
 	return "", fmt.Errorf("failed to identify reference (tag/branch) for the checked-out revision '%s'", ref)
 }
 
+// FindChangedFiles get the files changed between two revisions. With mergeBase set the changes
+// are computed from the merge base of both revisions, like the diff of a pull request.
+func FindChangedFiles(ctx context.Context, file, from, to string, mergeBase bool) ([]string, error) {
+	logger := common.Logger(ctx)
+
+	repo, err := git.PlainOpenWithOptions(
+		file,
+		&git.PlainOpenOptions{
+			DetectDotGit:          true,
+			EnableDotGitCommonDir: true,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resolveCommit := func(rev string) (*object.Commit, error) {
+		hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve revision '%s': %w", rev, err)
+		}
+		return repo.CommitObject(*hash)
+	}
+
+	fromCommit, err := resolveCommit(from)
+	if err != nil {
+		return nil, err
+	}
+	toCommit, err := resolveCommit(to)
+	if err != nil {
+		return nil, err
+	}
+
+	if mergeBase {
+		bases, err := fromCommit.MergeBase(toCommit)
+		if err != nil {
+			return nil, err
+		}
+		if len(bases) > 0 {
+			fromCommit = bases[0]
+		}
+	}
+
+	logger.Debugf("Computing changed files between %s and %s", fromCommit.Hash, toCommit.Hash)
+
+	fromTree, err := fromCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := toCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := fromTree.DiffContext(ctx, toTree)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(changes))
+	for _, change := range changes {
+		if change.To.Name != "" {
+			files = append(files, change.To.Name)
+		} else {
+			files = append(files, change.From.Name)
+		}
+	}
+	return files, nil
+}
+
 // FindGithubRepo get the repo
 func FindGithubRepo(ctx context.Context, file, githubInstance, remoteName string) (string, error) {
 	if remoteName == "" {
@@ -177,6 +177,39 @@ func TestGitFindRef(t *testing.T) {
 	}
 }
 
+func TestGitFindChangedFiles(t *testing.T) {
+	dir := filepath.Join(testDir(t), "changed_files")
+	gitConfig()
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, gitCmd("-C", dir, "init", "--initial-branch=master"))
+	require.NoError(t, cleanGitHooks(dir))
+
+	commitFile := func(name string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600))
+		require.NoError(t, gitCmd("-C", dir, "add", name))
+		require.NoError(t, gitCmd("-C", dir, "commit", "-m", name))
+	}
+
+	commitFile("README.md")
+	require.NoError(t, gitCmd("-C", dir, "checkout", "-b", "feature"))
+	commitFile("docs/index.md")
+	commitFile("src/main.go")
+	require.NoError(t, gitCmd("-C", dir, "checkout", "master"))
+	commitFile("LICENSE")
+
+	files, err := FindChangedFiles(context.Background(), dir, "feature~1", "feature", false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"src/main.go"}, files)
+
+	files, err = FindChangedFiles(context.Background(), dir, "master", "feature", true)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"docs/index.md", "src/main.go"}, files)
+
+	_, err = FindChangedFiles(context.Background(), dir, "missing", "feature", false)
+	require.Error(t, err)
+}
+
 func TestGitCloneExecutor(t *testing.T) {
 	t.Skip("Skipping test that requires network access to external repositories")
 	for name, tt := range map[string]struct {
@@ -14,11 +14,12 @@ import (
 	"github.com/docker/docker/api/types/registry"
 
 	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/tracing"
 )
 
 // NewDockerPullExecutor function to create a run executor for the container
 func NewDockerPullExecutor(input NewDockerPullExecutorInput) common.Executor {
-	return func(ctx context.Context) error {
+	return tracing.NewSpanExecutor("docker pull "+input.Image, func(ctx context.Context) error {
 		logger := common.Logger(ctx)
 		logger.Debugf("%sdocker pull %v", logPrefix, input.Image)
 
@@ -42,6 +43,7 @@ func NewDockerPullExecutor(input NewDockerPullExecutorInput) common.Executor {
 		if !pull {
 			return nil
 		}
+		tracing.SpanFromContext(ctx).SetAttributes(tracing.Bool("gha.docker.pulled", true))
 
 		imageRef := cleanImage(ctx, input.Image)
 		logger.Debugf("pulling image '%v' (%s)", imageRef, input.Platform)
@@ -71,7 +73,7 @@ func NewDockerPullExecutor(input NewDockerPullExecutorInput) common.Executor {
 			return err
 		}
 		return nil
-	}
+	}, tracing.String("container.image.name", input.Image), tracing.String("gha.docker.platform", input.Platform), tracing.Bool("gha.docker.pulled", false))
 }
 
 func getImagePullOptions(ctx context.Context, input NewDockerPullExecutorInput) (image.PullOptions, error) {
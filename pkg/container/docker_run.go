@@ -37,6 +37,7 @@ import (
 
 	"github.com/Leapfrog-DevOps/gha/pkg/common"
 	"github.com/Leapfrog-DevOps/gha/pkg/filecollector"
+	"github.com/Leapfrog-DevOps/gha/pkg/tracing"
 )
 
 // NewContainer creates a reference to a container
@@ -68,11 +69,11 @@ func (cr *containerReference) Create(capAdd []string, capDrop []string) common.E
 	return common.
 		NewInfoExecutor("%sdocker create image=%s platform=%s entrypoint=%+q cmd=%+q network=%+q", logPrefix, cr.input.Image, cr.input.Platform, cr.input.Entrypoint, cr.input.Cmd, cr.input.NetworkMode).
 		Then(
-			common.NewPipelineExecutor(
+			tracing.NewSpanExecutor("docker create "+cr.input.Name, common.NewPipelineExecutor(
 				cr.connect(),
 				cr.find(),
 				cr.create(capAdd, capDrop),
-			).IfNot(common.Dryrun),
+			), cr.spanAttributes()...).IfNot(common.Dryrun),
 		)
 }
 
@@ -80,7 +81,7 @@ func (cr *containerReference) Start(attach bool) common.Executor {
 	return common.
 		NewInfoExecutor("%sdocker run image=%s platform=%s entrypoint=%+q cmd=%+q network=%+q", logPrefix, cr.input.Image, cr.input.Platform, cr.input.Entrypoint, cr.input.Cmd, cr.input.NetworkMode).
 		Then(
-			common.NewPipelineExecutor(
+			tracing.NewSpanExecutor("docker start "+cr.input.Name, common.NewPipelineExecutor(
 				cr.connect(),
 				cr.find(),
 				cr.attach().IfBool(attach),
@@ -94,10 +95,17 @@ func (cr *containerReference) Start(attach bool) common.Executor {
 					}
 					return nil
 				},
-			).IfNot(common.Dryrun),
+			), cr.spanAttributes()...).IfNot(common.Dryrun),
 		)
 }
 
+func (cr *containerReference) spanAttributes() []tracing.Attribute {
+	return []tracing.Attribute{
+		tracing.String("container.name", cr.input.Name),
+		tracing.String("container.image.name", cr.input.Image),
+	}
+}
+
 func (cr *containerReference) Pull(forcePull bool) common.Executor {
 	return common.
 		NewInfoExecutor("%sdocker pull image=%s platform=%s username=%s forcePull=%t", logPrefix, cr.input.Image, cr.input.Platform, cr.input.Username, forcePull).
@@ -569,6 +577,19 @@ func (cr *containerReference) exec(cmd []string, env map[string]string, user, wo
 		}
 		logger.Debugf("Working directory '%s'", wd)
 
+		if terminal := TerminalFromContext(ctx); terminal != nil {
+			return cr.execTerminal(ctx, terminal, container.ExecOptions{
+				User:         user,
+				Cmd:          cmd,
+				WorkingDir:   wd,
+				Env:          envList,
+				Tty:          true,
+				AttachStdin:  true,
+				AttachStderr: true,
+				AttachStdout: true,
+			})
+		}
+
 		idResp, err := cr.cli.ContainerExecCreate(ctx, cr.id, container.ExecOptions{
 			User:         user,
 			Cmd:          cmd,
@@ -595,20 +616,56 @@ func (cr *containerReference) exec(cmd []string, env map[string]string, user, wo
 			return err
 		}
 
-		inspectResp, err := cr.cli.ContainerExecInspect(ctx, idResp.ID)
-		if err != nil {
-			return fmt.Errorf("failed to inspect exec: %w", err)
-		}
+		return cr.execExitError(ctx, idResp.ID)
+	}
+}
 
-		switch inspectResp.ExitCode {
-		case 0:
-			return nil
-		case 127:
-			return fmt.Errorf("exitcode '%d': command not found, please refer to https://github.com/Leapfrog-DevOps/gha/issues/107 for more information", inspectResp.ExitCode)
-		default:
-			return fmt.Errorf("exitcode '%d': failure", inspectResp.ExitCode)
-		}
+// execExitError returns the error of the exit code of the exec
+func (cr *containerReference) execExitError(ctx context.Context, execID string) error {
+	inspectResp, err := cr.cli.ContainerExecInspect(ctx, execID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect exec: %w", err)
+	}
+
+	switch inspectResp.ExitCode {
+	case 0:
+		return nil
+	case 127:
+		return fmt.Errorf("exitcode '%d': command not found, please refer to https://github.com/Leapfrog-DevOps/gha/issues/107 for more information", inspectResp.ExitCode)
+	default:
+		return fmt.Errorf("exitcode '%d': failure", inspectResp.ExitCode)
+	}
+}
+
+// execTerminal runs the exec interactively in the terminal of the user, through the pty of the exec
+func (cr *containerReference) execTerminal(ctx context.Context, terminal *Terminal, options container.ExecOptions) error {
+	idResp, err := cr.cli.ContainerExecCreate(ctx, cr.id, options)
+	if err != nil {
+		return fmt.Errorf("failed to create exec: %w", err)
+	}
+	resp, err := cr.cli.ContainerExecAttach(ctx, idResp.ID, container.ExecStartOptions{Tty: true})
+	if err != nil {
+		return fmt.Errorf("failed to attach to exec: %w", err)
+	}
+	defer resp.Close()
+
+	if width, height, err := term.GetSize(terminal.Fd); err == nil {
+		_ = cr.cli.ContainerExecResize(ctx, idResp.ID, container.ResizeOptions{Width: uint(width), Height: uint(height)})
+	}
+	if state, err := term.MakeRaw(terminal.Fd); err == nil {
+		defer func() {
+			_ = term.Restore(terminal.Fd, state)
+		}()
+	}
+
+	go func() {
+		_, _ = io.Copy(resp.Conn, terminal.In)
+	}()
+	// the output ends when the command exits
+	if _, err := io.Copy(terminal.Out, resp.Reader); err != nil && ctx.Err() == nil {
+		return err
 	}
+	return cr.execExitError(ctx, idResp.ID)
 }
 
 func (cr *containerReference) tryReadID(opt string, cbk func(id int)) common.Executor {
@@ -311,6 +311,9 @@ func (e *HostEnvironment) exec(ctx context.Context, command []string, cmdline st
 	cmd.Stderr = e.StdOut
 	cmd.Dir = wd
 	cmd.SysProcAttr = getSysProcAttr(cmdline, false)
+	if terminal := TerminalFromContext(ctx); terminal != nil {
+		return execTerminal(terminal, cmd, cmdline)
+	}
 	var ppty *os.File
 	var tty *os.File
 	defer func() {
@@ -357,6 +360,41 @@ func (e *HostEnvironment) exec(ctx context.Context, command []string, cmdline st
 	return err
 }
 
+// execTerminal runs the command interactively in the terminal of the user, through a pty
+func execTerminal(terminal *Terminal, cmd *exec.Cmd, cmdline string) error {
+	ppty, tty, err := openPty()
+	if err != nil {
+		return fmt.Errorf("unable to open a pty: %w", err)
+	}
+	defer ppty.Close()
+	if width, height, err := term.GetSize(terminal.Fd); err == nil {
+		_ = setPtySize(ppty, width, height)
+	}
+	if state, err := term.MakeRaw(terminal.Fd); err == nil {
+		defer func() {
+			_ = term.Restore(terminal.Fd, state)
+		}()
+	}
+	cmd.Stdin = tty
+	cmd.Stdout = tty
+	cmd.Stderr = tty
+	cmd.SysProcAttr = getSysProcAttr(cmdline, true)
+
+	go func() {
+		_, _ = io.Copy(ppty, terminal.In)
+	}()
+	output := make(chan struct{})
+	go func() {
+		defer close(output)
+		_, _ = io.Copy(terminal.Out, ppty)
+	}()
+	err = cmd.Run()
+	// the output ends once the command exited and the pty is drained
+	tty.Close()
+	<-output
+	return err
+}
+
 func (e *HostEnvironment) Exec(command []string /*cmdline string, */, env map[string]string, user, workdir string) common.Executor {
 	return e.ExecWithCmdLine(command, "", env, user, workdir)
 }
@@ -0,0 +1,31 @@
+package container
+
+import (
+	"context"
+	"io"
+)
+
+// Terminal is the interactive terminal of the user. An Exec with a terminal in its context runs
+// interactively through a pty, e.g. a shell: its input is read from In and its output is written to Out.
+type Terminal struct {
+	In  io.Reader // read until the command exits, it must return io.EOF once the command exited to stop the read
+	Out io.Writer
+	Fd  int // the file descriptor of the terminal, set to raw mode while the command runs
+}
+
+type terminalContextKey string
+
+const terminalContextKeyVal = terminalContextKey("container.terminal")
+
+// WithTerminal adds the terminal to the context, the Execs with the context run interactively
+func WithTerminal(ctx context.Context, terminal *Terminal) context.Context {
+	return context.WithValue(ctx, terminalContextKeyVal, terminal)
+}
+
+// TerminalFromContext returns the terminal of the context, nil if the Execs don't run interactively
+func TerminalFromContext(ctx context.Context) *Terminal {
+	if terminal, ok := ctx.Value(terminalContextKeyVal).(*Terminal); ok {
+		return terminal
+	}
+	return nil
+}
@@ -24,3 +24,7 @@ func getSysProcAttr(_ string, tty bool) *syscall.SysProcAttr {
 func openPty() (*os.File, *os.File, error) {
 	return pty.Open()
 }
+
+func setPtySize(ppty *os.File, width int, height int) error {
+	return pty.Setsize(ppty, &pty.Winsize{Cols: uint16(width), Rows: uint16(height)})
+}
@@ -15,3 +15,7 @@ func getSysProcAttr(cmdLine string, tty bool) *syscall.SysProcAttr {
 func openPty() (*os.File, *os.File, error) {
 	return nil, nil, errors.New("Unsupported")
 }
+
+func setPtySize(_ *os.File, _ int, _ int) error {
+	return errors.New("Unsupported")
+}
@@ -0,0 +1,182 @@
+// Package events writes the lifecycle events of a run of gha as NDJSON, a JSON object per line,
+// for tools that follow the progress of the run.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+// Version is the version of the format of the events, it changes when a field is removed or changes its meaning
+const Version = 1
+
+// Type is the type of an event, it determines which fields of the event are set
+type Type string
+
+const (
+	PlanCreated      Type = "plan-created"      // Plan
+	JobQueued        Type = "job-queued"        // Job
+	JobStarted       Type = "job-started"       // Job
+	JobFinished      Type = "job-finished"      // Job with its result
+	StepStarted      Type = "step-started"      // Job, Step
+	StepFinished     Type = "step-finished"     // Job, Step with its outcome and conclusion
+	OutputSet        Type = "output-set"        // Job, Step, Output
+	AnnotationAdded  Type = "annotation"        // Job, Step, Annotation
+	ArtifactUploaded Type = "artifact-uploaded" // Artifact
+	CacheHit         Type = "cache-hit"         // Cache
+)
+
+// Event is a line of the event stream
+type Event struct {
+	Version    int               `json:"version"`
+	Type       Type              `json:"type"`
+	Time       time.Time         `json:"time"`
+	Plan       *Plan             `json:"plan,omitempty"`
+	Job        *Job              `json:"job,omitempty"`
+	Step       *Step             `json:"step,omitempty"`
+	Output     *Output           `json:"output,omitempty"`
+	Annotation *model.Annotation `json:"annotation,omitempty"`
+	Artifact   *Artifact         `json:"artifact,omitempty"`
+	Cache      *Cache            `json:"cache,omitempty"`
+}
+
+// Plan is the plan of the run, the IDs of the jobs of each stage
+type Plan struct {
+	Workflows []string   `json:"workflows"`
+	Stages    [][]string `json:"stages"`
+}
+
+// Job is a job of the run, a leg of a matrix is a job of its own with a unique name
+type Job struct {
+	ID       string                 `json:"id"`
+	Name     string                 `json:"name"`
+	Workflow string                 `json:"workflow"`
+	Matrix   map[string]interface{} `json:"matrix,omitempty"`
+	Caller   string                 `json:"caller,omitempty"` // the ID of the job calling the reusable workflow of the job
+	Result   string                 `json:"result,omitempty"`
+}
+
+// Step is a stage of a step of a job
+type Step struct {
+	ID         string `json:"id"`
+	Name       string `json:"name,omitempty"`
+	Stage      string `json:"stage,omitempty"`
+	Outcome    string `json:"outcome,omitempty"`
+	Conclusion string `json:"conclusion,omitempty"`
+}
+
+// Output is an output set by a step, the secrets in its value are masked
+type Output struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Artifact is an artifact uploaded to the artifact server
+type Artifact struct {
+	Name  string `json:"name"`
+	Size  int64  `json:"size,omitempty"`
+	RunID string `json:"runId"`
+}
+
+// Cache is an entry of the cache server
+type Cache struct {
+	Key     string `json:"key"`
+	Version string `json:"version"`
+}
+
+// Writer writes the events as NDJSON, it can be used by several goroutines at the same time
+type Writer struct {
+	mu     sync.Mutex
+	out    io.Writer
+	closer io.Closer
+}
+
+// NewWriter returns a writer of the events to out
+func NewWriter(out io.Writer) *Writer {
+	return &Writer{out: out}
+}
+
+// Open returns a writer of the events to the target, which is the number of a file descriptor
+// inherited from the parent process, e.g. 3, or the path of a file
+func Open(target string) (*Writer, error) {
+	if fd, err := strconv.Atoi(target); err == nil {
+		file := os.NewFile(uintptr(fd), "fd"+target)
+		if file == nil {
+			return nil, fmt.Errorf("invalid file descriptor %d", fd)
+		}
+		if _, err := file.Stat(); err != nil {
+			return nil, fmt.Errorf("file descriptor %d is not open: %w", fd, err)
+		}
+		return &Writer{out: file, closer: file}, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.Create(target)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{out: file, closer: file}, nil
+}
+
+// Write writes the event as a line, with the current version and time
+func (w *Writer) Write(event Event) error {
+	event.Version = Version
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.out.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the file or file descriptor the events are written to
+func (w *Writer) Close() error {
+	if w.closer == nil {
+		return nil
+	}
+	return w.closer.Close()
+}
+
+type writerContextKey string
+
+const writerContextKeyVal = writerContextKey("events.writer")
+
+// WithWriter adds the writer to the context, the events emitted with the context are written to it
+func WithWriter(ctx context.Context, w *Writer) context.Context {
+	return context.WithValue(ctx, writerContextKeyVal, w)
+}
+
+// FromContext returns the writer of the context, nil if the events are not written
+func FromContext(ctx context.Context) *Writer {
+	if w, ok := ctx.Value(writerContextKeyVal).(*Writer); ok {
+		return w
+	}
+	return nil
+}
+
+// Emit writes the event to the writer of the context, if it has one
+func Emit(ctx context.Context, event Event) {
+	w := FromContext(ctx)
+	if w == nil {
+		return
+	}
+	if err := w.Write(event); err != nil {
+		common.Logger(ctx).Debugf("unable to write the %s event: %v", event.Type, err)
+	}
+}
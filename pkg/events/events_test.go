@@ -0,0 +1,72 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+func TestWrite(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	require.NoError(t, w.Write(Event{Type: JobStarted, Time: at, Job: &Job{ID: "test", Name: "CI/test-1", Workflow: "CI", Matrix: map[string]interface{}{"node": 20}}}))
+	require.NoError(t, w.Write(Event{Type: AnnotationAdded, Time: at, Job: &Job{ID: "test", Name: "CI/test-1", Workflow: "CI"}, Step: &Step{ID: "lint"}, Annotation: &model.Annotation{Level: "error", Message: "unused", File: "main.go", Line: 3}}))
+
+	assert.Equal(t, `{"version":1,"type":"job-started","time":"2024-05-01T10:00:00Z","job":{"id":"test","name":"CI/test-1","workflow":"CI","matrix":{"node":20}}}
+{"version":1,"type":"annotation","time":"2024-05-01T10:00:00Z","job":{"id":"test","name":"CI/test-1","workflow":"CI"},"step":{"id":"lint"},"annotation":{"level":"error","message":"unused","file":"main.go","line":3}}
+`, buf.String())
+}
+
+func TestEmit(t *testing.T) {
+	// the events are not written without a writer
+	Emit(context.Background(), Event{Type: CacheHit, Cache: &Cache{Key: "key"}})
+
+	buf := &bytes.Buffer{}
+	ctx := WithWriter(context.Background(), NewWriter(buf))
+	Emit(ctx, Event{Type: CacheHit, Cache: &Cache{Key: "key", Version: "v1"}})
+
+	var event Event
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &event))
+	assert.Equal(t, Version, event.Version)
+	assert.Equal(t, CacheHit, event.Type)
+	assert.False(t, event.Time.IsZero())
+	assert.Equal(t, &Cache{Key: "key", Version: "v1"}, event.Cache)
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events", "events.ndjson")
+	w, err := Open(path)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(Event{Type: PlanCreated, Plan: &Plan{Workflows: []string{"CI"}, Stages: [][]string{{"build"}, {"test"}}}}))
+	require.NoError(t, w.Close())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"plan":{"workflows":["CI"],"stages":[["build"],["test"]]}}`)
+
+	// a file descriptor inherited from the parent process
+	file, err := os.Create(filepath.Join(t.TempDir(), "fd.ndjson"))
+	require.NoError(t, err)
+	w, err = Open(strconv.Itoa(int(file.Fd())))
+	require.NoError(t, err)
+	require.NoError(t, w.Write(Event{Type: JobQueued, Job: &Job{ID: "build"}}))
+	data, err = os.ReadFile(file.Name())
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), `{"version":1,"type":"job-queued"`))
+	require.NoError(t, w.Close())
+
+	_, err = Open("1000")
+	assert.ErrorContains(t, err, "file descriptor 1000 is not open")
+}
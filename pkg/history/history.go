@@ -0,0 +1,270 @@
+// Package history records the local runs of gha, to look at their results and logs after the run.
+package history
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+const (
+	runFile  = "run.json"
+	logsFile = "logs.zip"
+
+	StatusInProgress = "in_progress"
+	StatusCompleted  = "completed"
+
+	// LogTimestampFormat is the timestamp of the lines of the step logs, like in the logs of GitHub
+	LogTimestampFormat = "2006-01-02T15:04:05.0000000Z"
+)
+
+// Run is a recorded invocation of gha
+type Run struct {
+	Number      int        `json:"number"`
+	Event       string     `json:"event"`
+	Workdir     string     `json:"workdir"`
+	Workflows   []string   `json:"workflows"`
+	Plan        [][]string `json:"plan"` // the job IDs of each stage of the plan
+	Status      string     `json:"status"`
+	Conclusion  string     `json:"conclusion,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	CompletedAt time.Time  `json:"completed_at,omitempty"`
+	Jobs        []*Job     `json:"jobs"`
+}
+
+// Job is a job of a recorded run, a leg of a matrix is a job of its own
+type Job struct {
+	ID             string                 `json:"id"`
+	Name           string                 `json:"name"`
+	Workflow       string                 `json:"workflow"`
+	Matrix         map[string]interface{} `json:"matrix,omitempty"`
+	Status         string                 `json:"status"`
+	Conclusion     string                 `json:"conclusion,omitempty"`
+	StartedAt      time.Time              `json:"started_at,omitempty"`
+	CompletedAt    time.Time              `json:"completed_at,omitempty"`
+	Outputs        map[string]string      `json:"outputs,omitempty"`
+	Environment    string                 `json:"environment,omitempty"`     // the deployment environment of the job
+	EnvironmentURL string                 `json:"environment_url,omitempty"` // the url of the deployment environment
+	Container      string                 `json:"container,omitempty"`       // the name of the job container kept with --reuse
+	Steps          []*Step                `json:"steps"`
+}
+
+// Step is a stage of a step of a recorded job, e.g. the post stage of an action
+type Step struct {
+	Number      int                `json:"number"`
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Stage       string             `json:"stage"`
+	Conclusion  string             `json:"conclusion"`
+	Outcome     string             `json:"outcome"`
+	StartedAt   time.Time          `json:"started_at,omitempty"`
+	CompletedAt time.Time          `json:"completed_at,omitempty"`
+	Outputs     map[string]string  `json:"outputs,omitempty"`
+	Annotations []model.Annotation `json:"annotations,omitempty"`
+	Substitute  string             `json:"substitute,omitempty"` // the substitution of the mocked action of the step
+	Env         map[string]string  `json:"env,omitempty"`        // the env the step added with GITHUB_ENV
+	Path        []string           `json:"path,omitempty"`       // the paths the step added with GITHUB_PATH, the last one first
+	State       map[string]string  `json:"state,omitempty"`      // the state the step saved with GITHUB_STATE
+	Masked      bool               `json:"masked,omitempty"`     // the outputs, env or state passed a secret, it's recorded masked
+	Log         []byte             `json:"-"`                    // written to the logs of the run
+}
+
+// AppendLog adds the output line to the log of the step
+func (s *Step) AppendLog(t time.Time, line string) {
+	s.Log = append(s.Log, t.UTC().Format(LogTimestampFormat)+" "+line...)
+}
+
+// Output returns the log of the step without the timestamps of its lines
+func (s *Step) Output() string {
+	lines := strings.SplitAfter(string(s.Log), "\n")
+	for i, line := range lines {
+		if len(line) > len(LogTimestampFormat) && line[len(LogTimestampFormat)] == ' ' {
+			lines[i] = line[len(LogTimestampFormat)+1:]
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// LogDir returns the directory of the step logs of the job in the logs of the run
+func (j *Job) LogDir() string {
+	return logFileName(j.Name)
+}
+
+// LogFile returns the path of the log of the step in the logs of the run, like in the logs of a run on GitHub
+func (j *Job) LogFile(step *Step) string {
+	return fmt.Sprintf("%s/%d_%s.txt", j.LogDir(), step.Number, logFileName(step.Name))
+}
+
+func logFileName(name string) string {
+	return strings.NewReplacer("/", "_", "\\", "_", ":", "_", "*", "_", "?", "_", "\"", "_", "<", "_", ">", "_", "|", "_").Replace(name)
+}
+
+// Store keeps the recorded runs in a directory, a subdirectory per run
+type Store struct {
+	Dir   string
+	Limit int // the number of runs kept, the oldest are removed when a run is saved
+}
+
+func (s *Store) runDir(number int) string {
+	return filepath.Join(s.Dir, strconv.Itoa(number))
+}
+
+// Create adds the run to the history with the next number
+func (s *Store) Create(run *Run) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+	numbers, err := s.numbers()
+	if err != nil {
+		return err
+	}
+	run.Number = 1
+	if len(numbers) > 0 {
+		run.Number = numbers[len(numbers)-1] + 1
+	}
+	// another gha can create a run at the same time, the directory is the lock of the number
+	for {
+		err := os.Mkdir(s.runDir(run.Number), 0o755)
+		if err == nil {
+			break
+		} else if !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+		run.Number++
+	}
+	return s.writeRun(run)
+}
+
+// Save writes the run and the logs of its steps, and removes the runs over the limit
+func (s *Store) Save(run *Run) error {
+	if err := s.writeRun(run); err != nil {
+		return err
+	}
+	if err := s.writeLogs(run); err != nil {
+		return err
+	}
+	return s.prune()
+}
+
+// Checkpoint writes the run without the logs of its steps while it runs, the completed jobs of a run
+// that didn't finish, e.g. because gha was killed, can be resumed
+func (s *Store) Checkpoint(run *Run) error {
+	return s.writeRun(run)
+}
+
+func (s *Store) writeRun(run *Run) error {
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.runDir(run.Number), runFile), data, 0o600)
+}
+
+func (s *Store) writeLogs(run *Run) error {
+	file, err := os.OpenFile(filepath.Join(s.runDir(run.Number), logsFile), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := zip.NewWriter(file)
+	for _, job := range run.Jobs {
+		for _, step := range job.Steps {
+			f, err := w.Create(job.LogFile(step))
+			if err != nil {
+				return err
+			}
+			if _, err := f.Write(step.Log); err != nil {
+				return err
+			}
+		}
+	}
+	return w.Close()
+}
+
+func (s *Store) prune() error {
+	if s.Limit <= 0 {
+		return nil
+	}
+	numbers, err := s.numbers()
+	if err != nil {
+		return err
+	}
+	for len(numbers) > s.Limit {
+		if err := os.RemoveAll(s.runDir(numbers[0])); err != nil {
+			return err
+		}
+		numbers = numbers[1:]
+	}
+	return nil
+}
+
+// numbers returns the numbers of the recorded runs in ascending order
+func (s *Store) numbers() ([]int, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	numbers := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		if number, err := strconv.Atoi(entry.Name()); err == nil && entry.IsDir() {
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+// List returns the recorded runs, the latest first
+func (s *Store) List() ([]*Run, error) {
+	numbers, err := s.numbers()
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]*Run, 0, len(numbers))
+	for i := len(numbers) - 1; i >= 0; i-- {
+		run, err := s.Get(numbers[i])
+		if errors.Is(err, fs.ErrNotExist) {
+			// the run is being created
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// Get returns the recorded run with the number
+func (s *Store) Get(number int) (*Run, error) {
+	data, err := os.ReadFile(filepath.Join(s.runDir(number), runFile))
+	if err != nil {
+		return nil, err
+	}
+	run := &Run{}
+	if err := json.Unmarshal(data, run); err != nil {
+		return nil, fmt.Errorf("invalid run %d in the history: %w", number, err)
+	}
+	return run, nil
+}
+
+// Logs returns the zip archive of the step logs of the run, with the layout of the logs of a run on GitHub
+func (s *Store) Logs(number int) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.runDir(number), logsFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("the run %d has no logs, it did not complete", number)
+	}
+	return data, err
+}
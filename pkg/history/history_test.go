@@ -0,0 +1,94 @@
+package history
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+func TestStore(t *testing.T) {
+	store := &Store{Dir: t.TempDir(), Limit: 2}
+
+	runs, err := store.List()
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+
+	for i := 1; i <= 3; i++ {
+		run := &Run{Event: "push", Status: StatusInProgress, StartedAt: time.Now()}
+		require.NoError(t, store.Create(run))
+		assert.Equal(t, i, run.Number)
+
+		run.Status = StatusCompleted
+		run.Jobs = []*Job{{
+			ID:   "build",
+			Name: "CI/build",
+			Steps: []*Step{
+				{Number: 1, Name: "actions/checkout@v4", Log: []byte("checked out\n")},
+				{Number: 2, Name: "Run tests", Log: []byte("ok\n"), Annotations: []model.Annotation{{Level: "warning", Message: "slow"}}},
+			},
+		}}
+		require.NoError(t, store.Save(run))
+	}
+
+	// the oldest run is removed over the limit
+	runs, err = store.List()
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, 3, runs[0].Number)
+	assert.Equal(t, 2, runs[1].Number)
+	_, err = store.Get(1)
+	assert.Error(t, err)
+
+	run, err := store.Get(3)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompleted, run.Status)
+	assert.Equal(t, []model.Annotation{{Level: "warning", Message: "slow"}}, run.Jobs[0].Steps[1].Annotations)
+	assert.Empty(t, run.Jobs[0].Steps[1].Log)
+
+	data, err := store.Logs(3)
+	require.NoError(t, err)
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	logs := map[string]string{}
+	for _, file := range reader.File {
+		f, err := file.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(f)
+		require.NoError(t, err)
+		logs[file.Name] = string(content)
+	}
+	assert.Equal(t, map[string]string{
+		"CI_build/1_actions_checkout@v4.txt": "checked out\n",
+		"CI_build/2_Run tests.txt":           "ok\n",
+	}, logs)
+}
+
+func TestStoreLogsOfInterruptedRun(t *testing.T) {
+	store := &Store{Dir: t.TempDir()}
+	run := &Run{Event: "push", Status: StatusInProgress}
+	require.NoError(t, store.Create(run))
+
+	runs, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, StatusInProgress, runs[0].Status)
+
+	_, err = store.Logs(run.Number)
+	assert.EqualError(t, err, "the run 1 has no logs, it did not complete")
+}
+
+func TestStepOutput(t *testing.T) {
+	step := &Step{}
+	started := time.Date(2024, 5, 1, 10, 0, 0, 123456700, time.UTC)
+	step.AppendLog(started, "go test ./...\n")
+	step.AppendLog(started.Add(time.Second), "ok  \tpkg\t0.1s\n")
+	assert.Equal(t, "2024-05-01T10:00:00.1234567Z go test ./...\n2024-05-01T10:00:01.1234567Z ok  \tpkg\t0.1s\n", string(step.Log))
+	assert.Equal(t, "go test ./...\nok  \tpkg\t0.1s\n", step.Output())
+}
@@ -0,0 +1,138 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/workflowpattern"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// EventFilter contains the git context the `on.<event>` filters of a workflow are evaluated against
+type EventFilter struct {
+	Ref          string   // the full git ref of the event, e.g. refs/heads/main or refs/tags/v1
+	BaseRef      string   // the branch a pull request targets, used for the branches filters of pull_request events
+	ChangedFiles []string // the files changed by the event, nil when they can't be determined
+}
+
+// SkippedWorkflow is a workflow that listens to the planned event, but whose filters don't match
+type SkippedWorkflow struct {
+	Workflow *Workflow
+	Reason   string
+}
+
+type eventFilterConfig struct {
+	Branches       []string `yaml:"branches"`
+	BranchesIgnore []string `yaml:"branches-ignore"`
+	Tags           []string `yaml:"tags"`
+	TagsIgnore     []string `yaml:"tags-ignore"`
+	Paths          []string `yaml:"paths"`
+	PathsIgnore    []string `yaml:"paths-ignore"`
+}
+
+type filterTraceWriter struct{}
+
+func (*filterTraceWriter) Info(format string, args ...interface{}) {
+	log.Debugf(format, args...)
+}
+
+// skipReason returns why the workflow isn't triggered by the event, or an empty string if it is
+func (f *EventFilter) skipReason(w *Workflow, eventName string) (string, error) {
+	if f == nil || w.RawOn.Kind != yaml.MappingNode {
+		return "", nil
+	}
+	var events map[string]yaml.Node
+	if err := w.RawOn.Decode(&events); err != nil {
+		return "", err
+	}
+	node, ok := events[eventName]
+	if !ok || node.Kind != yaml.MappingNode {
+		return "", nil
+	}
+	var config eventFilterConfig
+	if err := node.Decode(&config); err != nil {
+		return "", err
+	}
+
+	switch eventName {
+	case "push":
+		if strings.HasPrefix(f.Ref, "refs/tags/") {
+			tag := strings.TrimPrefix(f.Ref, "refs/tags/")
+			if len(config.Tags) == 0 && len(config.TagsIgnore) == 0 && (len(config.Branches) > 0 || len(config.BranchesIgnore) > 0) {
+				return fmt.Sprintf("tag '%s' is not matched, only branches are filtered", tag), nil
+			}
+			// paths filters are not evaluated for pushes of tags
+			return matchRefFilters("tag", tag, "tags", config.Tags, config.TagsIgnore)
+		}
+		branch := strings.TrimPrefix(f.Ref, "refs/heads/")
+		if len(config.Branches) == 0 && len(config.BranchesIgnore) == 0 && (len(config.Tags) > 0 || len(config.TagsIgnore) > 0) {
+			return fmt.Sprintf("branch '%s' is not matched, only tags are filtered", branch), nil
+		}
+		if reason, err := matchRefFilters("branch", branch, "branches", config.Branches, config.BranchesIgnore); reason != "" || err != nil {
+			return reason, err
+		}
+	case "pull_request", "pull_request_target":
+		if reason, err := matchRefFilters("base branch", strings.TrimPrefix(f.BaseRef, "refs/heads/"), "branches", config.Branches, config.BranchesIgnore); reason != "" || err != nil {
+			return reason, err
+		}
+	default:
+		return "", nil
+	}
+
+	return f.matchPathFilters(config.Paths, config.PathsIgnore)
+}
+
+func matchRefFilters(kind string, name string, key string, include []string, ignore []string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	if len(include) > 0 {
+		patterns, err := workflowpattern.CompilePatterns(include...)
+		if err != nil {
+			return "", err
+		}
+		if workflowpattern.Skip(patterns, []string{name}, &filterTraceWriter{}) {
+			return fmt.Sprintf("%s '%s' doesn't match the %s filter", kind, name, key), nil
+		}
+	}
+	if len(ignore) > 0 {
+		patterns, err := workflowpattern.CompilePatterns(ignore...)
+		if err != nil {
+			return "", err
+		}
+		if workflowpattern.Filter(patterns, []string{name}, &filterTraceWriter{}) {
+			return fmt.Sprintf("%s '%s' is ignored by the %s-ignore filter", kind, name, key), nil
+		}
+	}
+	return "", nil
+}
+
+func (f *EventFilter) matchPathFilters(include []string, ignore []string) (string, error) {
+	if len(include) == 0 && len(ignore) == 0 {
+		return "", nil
+	}
+	if f.ChangedFiles == nil {
+		log.Debugf("Unable to determine the changed files, ignoring the paths filters")
+		return "", nil
+	}
+	if len(include) > 0 {
+		patterns, err := workflowpattern.CompilePatterns(include...)
+		if err != nil {
+			return "", err
+		}
+		if workflowpattern.Skip(patterns, f.ChangedFiles, &filterTraceWriter{}) {
+			return "no changed file matches the paths filter", nil
+		}
+	}
+	if len(ignore) > 0 {
+		patterns, err := workflowpattern.CompilePatterns(ignore...)
+		if err != nil {
+			return "", err
+		}
+		if workflowpattern.Filter(patterns, f.ChangedFiles, &filterTraceWriter{}) {
+			return "all changed files are ignored by the paths-ignore filter", nil
+		}
+	}
+	return "", nil
+}
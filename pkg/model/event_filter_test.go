@@ -0,0 +1,79 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanEventFilters(t *testing.T) {
+	const workflow = `
+name: filtered
+on:
+  push:
+    branches: [main, 'releases/**']
+    paths-ignore: ['docs/**']
+  pull_request:
+    branches-ignore: [experimental]
+    paths: ['src/**']
+jobs:
+  test:
+    runs-on: ubuntu-latest
+    steps:
+      - run: echo
+`
+	const tagsWorkflow = `
+name: tags
+on:
+  push:
+    tags: ['v*']
+jobs:
+  release:
+    runs-on: ubuntu-latest
+    steps:
+      - run: echo
+`
+
+	table := []struct {
+		name     string
+		workflow string
+		event    string
+		filter   *EventFilter
+		reason   string
+	}{
+		{"no filter", workflow, "push", nil, ""},
+		{"matching branch", workflow, "push", &EventFilter{Ref: "refs/heads/releases/v1"}, ""},
+		{"other branch", workflow, "push", &EventFilter{Ref: "refs/heads/feature"}, "branch 'feature' doesn't match the branches filter"},
+		{"tag on branch filter", workflow, "push", &EventFilter{Ref: "refs/tags/v1"}, "tag 'v1' is not matched, only branches are filtered"},
+		{"ignored paths", workflow, "push", &EventFilter{Ref: "refs/heads/main", ChangedFiles: []string{"docs/index.md"}}, "all changed files are ignored by the paths-ignore filter"},
+		{"not only ignored paths", workflow, "push", &EventFilter{Ref: "refs/heads/main", ChangedFiles: []string{"docs/index.md", "main.go"}}, ""},
+		{"unknown changed files", workflow, "push", &EventFilter{Ref: "refs/heads/main"}, ""},
+		{"ignored base branch", workflow, "pull_request", &EventFilter{Ref: "refs/heads/feature", BaseRef: "experimental"}, "base branch 'experimental' is ignored by the branches-ignore filter"},
+		{"no matching paths", workflow, "pull_request", &EventFilter{BaseRef: "main", ChangedFiles: []string{"README.md"}}, "no changed file matches the paths filter"},
+		{"matching paths", workflow, "pull_request", &EventFilter{BaseRef: "main", ChangedFiles: []string{"src/main.go"}}, ""},
+		{"matching tag", tagsWorkflow, "push", &EventFilter{Ref: "refs/tags/v1.0.0"}, ""},
+		{"other tag", tagsWorkflow, "push", &EventFilter{Ref: "refs/tags/nightly"}, "tag 'nightly' doesn't match the tags filter"},
+		{"branch on tag filter", tagsWorkflow, "push", &EventFilter{Ref: "refs/heads/main"}, "branch 'main' is not matched, only tags are filtered"},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.name, func(t *testing.T) {
+			planner, err := NewSingleWorkflowPlanner("workflow.yml", strings.NewReader(tt.workflow))
+			assert.NoError(t, err)
+			planner.(*workflowPlanner).SetEventFilter(tt.filter)
+
+			plan, err := planner.PlanEvent(tt.event)
+			assert.NoError(t, err)
+			if tt.reason == "" {
+				assert.Len(t, plan.Stages, 1)
+				assert.Empty(t, plan.Skipped)
+			} else {
+				assert.Empty(t, plan.Stages)
+				if assert.Len(t, plan.Skipped, 1) {
+					assert.Equal(t, tt.reason, plan.Skipped[0].Reason)
+				}
+			}
+		})
+	}
+}
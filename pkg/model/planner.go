@@ -23,7 +23,8 @@ type WorkflowPlanner interface {
 
 // Plan contains a list of stages to run in series
 type Plan struct {
-	Stages []*Stage
+	Stages  []*Stage
+	Skipped []*SkippedWorkflow // workflows not triggered because of their event filters
 }
 
 // Stage contains a list of runs to execute in parallel
@@ -194,7 +195,14 @@ func validateJobName(workflow *Workflow) error {
 }
 
 type workflowPlanner struct {
-	workflows []*Workflow
+	workflows   []*Workflow
+	eventFilter *EventFilter
+}
+
+// SetEventFilter enables the evaluation of the `on.<event>` branch, tag and path filters in PlanEvent,
+// it isn't part of WorkflowPlanner to keep its implementations outside this package working
+func (wp *workflowPlanner) SetEventFilter(filter *EventFilter) {
+	wp.eventFilter = filter
 }
 
 // PlanEvent builds a new list of runs to execute in parallel for an event name
@@ -215,6 +223,14 @@ func (wp *workflowPlanner) PlanEvent(eventName string) (*Plan, error) {
 
 		for _, e := range events {
 			if e == eventName {
+				reason, err := wp.eventFilter.skipReason(w, eventName)
+				if err != nil {
+					log.Warnf("unable to evaluate the %s filters of workflow '%s': %v", eventName, w.File, err)
+				} else if reason != "" {
+					log.Debugf("skipping workflow '%s': %s", w.File, reason)
+					plan.Skipped = append(plan.Skipped, &SkippedWorkflow{Workflow: w, Reason: reason})
+					continue
+				}
 				stages, err := createStages(w, w.GetJobIDs()...)
 				if err != nil {
 					log.Warn(err)
@@ -248,6 +264,21 @@ func (wp *workflowPlanner) PlanJob(jobName string) (*Plan, error) {
 	return plan, lastErr
 }
 
+// PlanJobWithoutNeeds builds a new run to execute a job name alone, the jobs it needs don't run. It
+// isn't part of WorkflowPlanner to keep its implementations outside this package working.
+func (wp *workflowPlanner) PlanJobWithoutNeeds(jobName string) (*Plan, error) {
+	plan := new(Plan)
+	for _, w := range wp.workflows {
+		if w.GetJob(jobName) != nil {
+			plan.mergeStages([]*Stage{{Runs: []*Run{{Workflow: w, JobID: jobName}}}})
+		}
+	}
+	if len(plan.Stages) == 0 {
+		return nil, fmt.Errorf("no workflow has the job '%s'", jobName)
+	}
+	return plan, nil
+}
+
 // PlanAll builds a new run to execute in parallel all
 func (wp *workflowPlanner) PlanAll() (*Plan, error) {
 	plan := new(Plan)
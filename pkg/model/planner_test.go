@@ -6,6 +6,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
 )
 
 type WorkflowPlanTest struct {
@@ -56,3 +57,24 @@ func TestWorkflow(t *testing.T) {
 	assert.Nil(t, err)
 	assert.NotNil(t, result)
 }
+
+func TestPlanJobWithoutNeeds(t *testing.T) {
+	ci := &Workflow{Name: "CI", Jobs: map[string]*Job{
+		"build":  {},
+		"deploy": {RawNeeds: yaml.Node{Kind: yaml.ScalarNode, Value: "build"}},
+	}}
+	release := &Workflow{Name: "Release", Jobs: map[string]*Job{"publish": {}}}
+	wp := &workflowPlanner{workflows: []*Workflow{ci, release}}
+
+	plan, err := wp.PlanJob("deploy")
+	assert.NoError(t, err)
+	assert.Len(t, plan.Stages, 2)
+
+	// the needed jobs don't run
+	plan, err = wp.PlanJobWithoutNeeds("deploy")
+	assert.NoError(t, err)
+	assert.Equal(t, []*Stage{{Runs: []*Run{{Workflow: ci, JobID: "deploy"}}}}, plan.Stages)
+
+	_, err = wp.PlanJobWithoutNeeds("lint")
+	assert.EqualError(t, err, "no workflow has the job 'lint'")
+}
@@ -1,6 +1,9 @@
 package model
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+)
 
 type stepStatus int
 
@@ -39,7 +42,48 @@ func (s stepStatus) String() string {
 }
 
 type StepResult struct {
-	Outputs    map[string]string `json:"outputs"`
-	Conclusion stepStatus        `json:"conclusion"`
-	Outcome    stepStatus        `json:"outcome"`
+	Outputs     map[string]string `json:"outputs"`
+	Conclusion  stepStatus        `json:"conclusion"`
+	Outcome     stepStatus        `json:"outcome"`
+	Annotations []Annotation      `json:"-"` // not part of the steps context, the history records them
+}
+
+// Annotation is a notice, warning or error reported by a step with a workflow command
+type Annotation struct {
+	Level     string `json:"level"`
+	Message   string `json:"message"`
+	Title     string `json:"title,omitempty"`
+	File      string `json:"file,omitempty"`
+	Line      int    `json:"line,omitempty"`
+	EndLine   int    `json:"endLine,omitempty"`
+	Col       int    `json:"col,omitempty"`
+	EndColumn int    `json:"endColumn,omitempty"`
+}
+
+// Location returns the file position of the annotation, e.g. "main.go:10:5" or "main.go:10-12"
+func (a Annotation) Location() string {
+	if a.File == "" {
+		return ""
+	}
+	location := a.File
+	if a.Line > 0 {
+		location += ":" + strconv.Itoa(a.Line)
+		if a.EndLine > a.Line {
+			location += "-" + strconv.Itoa(a.EndLine)
+		} else if a.Col > 0 {
+			location += ":" + strconv.Itoa(a.Col)
+		}
+	}
+	return location
+}
+
+func (a Annotation) String() string {
+	s := a.Message
+	if a.Title != "" {
+		s = a.Title + ": " + s
+	}
+	if location := a.Location(); location != "" {
+		s = location + ": " + s
+	}
+	return s
 }
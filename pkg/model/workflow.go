@@ -17,12 +17,13 @@ import (
 
 // Workflow is the structure of the files in .github/workflows
 type Workflow struct {
-	File     string
-	Name     string            `yaml:"name"`
-	RawOn    yaml.Node         `yaml:"on"`
-	Env      map[string]string `yaml:"env"`
-	Jobs     map[string]*Job   `yaml:"jobs"`
-	Defaults Defaults          `yaml:"defaults"`
+	File           string
+	Name           string            `yaml:"name"`
+	RawOn          yaml.Node         `yaml:"on"`
+	Env            map[string]string `yaml:"env"`
+	Jobs           map[string]*Job   `yaml:"jobs"`
+	Defaults       Defaults          `yaml:"defaults"`
+	RawConcurrency yaml.Node         `yaml:"concurrency"`
 }
 
 // On events for the workflow
@@ -201,6 +202,8 @@ type Job struct {
 	Uses           string                    `yaml:"uses"`
 	With           map[string]interface{}    `yaml:"with"`
 	RawSecrets     yaml.Node                 `yaml:"secrets"`
+	RawConcurrency yaml.Node                 `yaml:"concurrency"`
+	RawEnvironment yaml.Node                 `yaml:"environment"`
 	Result         string
 }
 
@@ -213,6 +216,63 @@ type Strategy struct {
 	RawMatrix         yaml.Node `yaml:"matrix"`
 }
 
+// Concurrency group of a workflow or job, the values may contain expressions
+type Concurrency struct {
+	Group            string `yaml:"group"`
+	CancelInProgress string `yaml:"cancel-in-progress"`
+}
+
+// Concurrency returns the concurrency group of the workflow, nil if it has none
+func (w *Workflow) Concurrency() *Concurrency {
+	return concurrency(w.RawConcurrency)
+}
+
+// Concurrency returns the concurrency group of the job, nil if it has none
+func (j *Job) Concurrency() *Concurrency {
+	return concurrency(j.RawConcurrency)
+}
+
+func concurrency(node yaml.Node) *Concurrency {
+	var val *Concurrency
+	switch node.Kind {
+	case yaml.ScalarNode:
+		val = new(Concurrency)
+		if !decodeNode(node, &val.Group) {
+			return nil
+		}
+	case yaml.MappingNode:
+		val = new(Concurrency)
+		if !decodeNode(node, val) {
+			return nil
+		}
+	}
+	return val
+}
+
+// DeploymentEnvironment a job deploys to, the values may contain expressions
+type DeploymentEnvironment struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+}
+
+// DeploymentEnvironment returns the deployment environment of the job, nil if it has none
+func (j *Job) DeploymentEnvironment() *DeploymentEnvironment {
+	var val *DeploymentEnvironment
+	switch j.RawEnvironment.Kind {
+	case yaml.ScalarNode:
+		val = new(DeploymentEnvironment)
+		if !decodeNode(j.RawEnvironment, &val.Name) {
+			return nil
+		}
+	case yaml.MappingNode:
+		val = new(DeploymentEnvironment)
+		if !decodeNode(j.RawEnvironment, val) {
+			return nil
+		}
+	}
+	return val
+}
+
 // Default settings that will apply to all steps in the job or workflow
 type Defaults struct {
 	Run RunDefaults `yaml:"run"`
@@ -73,6 +73,66 @@ jobs:
 	assert.Contains(t, workflow.On(), "pull_request")
 }
 
+func TestReadWorkflow_Concurrency(t *testing.T) {
+	yaml := `
+name: deploy
+on: push
+concurrency: deploy-${{ github.ref }}
+
+jobs:
+  deploy:
+    runs-on: ubuntu-latest
+    concurrency:
+      group: production
+      cancel-in-progress: true
+    steps:
+    - run: echo
+  test:
+    runs-on: ubuntu-latest
+    steps:
+    - run: echo
+`
+
+	workflow, err := ReadWorkflow(strings.NewReader(yaml), false)
+	assert.NoError(t, err, "read workflow should succeed")
+
+	assert.Equal(t, &Concurrency{Group: "deploy-${{ github.ref }}"}, workflow.Concurrency())
+	assert.Equal(t, &Concurrency{Group: "production", CancelInProgress: "true"}, workflow.Jobs["deploy"].Concurrency())
+	assert.Nil(t, workflow.Jobs["test"].Concurrency())
+}
+
+func TestReadWorkflow_DeploymentEnvironment(t *testing.T) {
+	yaml := `
+name: deploy
+on: push
+
+jobs:
+  staging:
+    runs-on: ubuntu-latest
+    environment: staging
+    steps:
+    - run: echo
+  production:
+    runs-on: ubuntu-latest
+    environment:
+      name: production
+      url: ${{ steps.deploy.outputs.url }}
+    steps:
+    - run: echo
+  test:
+    runs-on: ubuntu-latest
+    steps:
+    - run: echo
+`
+
+	workflow, err := ReadWorkflow(strings.NewReader(yaml), false)
+	assert.NoError(t, err, "read workflow should succeed")
+
+	assert.Equal(t, &DeploymentEnvironment{Name: "staging"}, workflow.Jobs["staging"].DeploymentEnvironment())
+	assert.Equal(t, &DeploymentEnvironment{Name: "production", URL: "${{ steps.deploy.outputs.url }}"}, workflow.Jobs["production"].DeploymentEnvironment())
+	assert.Nil(t, workflow.Jobs["test"].DeploymentEnvironment())
+}
+
 func TestReadWorkflow_RunsOnLabels(t *testing.T) {
 	yaml := `
 name: local-action-docker-url
@@ -0,0 +1,115 @@
+package oidc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RequestTokenLifetime is how long a job can use its request token to ask for OIDC tokens
+const RequestTokenLifetime = 24 * time.Hour
+
+// LocalIssuerHost is the issuer host of a local-only OIDC server, job containers reach it through the
+// gateway of their docker network
+const LocalIssuerHost = "host.docker.internal"
+
+// Claims are the claims of a GitHub Actions OIDC token, see
+// https://docs.github.com/en/actions/security-for-github-actions/security-hardening-your-deployments/about-security-hardening-with-openid-connect#understanding-the-oidc-token
+type Claims struct {
+	jwt.RegisteredClaims
+	// Audience shadows the audience of the registered claims, which is marshalled as an array,
+	// while GitHub's tokens contain a single string
+	Audience          string `json:"aud,omitempty"`
+	Ref               string `json:"ref,omitempty"`
+	RefType           string `json:"ref_type,omitempty"`
+	Sha               string `json:"sha,omitempty"`
+	Repository        string `json:"repository,omitempty"`
+	RepositoryOwner   string `json:"repository_owner,omitempty"`
+	Actor             string `json:"actor,omitempty"`
+	Workflow          string `json:"workflow,omitempty"`
+	WorkflowRef       string `json:"workflow_ref,omitempty"`
+	WorkflowSha       string `json:"workflow_sha,omitempty"`
+	JobWorkflowRef    string `json:"job_workflow_ref,omitempty"`
+	JobWorkflowSha    string `json:"job_workflow_sha,omitempty"`
+	RunID             string `json:"run_id,omitempty"`
+	RunNumber         string `json:"run_number,omitempty"`
+	RunAttempt        string `json:"run_attempt,omitempty"`
+	EventName         string `json:"event_name,omitempty"`
+	HeadRef           string `json:"head_ref"`
+	BaseRef           string `json:"base_ref"`
+	Environment       string `json:"environment,omitempty"`
+	RunnerEnvironment string `json:"runner_environment,omitempty"`
+}
+
+// SupportedClaims lists the claims of the minted tokens, for the discovery document
+var SupportedClaims = []string{
+	"iss", "sub", "aud", "exp", "iat", "nbf", "jti",
+	"ref", "ref_type", "sha", "repository", "repository_owner", "actor",
+	"workflow", "workflow_ref", "workflow_sha", "job_workflow_ref", "job_workflow_sha",
+	"run_id", "run_number", "run_attempt", "event_name", "head_ref", "base_ref",
+	"environment", "runner_environment",
+}
+
+// GetAudience implements jwt.Claims
+func (c Claims) GetAudience() (jwt.ClaimStrings, error) {
+	if c.Audience == "" {
+		return nil, nil
+	}
+	return jwt.ClaimStrings{c.Audience}, nil
+}
+
+// DefaultSubject formats the subject the way GitHub does without a customized subject template:
+// the environment takes precedence over the pull_request event, which takes precedence over the ref
+func (c *Claims) DefaultSubject() string {
+	switch {
+	case c.Environment != "":
+		return fmt.Sprintf("repo:%s:environment:%s", c.Repository, c.Environment)
+	case c.EventName == "pull_request":
+		return fmt.Sprintf("repo:%s:pull_request", c.Repository)
+	default:
+		return fmt.Sprintf("repo:%s:ref:%s", c.Repository, c.Ref)
+	}
+}
+
+// NewRequestToken signs the claims of a job, the job exchanges it for OIDC tokens
+func NewRequestToken(secret string, claims Claims, now time.Time) (string, error) {
+	if secret == "" {
+		return "", errors.New("an empty secret can't sign a request token")
+	}
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(RequestTokenLifetime))
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+}
+
+// ParseRequestToken verifies a request token and returns the claims of the job it was issued to
+func ParseRequestToken(secret string, token string) (*Claims, error) {
+	if secret == "" {
+		return nil, errors.New("an empty secret can't verify a request token")
+	}
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(strings.TrimSpace(token), claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, fmt.Errorf("invalid request token: %w", err)
+	}
+	return claims, nil
+}
+
+// ForAudience returns the claims of an OIDC token minted from the claims of a request token
+func (c *Claims) ForAudience(issuer string, audience string, id string, now time.Time) *Claims {
+	token := *c
+	token.RegisteredClaims = jwt.RegisteredClaims{
+		Issuer:    issuer,
+		Subject:   c.DefaultSubject(),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		NotBefore: jwt.NewNumericDate(now),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ID:        id,
+	}
+	token.Audience = audience
+	return &token
+}
@@ -0,0 +1,74 @@
+package oidc
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultSubject(t *testing.T) {
+	table := []struct {
+		name   string
+		claims Claims
+		sub    string
+	}{
+		{"branch", Claims{Repository: "octo-org/octo-repo", EventName: "push", Ref: "refs/heads/main"}, "repo:octo-org/octo-repo:ref:refs/heads/main"},
+		{"tag", Claims{Repository: "octo-org/octo-repo", EventName: "push", Ref: "refs/tags/v1.0.0"}, "repo:octo-org/octo-repo:ref:refs/tags/v1.0.0"},
+		{"pull request", Claims{Repository: "octo-org/octo-repo", EventName: "pull_request", Ref: "refs/pull/1/merge"}, "repo:octo-org/octo-repo:pull_request"},
+		{"pull request target", Claims{Repository: "octo-org/octo-repo", EventName: "pull_request_target", Ref: "refs/heads/main"}, "repo:octo-org/octo-repo:ref:refs/heads/main"},
+		{"environment", Claims{Repository: "octo-org/octo-repo", EventName: "pull_request", Environment: "production"}, "repo:octo-org/octo-repo:environment:production"},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.sub, tt.claims.DefaultSubject())
+		})
+	}
+}
+
+func TestRequestToken(t *testing.T) {
+	now := time.Now()
+	token, err := NewRequestToken("secret", Claims{Repository: "octo-org/octo-repo", Ref: "refs/heads/main"}, now)
+	require.NoError(t, err)
+
+	claims, err := ParseRequestToken("secret", token)
+	require.NoError(t, err)
+	assert.Equal(t, "octo-org/octo-repo", claims.Repository)
+	assert.Equal(t, now.Add(RequestTokenLifetime).Unix(), claims.ExpiresAt.Unix())
+
+	_, err = ParseRequestToken("other", token)
+	assert.Error(t, err)
+
+	_, err = ParseRequestToken("secret", "secret")
+	assert.Error(t, err)
+
+	expired, err := NewRequestToken("secret", Claims{}, now.Add(-2*RequestTokenLifetime))
+	require.NoError(t, err)
+	_, err = ParseRequestToken("secret", expired)
+	assert.Error(t, err)
+
+	_, err = NewRequestToken("", Claims{}, now)
+	assert.Error(t, err)
+}
+
+func TestForAudience(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	request := &Claims{Repository: "octo-org/octo-repo", Ref: "refs/heads/main", EventName: "push"}
+	request.Subject = "ignored"
+
+	data, err := json.Marshal(request.ForAudience("https://issuer.example", "sts.amazonaws.com", "id", now))
+	require.NoError(t, err)
+
+	var claims map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &claims))
+	assert.Equal(t, "https://issuer.example", claims["iss"])
+	assert.Equal(t, "repo:octo-org/octo-repo:ref:refs/heads/main", claims["sub"])
+	assert.Equal(t, "sts.amazonaws.com", claims["aud"])
+	assert.Equal(t, "id", claims["jti"])
+	assert.Equal(t, float64(now.Add(time.Hour).Unix()), claims["exp"])
+	assert.Equal(t, "", claims["base_ref"])
+	assert.NotContains(t, claims, "environment")
+}
@@ -0,0 +1,237 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// keyBits is the size of the generated RSA signing keys
+const keyBits = 2048
+
+// Key is an RSA key signing the OIDC tokens
+type Key struct {
+	ID          string
+	PrivateKey  *rsa.PrivateKey
+	CreatedAt   time.Time
+	ActivatesAt time.Time // the key signs the tokens from then on, it is published before
+	ExpiresAt   time.Time // set once a newer key was scheduled to sign, it stays published until then
+}
+
+// KeySet holds the keys published in the JWKS: the next signing key, published ahead of signing so the
+// identity providers caching the JWKS know it, the signing key and the rotated out keys
+type KeySet struct {
+	Keys []*Key // ordered by activation, the newest first
+}
+
+// JWK is the public part of a signing key, as published in the JWKS
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type keyFile struct {
+	ID          string    `json:"kid"`
+	PrivateKey  string    `json:"private_key"`
+	CreatedAt   time.Time `json:"created_at"`
+	ActivatesAt time.Time `json:"activates_at,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at,omitempty"`
+}
+
+// NewKey generates a signing key, its ID is the RFC 7638 thumbprint of the public key
+func NewKey(now time.Time) (*Key, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{ID: keyID(&privateKey.PublicKey), PrivateKey: privateKey, CreatedAt: now}, nil
+}
+
+func keyID(publicKey *rsa.PublicKey) string {
+	jwk := newJWK("", publicKey)
+	// the members of the thumbprint are required to be in lexicographic order
+	thumbprint, _ := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{jwk.E, jwk.Kty, jwk.N})
+	sum := sha256.Sum256(thumbprint)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func newJWK(id string, publicKey *rsa.PublicKey) JWK {
+	return JWK{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: "RS256",
+		Kid: id,
+		N:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+	}
+}
+
+// LoadKeySet reads the key set from the file, generating and saving a signing key if there is none
+func LoadKeySet(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err := NewKey(time.Now())
+		if err != nil {
+			return nil, err
+		}
+		ks := &KeySet{Keys: []*Key{key}}
+		return ks, ks.Save(path)
+	} else if err != nil {
+		return nil, err
+	}
+
+	var files []keyFile
+	if err := json.Unmarshal(data, &files); err != nil {
+		return nil, fmt.Errorf("unable to read the OIDC keys in '%s': %w", path, err)
+	}
+	ks := &KeySet{}
+	for _, f := range files {
+		block, _ := pem.Decode([]byte(f.PrivateKey))
+		if block == nil {
+			return nil, fmt.Errorf("unable to read the OIDC key '%s' in '%s': no PEM data", f.ID, path)
+		}
+		privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the OIDC key '%s' in '%s': %w", f.ID, path, err)
+		}
+		ks.Keys = append(ks.Keys, &Key{ID: f.ID, PrivateKey: privateKey, CreatedAt: f.CreatedAt, ActivatesAt: f.ActivatesAt, ExpiresAt: f.ExpiresAt})
+	}
+	if len(ks.Keys) == 0 {
+		return nil, fmt.Errorf("no OIDC signing key in '%s'", path)
+	}
+	return ks, nil
+}
+
+// Save writes the key set to the file, only the current user can read it
+func (ks *KeySet) Save(path string) error {
+	files := make([]keyFile, 0, len(ks.Keys))
+	for _, key := range ks.Keys {
+		files = append(files, keyFile{
+			ID:          key.ID,
+			PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key.PrivateKey)})),
+			CreatedAt:   key.CreatedAt,
+			ActivatesAt: key.ActivatesAt,
+			ExpiresAt:   key.ExpiresAt,
+		})
+	}
+	data, err := json.MarshalIndent(files, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	// write a temporary file first, so a running server never reads a partial key set
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// SigningKey returns the key signing the tokens, the newest published key that is active
+func (ks *KeySet) SigningKey(now time.Time) *Key {
+	var signing *Key
+	for _, key := range ks.Keys {
+		if key.ActivatesAt.After(now) || !key.published(now) {
+			continue
+		}
+		if signing == nil || key.ActivatesAt.After(signing.ActivatesAt) {
+			signing = key
+		}
+	}
+	if signing == nil {
+		// no key is active, e.g. in a key set edited by hand
+		signing = ks.Keys[0]
+	}
+	return signing
+}
+
+// Rotate generates the next signing key, published right away and signing after the overlap, like GitHub
+// publishes its next key ahead of time. The key published by the previous rotation, if it doesn't sign yet,
+// signs from now on. A key that was replaced stays published for the overlap after its successor started
+// signing, so the tokens it signed can still be verified, and expired keys are dropped.
+func (ks *KeySet) Rotate(now time.Time, overlap time.Duration) (*Key, error) {
+	key, err := NewKey(now)
+	if err != nil {
+		return nil, err
+	}
+	key.ActivatesAt = now.Add(overlap)
+	keys := []*Key{key}
+	for _, old := range ks.Keys {
+		if old.ActivatesAt.After(now) {
+			// the identity providers had the time since the previous rotation to fetch it
+			old.ActivatesAt = now
+		}
+		keys = append(keys, old)
+	}
+	ks.Keys = []*Key{}
+	for i, k := range keys {
+		if i > 0 {
+			if expiresAt := keys[i-1].ActivatesAt.Add(overlap); k.ExpiresAt.IsZero() || expiresAt.Before(k.ExpiresAt) {
+				k.ExpiresAt = expiresAt
+			}
+		}
+		if k.published(now) {
+			ks.Keys = append(ks.Keys, k)
+		}
+	}
+	return key, nil
+}
+
+func (k *Key) published(now time.Time) bool {
+	return k.ExpiresAt.IsZero() || k.ExpiresAt.After(now)
+}
+
+// JWKS returns the public keys the tokens can be verified with, including the next signing key
+func (ks *KeySet) JWKS(now time.Time) []JWK {
+	jwks := []JWK{}
+	for _, key := range ks.Keys {
+		if key.published(now) {
+			jwks = append(jwks, newJWK(key.ID, &key.PrivateKey.PublicKey))
+		}
+	}
+	return jwks
+}
+
+// PublicKey returns the public key with the ID, if it is published
+func (ks *KeySet) PublicKey(id string, now time.Time) (crypto.PublicKey, bool) {
+	for _, key := range ks.Keys {
+		if key.ID == id && key.published(now) {
+			return &key.PrivateKey.PublicKey, true
+		}
+	}
+	return nil, false
+}
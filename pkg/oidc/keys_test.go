@@ -0,0 +1,88 @@
+package oidc
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadKeySet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gha", "oidc-keys.json")
+
+	created, err := LoadKeySet(path)
+	require.NoError(t, err)
+	require.Len(t, created.Keys, 1)
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+
+	loaded, err := LoadKeySet(path)
+	require.NoError(t, err)
+	now := time.Now()
+	assert.Equal(t, created.SigningKey(now).ID, loaded.SigningKey(now).ID)
+	assert.True(t, created.SigningKey(now).PrivateKey.Equal(loaded.SigningKey(now).PrivateKey))
+
+	// the activation of a rotated key is saved
+	next, err := loaded.Rotate(now, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, loaded.Save(path))
+	reloaded, err := LoadKeySet(path)
+	require.NoError(t, err)
+	assert.Equal(t, created.SigningKey(now).ID, reloaded.SigningKey(now).ID)
+	assert.Equal(t, next.ID, reloaded.SigningKey(now.Add(time.Hour)).ID)
+}
+
+func TestKeySetRotate(t *testing.T) {
+	now := time.Now()
+	first, err := NewKey(now)
+	require.NoError(t, err)
+	ks := &KeySet{Keys: []*Key{first}}
+
+	// the rotated key is published before it signs, the identity providers caching the JWKS know it
+	second, err := ks.Rotate(now, time.Hour)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ID, second.ID)
+	assert.Equal(t, []string{second.ID, first.ID}, jwkIDs(ks.JWKS(now)))
+	_, ok := ks.PublicKey(second.ID, now)
+	assert.True(t, ok)
+	assert.Equal(t, first, ks.SigningKey(now))
+	assert.Equal(t, first, ks.SigningKey(now.Add(59*time.Minute)))
+
+	// it signs after the overlap, the replaced key stays published for the overlap
+	assert.Equal(t, second, ks.SigningKey(now.Add(time.Hour)))
+	_, ok = ks.PublicKey(first.ID, now.Add(90*time.Minute))
+	assert.True(t, ok)
+	_, ok = ks.PublicKey(first.ID, now.Add(2*time.Hour))
+	assert.False(t, ok)
+	assert.Equal(t, []string{second.ID}, jwkIDs(ks.JWKS(now.Add(2*time.Hour))))
+
+	// expired keys are dropped by the next rotation
+	third, err := ks.Rotate(now.Add(2*time.Hour), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, []string{third.ID, second.ID}, jwkIDs(ks.JWKS(now.Add(2*time.Hour))))
+	assert.Equal(t, second, ks.SigningKey(now.Add(2*time.Hour)))
+
+	// a later rotation promotes the published key right away
+	fourth, err := ks.Rotate(now.Add(150*time.Minute), time.Hour)
+	require.NoError(t, err)
+	later := now.Add(150 * time.Minute)
+	assert.Equal(t, third, ks.SigningKey(later))
+	assert.Equal(t, []string{fourth.ID, third.ID, second.ID}, jwkIDs(ks.JWKS(later)))
+	assert.Equal(t, later.Add(time.Hour), second.ExpiresAt)
+	assert.Equal(t, fourth, ks.SigningKey(later.Add(time.Hour)))
+}
+
+func jwkIDs(jwks []JWK) []string {
+	ids := []string{}
+	for _, jwk := range jwks {
+		ids = append(ids, jwk.Kid)
+	}
+	return ids
+}
@@ -0,0 +1,315 @@
+package oidc
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// assumeRoleAction is the action of exchanging an OIDC token for AWS credentials
+const assumeRoleAction = "sts:AssumeRoleWithWebIdentity"
+
+// TrustPolicy is an AWS IAM role trust policy
+type TrustPolicy struct {
+	Version   string        `json:"Version"`
+	Statement statementList `json:"Statement"`
+}
+
+// PolicyStatement is a statement of a trust policy
+type PolicyStatement struct {
+	Sid       string                           `json:"Sid"`
+	Effect    string                           `json:"Effect"`
+	Action    stringList                       `json:"Action"`
+	Principal map[string]json.RawMessage       `json:"Principal"`
+	Condition map[string]map[string]stringList `json:"Condition"`
+}
+
+// statementList is a single statement or a list of them
+type statementList []PolicyStatement
+
+func (l *statementList) UnmarshalJSON(data []byte) error {
+	var statement PolicyStatement
+	if err := json.Unmarshal(data, &statement); err == nil {
+		*l = statementList{statement}
+		return nil
+	}
+	var statements []PolicyStatement
+	if err := json.Unmarshal(data, &statements); err != nil {
+		return err
+	}
+	*l = statements
+	return nil
+}
+
+// stringList is a single value or a list of them, booleans and numbers are compared as strings
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var values []interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		values = []interface{}{value}
+	}
+	*l = make(stringList, 0, len(values))
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			*l = append(*l, v)
+		case bool, float64:
+			*l = append(*l, fmt.Sprint(v))
+		default:
+			return fmt.Errorf("unsupported policy value %v", v)
+		}
+	}
+	return nil
+}
+
+// ParseTrustPolicy reads an AWS trust policy document
+func ParseTrustPolicy(data []byte) (*TrustPolicy, error) {
+	policy := &TrustPolicy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("invalid trust policy: %w", err)
+	}
+	if len(policy.Statement) == 0 {
+		return nil, fmt.Errorf("invalid trust policy: it has no statements")
+	}
+	return policy, nil
+}
+
+// PolicyEvaluation is the result of evaluating a trust policy for a token
+type PolicyEvaluation struct {
+	Allowed    bool
+	Statements []*StatementEvaluation
+}
+
+// StatementEvaluation is the result of evaluating a statement, it matches if it applies to the
+// token and all its conditions pass
+type StatementEvaluation struct {
+	Index      int
+	Sid        string
+	Effect     string
+	Reason     string // why the statement doesn't apply to the token
+	Conditions []*ConditionEvaluation
+	Matched    bool
+}
+
+// ConditionEvaluation is the result of evaluating one key of a condition operator
+type ConditionEvaluation struct {
+	Operator string
+	Key      string
+	Values   []string // the values of the policy
+	Actual   []string // the values of the token
+	Passed   bool
+	Reason   string
+}
+
+// Evaluate evaluates the policy for an AssumeRoleWithWebIdentity call with a token of the issuer.
+// Like AWS, a matching Deny statement wins over the matching Allow statements.
+func (p *TrustPolicy) Evaluate(issuer string, claims map[string]interface{}) *PolicyEvaluation {
+	host := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(issuer, "https://"), "http://"), "/")
+	result := &PolicyEvaluation{}
+	allowed, denied := false, false
+	for i, statement := range p.Statement {
+		evaluation := statement.evaluate(host, claims)
+		evaluation.Index = i
+		result.Statements = append(result.Statements, evaluation)
+		if !evaluation.Matched {
+			continue
+		}
+		if strings.EqualFold(statement.Effect, "Deny") {
+			denied = true
+		} else if strings.EqualFold(statement.Effect, "Allow") {
+			allowed = true
+		}
+	}
+	result.Allowed = allowed && !denied
+	return result
+}
+
+func (s *PolicyStatement) evaluate(host string, claims map[string]interface{}) *StatementEvaluation {
+	result := &StatementEvaluation{Sid: s.Sid, Effect: s.Effect}
+
+	if !s.allowsAction() {
+		result.Reason = fmt.Sprintf("its actions %v don't include %s", []string(s.Action), assumeRoleAction)
+		return result
+	}
+	if federated, ok := s.Principal["Federated"]; ok {
+		var principals stringList
+		// the principal can't be checked if it refers to a resource of a template
+		if json.Unmarshal(federated, &principals) == nil && !principalsTrust(principals, host) {
+			result.Reason = fmt.Sprintf("its federated principal %v doesn't trust the issuer '%s'", []string(principals), host)
+			return result
+		}
+	} else if len(s.Principal) > 0 {
+		result.Reason = "it has no federated principal"
+		return result
+	}
+
+	result.Matched = true
+	operators := make([]string, 0, len(s.Condition))
+	for operator := range s.Condition {
+		operators = append(operators, operator)
+	}
+	sort.Strings(operators)
+	for _, operator := range operators {
+		keys := make([]string, 0, len(s.Condition[operator]))
+		for key := range s.Condition[operator] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			condition := evaluateCondition(operator, key, s.Condition[operator][key], host, claims)
+			result.Conditions = append(result.Conditions, condition)
+			result.Matched = result.Matched && condition.Passed
+		}
+	}
+	return result
+}
+
+func (s *PolicyStatement) allowsAction() bool {
+	for _, action := range s.Action {
+		if matchesPattern(strings.ToLower(assumeRoleAction), strings.ToLower(action)) {
+			return true
+		}
+	}
+	return false
+}
+
+func principalsTrust(principals []string, host string) bool {
+	for _, principal := range principals {
+		if principal == host || strings.HasSuffix(principal, ":oidc-provider/"+host) {
+			return true
+		}
+	}
+	return false
+}
+
+func evaluateCondition(operator string, key string, values []string, host string, claims map[string]interface{}) *ConditionEvaluation {
+	result := &ConditionEvaluation{Operator: operator, Key: key, Values: values}
+
+	claim, ok := strings.CutPrefix(key, host+":")
+	if !ok {
+		if strings.HasPrefix(strings.ToLower(key), "aws:") {
+			result.Passed = true
+			result.Reason = "global condition keys aren't evaluated locally"
+		} else {
+			result.Reason = fmt.Sprintf("the key is not for the issuer '%s'", host)
+		}
+		return result
+	}
+
+	base, qualifier, ifExists := parseConditionOperator(operator)
+	match, negated, ok := conditionMatcher(base)
+	if !ok {
+		result.Reason = fmt.Sprintf("the operator '%s' is not supported", operator)
+		return result
+	}
+
+	actual, exists := claimValues(claims[claim])
+	result.Actual = actual
+	if !exists {
+		// a missing claim passes negated operators and IfExists conditions
+		result.Passed = ifExists || negated || qualifier == "ForAllValues"
+		if !result.Passed {
+			result.Reason = fmt.Sprintf("the token has no '%s' claim", claim)
+		}
+		return result
+	}
+
+	matchesAny := func(value string) bool {
+		for _, expected := range values {
+			if match(value, expected) {
+				return true
+			}
+		}
+		return false
+	}
+	if qualifier == "ForAllValues" {
+		result.Passed = true
+		for _, value := range actual {
+			result.Passed = result.Passed && matchesAny(value) != negated
+		}
+	} else {
+		for _, value := range actual {
+			result.Passed = result.Passed || matchesAny(value) != negated
+		}
+	}
+	if !result.Passed {
+		if negated {
+			result.Reason = fmt.Sprintf("'%s' is %v", claim, actual)
+		} else {
+			result.Reason = fmt.Sprintf("'%s' is %v, which doesn't match %v", claim, actual, values)
+		}
+	}
+	return result
+}
+
+func parseConditionOperator(operator string) (base string, qualifier string, ifExists bool) {
+	base = operator
+	if q, rest, ok := strings.Cut(base, ":"); ok {
+		qualifier, base = q, rest
+	}
+	base, ifExists = strings.CutSuffix(base, "IfExists")
+	return base, qualifier, ifExists
+}
+
+func conditionMatcher(operator string) (func(actual string, expected string) bool, bool, bool) {
+	switch operator {
+	case "StringEquals":
+		return stringEquals, false, true
+	case "StringNotEquals":
+		return stringEquals, true, true
+	case "StringEqualsIgnoreCase":
+		return strings.EqualFold, false, true
+	case "StringNotEqualsIgnoreCase":
+		return strings.EqualFold, true, true
+	case "StringLike":
+		return matchesPattern, false, true
+	case "StringNotLike":
+		return matchesPattern, true, true
+	default:
+		return nil, false, false
+	}
+}
+
+func stringEquals(actual string, expected string) bool {
+	return actual == expected
+}
+
+// matchesPattern matches the wildcards of StringLike, '*' matches any characters and '?' a single one
+func matchesPattern(actual string, pattern string) bool {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String()).MatchString(actual)
+}
+
+func claimValues(claim interface{}) ([]string, bool) {
+	switch v := claim.(type) {
+	case nil:
+		return nil, false
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, value := range v {
+			values = append(values, fmt.Sprint(value))
+		}
+		return values, true
+	default:
+		return []string{fmt.Sprint(v)}, true
+	}
+}
@@ -0,0 +1,119 @@
+package oidc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": {
+    "Effect": "Allow",
+    "Principal": {"Federated": "arn:aws:iam::123456789012:oidc-provider/oidc.example.com"},
+    "Action": "sts:AssumeRoleWithWebIdentity",
+    "Condition": {
+      "StringEquals": {"oidc.example.com:aud": "sts.amazonaws.com"},
+      "StringLike": {"oidc.example.com:sub": ["repo:octo-org/octo-repo:ref:refs/heads/*", "repo:octo-org/octo-repo:environment:prod?"]}
+    }
+  }
+}`
+
+func TestTrustPolicyEvaluate(t *testing.T) {
+	policy, err := ParseTrustPolicy([]byte(testPolicy))
+	require.NoError(t, err)
+
+	table := []struct {
+		name    string
+		issuer  string
+		claims  map[string]interface{}
+		allowed bool
+		reason  string
+	}{
+		{"branch", "https://oidc.example.com", map[string]interface{}{"aud": "sts.amazonaws.com", "sub": "repo:octo-org/octo-repo:ref:refs/heads/main"}, true, ""},
+		{"environment", "https://oidc.example.com/", map[string]interface{}{"aud": "sts.amazonaws.com", "sub": "repo:octo-org/octo-repo:environment:prod1"}, true, ""},
+		{"audience", "https://oidc.example.com", map[string]interface{}{"aud": "api://AzureADTokenExchange", "sub": "repo:octo-org/octo-repo:ref:refs/heads/main"}, false,
+			"'aud' is [api://AzureADTokenExchange], which doesn't match [sts.amazonaws.com]"},
+		{"subject", "https://oidc.example.com", map[string]interface{}{"aud": "sts.amazonaws.com", "sub": "repo:octo-org/octo-repo:pull_request"}, false,
+			"'sub' is [repo:octo-org/octo-repo:pull_request], which doesn't match [repo:octo-org/octo-repo:ref:refs/heads/* repo:octo-org/octo-repo:environment:prod?]"},
+		{"missing claim", "https://oidc.example.com", map[string]interface{}{"aud": "sts.amazonaws.com"}, false, "the token has no 'sub' claim"},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluation := policy.Evaluate(tt.issuer, tt.claims)
+			assert.Equal(t, tt.allowed, evaluation.Allowed)
+			require.Len(t, evaluation.Statements, 1)
+			var reasons []string
+			for _, condition := range evaluation.Statements[0].Conditions {
+				if !condition.Passed {
+					reasons = append(reasons, condition.Reason)
+				}
+			}
+			if tt.reason == "" {
+				assert.Empty(t, reasons)
+			} else {
+				assert.Equal(t, []string{tt.reason}, reasons)
+			}
+		})
+	}
+}
+
+func TestTrustPolicyEvaluateIssuer(t *testing.T) {
+	policy, err := ParseTrustPolicy([]byte(testPolicy))
+	require.NoError(t, err)
+
+	evaluation := policy.Evaluate("https://other.example.com", map[string]interface{}{"aud": "sts.amazonaws.com"})
+	assert.False(t, evaluation.Allowed)
+	assert.Equal(t, "its federated principal [arn:aws:iam::123456789012:oidc-provider/oidc.example.com] doesn't trust the issuer 'other.example.com'", evaluation.Statements[0].Reason)
+}
+
+func TestTrustPolicyEvaluateDeny(t *testing.T) {
+	policy, err := ParseTrustPolicy([]byte(`{
+  "Statement": [
+    {"Effect": "Allow", "Principal": {"Federated": {"Ref": "OIDCProvider"}}, "Action": ["sts:AssumeRoleWithWebIdentity", "sts:TagSession"],
+     "Condition": {"StringEquals": {"oidc.example.com:aud": "sts.amazonaws.com"}}},
+    {"Sid": "NoPullRequests", "Effect": "Deny", "Principal": {"Federated": "oidc.example.com"}, "Action": "sts:*",
+     "Condition": {"StringEquals": {"oidc.example.com:event_name": "pull_request"}, "StringNotEqualsIfExists": {"oidc.example.com:environment": "prod"}}}
+  ]
+}`))
+	require.NoError(t, err)
+
+	claims := map[string]interface{}{"aud": "sts.amazonaws.com", "event_name": "push"}
+	assert.True(t, policy.Evaluate("https://oidc.example.com", claims).Allowed)
+
+	claims["event_name"] = "pull_request"
+	evaluation := policy.Evaluate("https://oidc.example.com", claims)
+	assert.False(t, evaluation.Allowed)
+	assert.True(t, evaluation.Statements[1].Matched)
+
+	claims["environment"] = "prod"
+	assert.True(t, policy.Evaluate("https://oidc.example.com", claims).Allowed)
+}
+
+func TestConditionOperators(t *testing.T) {
+	claims := map[string]interface{}{"aud": []interface{}{"a", "b"}, "ref": "refs/heads/Main"}
+	table := []struct {
+		operator string
+		key      string
+		values   []string
+		passed   bool
+	}{
+		{"StringEqualsIgnoreCase", "ref", []string{"refs/heads/main"}, true},
+		{"StringEquals", "ref", []string{"refs/heads/main"}, false},
+		{"StringNotLike", "ref", []string{"refs/tags/*"}, true},
+		{"ForAnyValue:StringEquals", "aud", []string{"b"}, true},
+		{"ForAllValues:StringEquals", "aud", []string{"b"}, false},
+		{"ForAllValues:StringLike", "aud", []string{"?"}, true},
+		{"StringEqualsIfExists", "environment", []string{"prod"}, true},
+		{"NumericEquals", "ref", []string{"1"}, false},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.operator, func(t *testing.T) {
+			condition := evaluateCondition(tt.operator, "oidc.example.com:"+tt.key, tt.values, "oidc.example.com", claims)
+			assert.Equal(t, tt.passed, condition.Passed, condition.Reason)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// Status is the state of the OIDC server, written by 'gha oidc start' and read by the jobs
+type Status struct {
+	Running   bool   `json:"running"`
+	PID       int    `json:"pid,omitempty"`
+	Port      int    `json:"port,omitempty"`
+	Provider  string `json:"provider,omitempty"`
+	IssuerURL string `json:"issuer_url,omitempty"`
+	TunnelPID int    `json:"tunnel_pid,omitempty"`
+	StartTime string `json:"start_time,omitempty"`
+	Password  string `json:"password,omitempty"`
+
+	// NgrokURL and NgrokPID are read from the status of older gha versions
+	NgrokURL string `json:"ngrok_url,omitempty"`
+	NgrokPID int    `json:"ngrok_pid,omitempty"`
+}
+
+// LoadStatus reads the status from the file, a missing file is the status of a stopped server. The status
+// of older gha versions, which always used ngrok, is migrated.
+func LoadStatus(path string) (*Status, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Status{Running: false}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var status Status
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, err
+	}
+	if status.IssuerURL == "" && status.NgrokURL != "" {
+		status.Provider = "ngrok"
+		status.IssuerURL = status.NgrokURL
+		status.TunnelPID = status.NgrokPID
+	}
+	status.NgrokURL = ""
+	status.NgrokPID = 0
+	return &status, nil
+}
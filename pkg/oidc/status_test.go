@@ -0,0 +1,33 @@
+package oidc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadStatus(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "oidc-status.json")
+
+	status, err := LoadStatus(path)
+	require.NoError(t, err)
+	assert.Equal(t, &Status{Running: false}, status)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"running": true, "pid": 10, "provider": "cloudflared", "issuer_url": "https://oidc.trycloudflare.com", "tunnel_pid": 11, "password": "password"}`), 0o600))
+	status, err = LoadStatus(path)
+	require.NoError(t, err)
+	assert.Equal(t, &Status{Running: true, PID: 10, Provider: "cloudflared", IssuerURL: "https://oidc.trycloudflare.com", TunnelPID: 11, Password: "password"}, status)
+
+	// the status of older versions always used ngrok
+	require.NoError(t, os.WriteFile(path, []byte(`{"running": true, "pid": 10, "ngrok_url": "https://oidc.ngrok.app", "ngrok_pid": 11, "password": "password"}`), 0o600))
+	status, err = LoadStatus(path)
+	require.NoError(t, err)
+	assert.Equal(t, &Status{Running: true, PID: 10, Provider: "ngrok", IssuerURL: "https://oidc.ngrok.app", TunnelPID: 11, Password: "password"}, status)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{`), 0o600))
+	_, err = LoadStatus(path)
+	assert.Error(t, err)
+}
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/tracing"
 	git "github.com/go-git/go-git/v5"
 	config "github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
@@ -27,6 +28,16 @@ type ActionCache interface {
 	GetTarArchive(ctx context.Context, cacheDir, sha, includePrefix string) (io.ReadCloser, error)
 }
 
+// fetchAction fetches the ref of the repository of an action or reusable workflow with the cache, in a span
+func fetchAction(ctx context.Context, cache ActionCache, cacheDir, url, ref, token string) (string, error) {
+	ctx, span := tracing.Start(ctx, fmt.Sprintf("fetch %s@%s", url, ref), tracing.String("gha.action.url", url), tracing.String("gha.action.ref", ref))
+	defer span.End()
+	sha, err := cache.Fetch(ctx, cacheDir, url, ref, token)
+	span.SetError(err)
+	span.SetAttributes(tracing.String("gha.action.sha", sha))
+	return sha, err
+}
+
 type GoGitActionCache struct {
 	Path string
 }
@@ -203,7 +203,7 @@ func (rc *RunContext) newCompositeCommandExecutor(executor common.Executor) comm
 		// We need this, to support scoping commands to the composite action
 		// executing.
 		rawLogger := common.Logger(ctx).WithField("raw_output", true)
-		logWriter := common.NewLineWriter(rc.commandHandler(ctx), func(s string) bool {
+		logWriter := common.NewLineWriter(rc.commandHandler(ctx), rc.historyLineHandler(), func(s string) bool {
 			if rc.Config.LogOutput {
 				rawLogger.Infof("%s", s)
 			} else {
@@ -0,0 +1,88 @@
+package runner
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+// ActionMock substitutes the actions of the steps with the `uses` matching a pattern, like the actions that
+// notify, release or deploy and can't run locally. The steps run the local Action instead, or set the
+// Outputs without running, or succeed without running when Noop is set.
+type ActionMock struct {
+	Uses    string            // pattern of the `uses` of the steps, * matches any characters (e.g. slackapi/slack-github-action@*)
+	Action  string            // directory of the local action replacing the action, relative to the workdir
+	Outputs map[string]string // outputs of the steps, that don't run
+	Noop    bool              // the steps succeed without running and without outputs
+}
+
+// matches returns whether the `uses` of a step matches the pattern of the mock
+func (m *ActionMock) matches(uses string) bool {
+	pattern := strings.ReplaceAll(regexp.QuoteMeta(m.Uses), `\*`, ".*")
+	matched, _ := regexp.MatchString("^"+pattern+"$", uses)
+	return matched
+}
+
+// mockAction returns the step substituting the action of the step with the first matching mock of
+// ActionMocks, nil when the action isn't mocked. The substitution is logged when the step runs and recorded
+// in the history.
+func (rc *RunContext) mockAction(stepModel *model.Step) step {
+	if rc.Config == nil || stepModel.Uses == "" {
+		return nil
+	}
+	for i := range rc.Config.ActionMocks {
+		mock := &rc.Config.ActionMocks[i]
+		if !mock.matches(stepModel.Uses) {
+			continue
+		}
+		if mock.Action != "" {
+			// the local action gets the inputs of the step, the step of the workflow is unchanged
+			action := "./" + path.Clean(strings.TrimPrefix(mock.Action, "./"))
+			rc.recordSubstitute(stepModel.ID, fmt.Sprintf("%s substituted by the local action %s of the mock '%s'", stepModel.Uses, action, mock.Uses))
+			stepCopy := *stepModel
+			stepCopy.Uses = action
+			return &stepActionLocal{
+				Step:       &stepCopy,
+				RunContext: rc,
+				readAction: readActionImpl,
+				runAction:  runActionImpl,
+			}
+		}
+		if mock.Noop {
+			rc.recordSubstitute(stepModel.ID, fmt.Sprintf("%s substituted by a no-op of the mock '%s'", stepModel.Uses, mock.Uses))
+		} else {
+			rc.recordSubstitute(stepModel.ID, fmt.Sprintf("%s substituted by the outputs of the mock '%s'", stepModel.Uses, mock.Uses))
+		}
+		return &stepOverride{Step: stepModel, RunContext: rc, mock: &StepMock{Outputs: mock.Outputs}}
+	}
+	return nil
+}
+
+// recordSubstitute records the substitution of the mocked action of the step, it's logged when the step runs.
+// The substitutions of the steps of composite actions are also recorded on the step of the job running the
+// action, the one of the history and the reports.
+func (rc *RunContext) recordSubstitute(stepID string, substitute string) {
+	if rc.substitutes == nil {
+		rc.substitutes = map[string]string{}
+	}
+	rc.substitutes[stepID] = substitute
+	jobRC := rc.jobRunContext()
+	if jobRC == rc || jobRC.CurrentStep == "" {
+		return
+	}
+	if jobRC.substitutes == nil {
+		jobRC.substitutes = map[string]string{}
+	}
+	// a composite action can use the same mocked action in several steps
+	previous := jobRC.substitutes[jobRC.CurrentStep]
+	switch {
+	case previous == "":
+		jobRC.substitutes[jobRC.CurrentStep] = substitute
+	case !slices.Contains(strings.Split(previous, "; "), substitute):
+		jobRC.substitutes[jobRC.CurrentStep] = previous + "; " + substitute
+	}
+}
@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+func TestActionMockMatches(t *testing.T) {
+	mock := &ActionMock{Uses: "slackapi/slack-github-action@*"}
+	assert.True(t, mock.matches("slackapi/slack-github-action@v1.24.0"))
+	assert.True(t, mock.matches("slackapi/slack-github-action@feature/retry"))
+	assert.False(t, mock.matches("slackapi/slack-github-action"))
+	assert.False(t, mock.matches("octo/slackapi/slack-github-action@v1"))
+	assert.True(t, (&ActionMock{Uses: "*/deploy-*@v?"}).matches("octo/deploy-aws@v?"))
+	assert.False(t, (&ActionMock{Uses: "*/deploy-*@v?"}).matches("octo/deploy-aws@v2"))
+}
+
+func TestMockAction(t *testing.T) {
+	config := &Config{
+		SkipSteps: []string{"release.skipped"},
+		ActionMocks: []ActionMock{
+			{Uses: "slackapi/slack-github-action@*", Noop: true},
+			{Uses: "softprops/action-gh-release@*", Outputs: map[string]string{"url": "https://example.com/release"}},
+			{Uses: "octo/deploy@*", Action: ".github/mocks/deploy"},
+			{Uses: "softprops/*", Noop: true},
+		},
+	}
+	sf := &stepFactoryImpl{}
+	rc := &RunContext{Config: config, Run: &model.Run{JobID: "release"}}
+	newStep := func(id string, uses string) step {
+		s, err := sf.newStep(&model.Step{ID: id, Uses: uses}, rc)
+		require.NoError(t, err)
+		return s
+	}
+
+	notify := newStep("notify", "slackapi/slack-github-action@v1.24.0")
+	require.IsType(t, &stepOverride{}, notify)
+	assert.Equal(t, &StepMock{}, notify.(*stepOverride).mock)
+
+	// the first matching mock applies
+	publish := newStep("publish", "softprops/action-gh-release@v2")
+	require.IsType(t, &stepOverride{}, publish)
+	assert.Equal(t, &StepMock{Outputs: map[string]string{"url": "https://example.com/release"}}, publish.(*stepOverride).mock)
+
+	// the local action gets the inputs of the step
+	stepModel := &model.Step{ID: "deploy", Uses: "octo/deploy@v3", With: map[string]string{"env": "production"}}
+	deploy, err := sf.newStep(stepModel, rc)
+	require.NoError(t, err)
+	require.IsType(t, &stepActionLocal{}, deploy)
+	assert.Equal(t, "./.github/mocks/deploy", deploy.getStepModel().Uses)
+	assert.Equal(t, map[string]string{"env": "production"}, deploy.getStepModel().With)
+	assert.Equal(t, "octo/deploy@v3", stepModel.Uses)
+
+	assert.IsType(t, &stepActionRemote{}, newStep("checkout", "actions/checkout@v4"))
+	// the skipped steps aren't substituted
+	assert.Nil(t, newStep("skipped", "slackapi/slack-github-action@v1").(*stepOverride).mock)
+
+	assert.Equal(t, map[string]string{
+		"notify":  "slackapi/slack-github-action@v1.24.0 substituted by a no-op of the mock 'slackapi/slack-github-action@*'",
+		"publish": "softprops/action-gh-release@v2 substituted by the outputs of the mock 'softprops/action-gh-release@*'",
+		"deploy":  "octo/deploy@v3 substituted by the local action ./.github/mocks/deploy of the mock 'octo/deploy@*'",
+	}, rc.substitutes)
+}
+
+func TestMockActionOfCompositeAction(t *testing.T) {
+	config := &Config{ActionMocks: []ActionMock{{Uses: "slackapi/*", Noop: true}}}
+	rc := &RunContext{Config: config, Run: &model.Run{JobID: "release"}, CurrentStep: "notify"}
+	composite := &RunContext{Config: config, Parent: rc}
+	nested := &RunContext{Config: config, Parent: composite}
+
+	require.NotNil(t, composite.mockAction(&model.Step{ID: "slack", Uses: "slackapi/slack-github-action@v1"}))
+	require.NotNil(t, nested.mockAction(&model.Step{ID: "0", Uses: "slackapi/slack-github-action@v1"}))
+	require.NotNil(t, nested.mockAction(&model.Step{ID: "1", Uses: "slackapi/other@v2"}))
+
+	// the substitutions are logged by the steps of the composite actions, and recorded on the step of the job
+	assert.Equal(t, map[string]string{"slack": "slackapi/slack-github-action@v1 substituted by a no-op of the mock 'slackapi/*'"}, composite.substitutes)
+	assert.Equal(t, map[string]string{
+		"notify": "slackapi/slack-github-action@v1 substituted by a no-op of the mock 'slackapi/*'; slackapi/other@v2 substituted by a no-op of the mock 'slackapi/*'",
+	}, rc.substitutes)
+}
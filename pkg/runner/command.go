@@ -3,9 +3,13 @@ package runner
 import (
 	"context"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/events"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
 
 	"github.com/sirupsen/logrus"
 )
@@ -39,6 +43,7 @@ func (rc *RunContext) commandHandler(ctx context.Context) common.LineHandler {
 	return func(line string) bool {
 		command, kvPairs, arg, ok := tryParseRawActionCommand(line)
 		if !ok {
+			rc.matchProblems(ctx, line)
 			return true
 		}
 
@@ -58,10 +63,12 @@ func (rc *RunContext) commandHandler(ctx context.Context) common.LineHandler {
 			rc.addPath(ctx, arg)
 		case "debug":
 			defCommandLogger.Debugf("  \U0001F4AC  %s", line)
-		case "warning":
-			defCommandLogger.Warnf("  \U0001F6A7  %s", line)
-		case "error":
-			defCommandLogger.Errorf("  \U00002757  %s", line)
+		case "notice", "warning", "error":
+			rc.addAnnotation(ctx, defCommandLogger, command, kvPairs, arg)
+		case "group":
+			rc.startLogGroup(ctx, arg)
+		case "endgroup":
+			rc.endLogGroup(ctx)
 		case "add-mask":
 			rc.AddMask(arg)
 			defCommandLogger.Infof("  \U00002699  %s", "***")
@@ -75,7 +82,13 @@ func (rc *RunContext) commandHandler(ctx context.Context) common.LineHandler {
 			defCommandLogger.Infof("  \U0001f4be  %s", line)
 			rc.saveState(ctx, kvPairs, arg)
 		case "add-matcher":
-			defCommandLogger.Infof("  \U00002753 add-matcher %s", arg)
+			defCommandLogger.Infof("  \U00002699  add-matcher %s", arg)
+			if err := rc.addProblemMatchers(ctx, arg); err != nil {
+				defCommandLogger.Warnf("  \U0001F6A7  Unable to add the problem matchers of '%s': %v", arg, err)
+			}
+		case "remove-matcher":
+			defCommandLogger.Infof("  \U00002699  remove-matcher %s", kvPairs["owner"])
+			rc.removeProblemMatcher(kvPairs["owner"])
 		default:
 			defCommandLogger.Infof("  \U00002753  %s", line)
 		}
@@ -120,6 +133,10 @@ func (rc *RunContext) setOutput(ctx context.Context, kvPairs map[string]string,
 
 	logger.WithFields(logrus.Fields{"command": "set-output", "name": outputName, "arg": arg}).Infof("  \U00002699  ::set-output:: %s=%s", outputName, arg)
 	result.Outputs[outputName] = arg
+	rc.emitStepEvent(ctx, events.Event{Type: events.OutputSet, Step: &events.Step{ID: stepID}, Output: &events.Output{Name: outputName, Value: arg}})
+	if hooks := rc.hooks(); hooks != nil {
+		hooks.OnOutput(ctx, rc.hookJob(), stepID, outputName, arg)
+	}
 }
 func (rc *RunContext) addPath(ctx context.Context, arg string) {
 	common.Logger(ctx).WithFields(logrus.Fields{"command": "add-path", "arg": arg}).Infof("  \U00002699  ::add-path:: %s", arg)
@@ -132,6 +149,79 @@ func (rc *RunContext) addPath(ctx context.Context, arg string) {
 	rc.ExtraPath = extraPath
 }
 
+// addAnnotation records a notice, warning or error on the result of the running stage of the current step
+func (rc *RunContext) addAnnotation(ctx context.Context, logger logrus.FieldLogger, level string, kvPairs map[string]string, message string) {
+	annotation := model.Annotation{
+		Level:     level,
+		Message:   message,
+		Title:     kvPairs["title"],
+		File:      kvPairs["file"],
+		Line:      parseAnnotationPosition(kvPairs["line"]),
+		EndLine:   parseAnnotationPosition(kvPairs["endLine"]),
+		Col:       parseAnnotationPosition(kvPairs["col"]),
+		EndColumn: parseAnnotationPosition(kvPairs["endColumn"]),
+	}
+	if result := rc.currentStageResult(); result != nil {
+		result.Annotations = append(result.Annotations, annotation)
+	}
+	rc.emitStepEvent(ctx, events.Event{Type: events.AnnotationAdded, Annotation: &annotation})
+
+	logger = logger.WithField("annotation", annotation)
+	switch level {
+	case "notice":
+		logger.Infof("  \U0001F4DD  %s", annotation)
+	case "warning":
+		logger.Warnf("  \U0001F6A7  %s", annotation)
+	default:
+		logger.Errorf("  \U00002757  %s", annotation)
+	}
+}
+
+// currentStageResult returns the result of the running stage of the current step, the annotations of the pre and
+// post stages are recorded on their own result
+func (rc *RunContext) currentStageResult() *model.StepResult {
+	if rc.stageResult != nil {
+		return rc.stageResult
+	}
+	return rc.StepResults[rc.CurrentStep]
+}
+
+func parseAnnotationPosition(value string) int {
+	position, err := strconv.Atoi(value)
+	if err != nil || position < 0 {
+		return 0
+	}
+	return position
+}
+
+// logGroup is a group of log lines started with ::group::
+type logGroup struct {
+	name      string
+	startTime time.Time
+}
+
+func (rc *RunContext) startLogGroup(ctx context.Context, name string) {
+	rc.logGroups = append(rc.logGroups, logGroup{name: name, startTime: time.Now()})
+	common.Logger(ctx).WithFields(logrus.Fields{"command": "group", "group": name, "groupEvent": "start"}).Infof("  \u25BC  %s", name)
+}
+
+func (rc *RunContext) endLogGroup(ctx context.Context) {
+	if len(rc.logGroups) == 0 {
+		return
+	}
+	group := rc.logGroups[len(rc.logGroups)-1]
+	rc.logGroups = rc.logGroups[:len(rc.logGroups)-1]
+	executionTime := time.Since(group.startTime)
+	common.Logger(ctx).WithFields(logrus.Fields{"command": "endgroup", "group": group.name, "groupEvent": "end", "executionTime": executionTime}).Infof("  \u25B2  %s [%s]", group.name, executionTime)
+}
+
+// endLogGroups ends the groups a step left open, like the runner does at the end of a step
+func (rc *RunContext) endLogGroups(ctx context.Context) {
+	for len(rc.logGroups) > 0 {
+		rc.endLogGroup(ctx)
+	}
+}
+
 func parseKeyValuePairs(kvPairs string, separator string) map[string]string {
 	rtn := make(map[string]string)
 	kvPairList := strings.Split(kvPairs, separator)
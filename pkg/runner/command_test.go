@@ -3,10 +3,14 @@ package runner
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"io"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/sirupsen/logrus"
 	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 
@@ -187,3 +191,99 @@ func TestSaveState(t *testing.T) {
 
 	assert.Equal(t, "state-value", rc.IntraActionState["step"]["state-name"])
 }
+
+func TestAnnotations(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	ctx := common.WithLogger(context.Background(), logger)
+	rc := &RunContext{
+		CurrentStep: "my-step",
+		StepResults: map[string]*model.StepResult{"my-step": {Outputs: map[string]string{}}},
+	}
+	handler := rc.commandHandler(ctx)
+
+	handler("::notice::Deployed%0Ato staging\n")
+	handler("::warning file=main.go,line=10,col=5,title=Lint%3A vet::unused variable\n")
+	handler("::error file=main.go,line=10,endLine=12::does not compile\n")
+
+	assert.Equal(t, []model.Annotation{
+		{Level: "notice", Message: "Deployed\nto staging"},
+		{Level: "warning", Message: "unused variable", Title: "Lint: vet", File: "main.go", Line: 10, Col: 5},
+		{Level: "error", Message: "does not compile", File: "main.go", Line: 10, EndLine: 12},
+	}, rc.StepResults["my-step"].Annotations)
+
+	entries := hook.AllEntries()
+	assert.Equal(t, "  \U0001F6A7  main.go:10:5: Lint: vet: unused variable", entries[1].Message)
+	assert.Equal(t, "  \U00002757  main.go:10-12: does not compile", entries[2].Message)
+	assert.Equal(t, rc.StepResults["my-step"].Annotations[2], entries[2].Data["annotation"])
+
+	// toJSON(steps) doesn't include the annotations, the steps context of GitHub has none
+	steps, err := json.Marshal(rc.StepResults)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"my-step": {"outputs": {}, "conclusion": "success", "outcome": "success"}}`, string(steps))
+}
+
+func TestAnnotationsOfPreAndPostStages(t *testing.T) {
+	ctx := common.WithLogger(context.Background(), logrus.New())
+	pre := &model.StepResult{Outputs: map[string]string{}}
+	rc := &RunContext{
+		CurrentStep: "my-step",
+		StepResults: map[string]*model.StepResult{},
+		stageResult: pre,
+	}
+
+	// the pre stage runs before the step has a result
+	rc.commandHandler(ctx)("::warning::cache miss\n")
+	assert.Equal(t, []model.Annotation{{Level: "warning", Message: "cache miss"}}, pre.Annotations)
+
+	main := &model.StepResult{Outputs: map[string]string{}}
+	post := &model.StepResult{Outputs: map[string]string{}}
+	rc.StepResults["my-step"] = main
+	rc.stageResult = post
+	rc.commandHandler(ctx)("::error::cache not saved\n")
+	assert.Equal(t, []model.Annotation{{Level: "error", Message: "cache not saved"}}, post.Annotations)
+	assert.Empty(t, main.Annotations)
+}
+
+func TestLogGroups(t *testing.T) {
+	rc := new(RunContext)
+	config := &Config{Secrets: map[string]string{}}
+
+	out := captureOutput(t, func() {
+		ctx := WithJobLogger(context.Background(), "0", "testjob", config, &rc.Masks, map[string]interface{}{})
+		handler := rc.commandHandler(ctx)
+		handler("::group::Install\n")
+		common.Logger(ctx).WithField("raw_output", true).Infof("npm ci")
+		handler("##[group]Nested\n")
+		handler("::endgroup::\n")
+		rc.endLogGroups(ctx)
+		common.Logger(ctx).Infof("done")
+	})
+
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	assert.Len(t, lines, 6)
+	assert.Equal(t, "[testjob]   ▼  Install", lines[0])
+	assert.Equal(t, "[testjob]   |   npm ci", lines[1])
+	assert.Equal(t, "[testjob]     ▼  Nested", lines[2])
+	assert.Regexp(t, `^\[testjob\]     \x{25B2}  Nested \[.+\]$`, lines[3])
+	assert.Regexp(t, `^\[testjob\]   \x{25B2}  Install \[.+\]$`, lines[4])
+	assert.Equal(t, "[testjob] done", lines[5])
+	assert.Empty(t, rc.logGroups)
+}
+
+func TestLogGroupsJSON(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	ctx := common.WithLogger(context.Background(), logger)
+	rc := new(RunContext)
+	handler := rc.commandHandler(ctx)
+
+	handler("::group::Build\n")
+	handler("::endgroup::\n")
+	handler("::endgroup::\n")
+
+	entries := hook.AllEntries()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, logrus.Fields{"command": "group", "group": "Build", "groupEvent": "start"}, entries[0].Data)
+	assert.Equal(t, "end", entries[1].Data["groupEvent"])
+	assert.Equal(t, "Build", entries[1].Data["group"])
+	assert.IsType(t, time.Duration(0), entries[1].Data["executionTime"])
+}
@@ -0,0 +1,291 @@
+package runner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/exprparser"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+// workflowConcurrencyHolder holds all workflow level groups of this process, so the workflows
+// of one gha invocation run together and reusable workflows don't wait for their caller
+const workflowConcurrencyHolder = "workflow"
+
+// concurrencyPollInterval is how often a lock file held by another gha process is checked
+var concurrencyPollInterval = time.Second
+
+// concurrencyGroups serializes the workflows and jobs sharing a concurrency group within this
+// process. Every group is backed by a lock file under the action cache dir, which extends
+// the serialization to the other gha processes on the same machine.
+type concurrencyGroups struct {
+	mu     sync.Mutex
+	groups map[string]*concurrencyGroup
+}
+
+type concurrencyGroup struct {
+	holder   string             // the workflow or job holding the group
+	count    int                // the number of acquisitions by the holder
+	cancel   context.CancelFunc // cancels the holder for a cancel-in-progress request
+	released chan struct{}      // closed when the group is released
+	file     *os.File           // the lock file shared with other gha processes
+}
+
+// concurrencyRequest asks for a concurrency group on behalf of a workflow or job
+type concurrencyRequest struct {
+	key              string             // identifies the group, scoped to the repository
+	group            string             // the evaluated group name
+	holder           string             // the workflow or job asking for the group
+	cancelInProgress bool               // cancel the holder instead of waiting for it to finish
+	interruptProcess bool               // cancel-in-progress interrupts another gha process holding the group
+	cancel           context.CancelFunc // cancels the requester once it holds the group
+}
+
+var processConcurrencyGroups = &concurrencyGroups{groups: map[string]*concurrencyGroup{}}
+
+type heldConcurrencyGroupsKey struct{}
+
+func withHeldConcurrencyGroup(ctx context.Context, key string) context.Context {
+	held, _ := ctx.Value(heldConcurrencyGroupsKey{}).([]string)
+	return context.WithValue(ctx, heldConcurrencyGroupsKey{}, append(append([]string{}, held...), key))
+}
+
+func concurrencyKey(repository string, group string) string {
+	sum := sha256.Sum256([]byte(repository + "\n" + group))
+	return hex.EncodeToString(sum[:])
+}
+
+// acquire waits until the group is free and returns the function releasing it again
+func (cg *concurrencyGroups) acquire(ctx context.Context, lockDir string, req concurrencyRequest) (func(), error) {
+	logger := common.Logger(ctx)
+
+	cg.mu.Lock()
+	if g, ok := cg.groups[req.key]; ok && g.holder != req.holder {
+		held, _ := ctx.Value(heldConcurrencyGroupsKey{}).([]string)
+		for _, key := range held {
+			if key == req.key {
+				cg.mu.Unlock()
+				return nil, fmt.Errorf("canceling since a deadlock for concurrency group '%s' was detected between %s and %s", req.group, g.holder, req.holder)
+			}
+		}
+	}
+	cg.mu.Unlock()
+
+	// a graceful cancellation stops the waiting as well
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if cctx := common.JobCancelContext(ctx); cctx != nil {
+		stop := context.AfterFunc(cctx, cancel)
+		defer stop()
+	}
+
+	for {
+		cg.mu.Lock()
+		g, ok := cg.groups[req.key]
+		if !ok {
+			g = &concurrencyGroup{holder: req.holder, count: 1, cancel: req.cancel, released: make(chan struct{})}
+			cg.groups[req.key] = g
+			cg.mu.Unlock()
+
+			file, err := lockConcurrencyFile(ctx, filepath.Join(lockDir, req.key+".lock"), func(pid int) {
+				if req.cancelInProgress && req.interruptProcess && pid > 0 {
+					logger.Infof("Cancelling gha process %d in progress in concurrency group '%s'", pid, req.group)
+					if err := interruptProcess(pid); err != nil {
+						logger.Warnf("Unable to cancel gha process %d: %v", pid, err)
+					}
+				}
+				logger.Infof("Waiting for concurrency group '%s', it is in use by another gha process", req.group)
+			})
+			if err != nil {
+				cg.release(req.key)
+				return nil, err
+			}
+			cg.mu.Lock()
+			g.file = file
+			cg.mu.Unlock()
+			return func() { cg.release(req.key) }, nil
+		}
+		if g.holder == req.holder {
+			g.count++
+			cg.mu.Unlock()
+			return func() { cg.release(req.key) }, nil
+		}
+		if req.cancelInProgress && g.cancel != nil {
+			logger.Infof("Cancelling %s in progress in concurrency group '%s'", g.holder, req.group)
+			g.cancel()
+			g.cancel = nil
+		}
+		holder, released := g.holder, g.released
+		cg.mu.Unlock()
+
+		logger.Infof("Waiting for concurrency group '%s', it is in use by %s", req.group, holder)
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (cg *concurrencyGroups) release(key string) {
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+
+	g := cg.groups[key]
+	g.count--
+	if g.count > 0 {
+		return
+	}
+	delete(cg.groups, key)
+	if g.file != nil {
+		_ = unlockFile(g.file)
+		_ = g.file.Close()
+	}
+	close(g.released)
+}
+
+// lockConcurrencyFile locks the file, calling onBusy once with the pid of the gha process
+// holding it if it is locked already
+func lockConcurrencyFile(ctx context.Context, path string, onBusy func(pid int)) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	busy := false
+	for {
+		locked, err := tryLockFile(file)
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		if locked {
+			if err := file.Truncate(0); err == nil {
+				_, _ = file.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+			}
+			return file, nil
+		}
+		if !busy {
+			busy = true
+			content, _ := os.ReadFile(path)
+			pid, _ := strconv.Atoi(strings.TrimSpace(string(content)))
+			onBusy(pid)
+		}
+		select {
+		case <-time.After(concurrencyPollInterval):
+		case <-ctx.Done():
+			_ = file.Close()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// evaluateConcurrency returns the group name and cancel-in-progress setting of a concurrency key
+func evaluateConcurrency(ctx context.Context, ee ExpressionEvaluator, concurrency *model.Concurrency) (string, bool) {
+	group := ee.Interpolate(ctx, concurrency.Group)
+	cancelInProgress := false
+	if concurrency.CancelInProgress != "" {
+		var err error
+		if cancelInProgress, err = EvalBool(ctx, ee, concurrency.CancelInProgress, exprparser.DefaultStatusCheckNone); err != nil {
+			common.Logger(ctx).Warnf("Invalid value '%s' for 'cancel-in-progress': %v", concurrency.CancelInProgress, err)
+		}
+	}
+	return group, cancelInProgress
+}
+
+// cancelInProgressError is the cause of the cancellation of a job by a later job of its group, the
+// job is cancelled like on GitHub
+func cancelInProgressError(group string) error {
+	return fmt.Errorf("canceling since a higher priority waiting request for '%s' exists, cancel-in-progress %w", group, errJobCancelled)
+}
+
+// withJobConcurrency waits for the concurrency group of the job. The returned context is
+// cancelled when a later job of the group cancels this one in progress.
+func (rc *RunContext) withJobConcurrency(ctx context.Context) (context.Context, func(), error) {
+	concurrency := rc.Run.Job().Concurrency()
+	if concurrency == nil || common.Dryrun(ctx) {
+		return ctx, func() {}, nil
+	}
+	group, cancelInProgress := evaluateConcurrency(ctx, rc.ExprEval, concurrency)
+	if group == "" {
+		return ctx, func() {}, nil
+	}
+
+	parent := common.JobCancelContext(ctx)
+	if parent == nil {
+		parent = context.Background()
+	}
+	cancelCtx, cancel := context.WithCancelCause(parent)
+	key := concurrencyKey(rc.getGithubContext(ctx).Repository, group)
+	release, err := processConcurrencyGroups.acquire(ctx, filepath.Join(rc.ActionCacheDir(), "concurrency"), concurrencyRequest{
+		key:              key,
+		group:            group,
+		holder:           fmt.Sprintf("job '%s'", rc.String()),
+		cancelInProgress: cancelInProgress,
+		cancel: func() {
+			cancel(cancelInProgressError(group))
+		},
+	})
+	if err != nil {
+		cancel(nil)
+		return ctx, nil, err
+	}
+	ctx = withHeldConcurrencyGroup(common.WithJobCancelContext(ctx, cancelCtx), key)
+	return ctx, func() {
+		release()
+		cancel(nil)
+	}, nil
+}
+
+// newWorkflowConcurrencyExecutor holds the workflow level concurrency groups of the planned
+// workflows while the executor runs. Cancelling a workflow in progress interrupts the gha
+// process running it, as all workflows of a process run together.
+func (runner *runnerImpl) newWorkflowConcurrencyExecutor(plan *model.Plan, executor common.Executor) common.Executor {
+	return func(ctx context.Context) error {
+		if common.Dryrun(ctx) {
+			return executor(ctx)
+		}
+
+		seen := map[*model.Workflow]bool{}
+		for _, stage := range plan.Stages {
+			for _, run := range stage.Runs {
+				if seen[run.Workflow] || run.Workflow.Concurrency() == nil {
+					continue
+				}
+				seen[run.Workflow] = true
+
+				rc := runner.newRunContext(ctx, run, nil)
+				group, cancelInProgress := evaluateConcurrency(ctx, rc.ExprEval, run.Workflow.Concurrency())
+				if group == "" {
+					continue
+				}
+				key := concurrencyKey(rc.getGithubContext(ctx).Repository, group)
+				release, err := processConcurrencyGroups.acquire(ctx, filepath.Join(rc.ActionCacheDir(), "concurrency"), concurrencyRequest{
+					key:              key,
+					group:            group,
+					holder:           workflowConcurrencyHolder,
+					cancelInProgress: cancelInProgress,
+					interruptProcess: true,
+				})
+				if err != nil {
+					return err
+				}
+				defer release()
+				ctx = withHeldConcurrencyGroup(ctx, key)
+			}
+		}
+		return executor(ctx)
+	}
+}
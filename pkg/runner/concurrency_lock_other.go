@@ -0,0 +1,21 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package runner
+
+import (
+	"errors"
+	"os"
+)
+
+// without file locks concurrency groups are only enforced within a single process
+func tryLockFile(_ *os.File) (bool, error) {
+	return true, nil
+}
+
+func unlockFile(_ *os.File) error {
+	return nil
+}
+
+func interruptProcess(_ int) error {
+	return errors.New("interrupting another process is not supported on this platform")
+}
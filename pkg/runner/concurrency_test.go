@@ -0,0 +1,130 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrencyGroupsSerialize(t *testing.T) {
+	cg := &concurrencyGroups{groups: map[string]*concurrencyGroup{}}
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	release, err := cg.acquire(ctx, dir, concurrencyRequest{key: "deploy", group: "deploy", holder: "job 'a'"})
+	require.NoError(t, err)
+
+	acquired := make(chan struct{})
+	go func() {
+		release, err := cg.acquire(ctx, dir, concurrencyRequest{key: "deploy", group: "deploy", holder: "job 'b'"})
+		assert.NoError(t, err)
+		close(acquired)
+		release()
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("the group was acquired while it was held by another job")
+	case <-time.After(100 * time.Millisecond):
+	}
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the group was not acquired after it was released")
+	}
+}
+
+func TestConcurrencyGroupsCancelInProgress(t *testing.T) {
+	cg := &concurrencyGroups{groups: map[string]*concurrencyGroup{}}
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	running, cancel := context.WithCancel(ctx)
+	defer cancel()
+	release, err := cg.acquire(ctx, dir, concurrencyRequest{key: "deploy", group: "deploy", holder: "job 'a'", cancel: cancel})
+	require.NoError(t, err)
+	go func() {
+		<-running.Done()
+		release()
+	}()
+
+	release, err = cg.acquire(ctx, dir, concurrencyRequest{key: "deploy", group: "deploy", holder: "job 'b'", cancelInProgress: true})
+	require.NoError(t, err)
+	assert.Error(t, running.Err())
+	release()
+}
+
+func TestConcurrencyGroupsReentrantAndDeadlock(t *testing.T) {
+	cg := &concurrencyGroups{groups: map[string]*concurrencyGroup{}}
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	release, err := cg.acquire(ctx, dir, concurrencyRequest{key: "ci", group: "ci", holder: workflowConcurrencyHolder})
+	require.NoError(t, err)
+	defer release()
+
+	nested, err := cg.acquire(ctx, dir, concurrencyRequest{key: "ci", group: "ci", holder: workflowConcurrencyHolder})
+	require.NoError(t, err)
+	nested()
+
+	_, err = cg.acquire(withHeldConcurrencyGroup(ctx, "ci"), dir, concurrencyRequest{key: "ci", group: "ci", holder: "job 'build'"})
+	assert.EqualError(t, err, "canceling since a deadlock for concurrency group 'ci' was detected between workflow and job 'build'")
+}
+
+func TestLockConcurrencyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "concurrency", "deploy.lock")
+
+	file, err := lockConcurrencyFile(context.Background(), path, func(int) {
+		t.Fatal("the lock file is not in use")
+	})
+	require.NoError(t, err)
+	defer file.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	busyPid := 0
+	_, err = lockConcurrencyFile(ctx, path, func(pid int) {
+		busyPid = pid
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, os.Getpid(), busyPid)
+
+	require.NoError(t, unlockFile(file))
+	other, err := lockConcurrencyFile(context.Background(), path, func(int) {})
+	require.NoError(t, err)
+	other.Close()
+}
+
+func TestEvaluateConcurrency(t *testing.T) {
+	rc := &RunContext{
+		Config: &Config{},
+		Run: &model.Run{
+			JobID: "deploy",
+			Workflow: &model.Workflow{
+				Jobs: map[string]*model.Job{
+					"deploy": {},
+				},
+			},
+		},
+		Matrix: map[string]interface{}{"env": "prod"},
+	}
+	ctx := context.Background()
+	ee := rc.NewExpressionEvaluator(ctx)
+
+	group, cancelInProgress := evaluateConcurrency(ctx, ee, &model.Concurrency{Group: "deploy-${{ matrix.env }}"})
+	assert.Equal(t, "deploy-prod", group)
+	assert.False(t, cancelInProgress)
+
+	_, cancelInProgress = evaluateConcurrency(ctx, ee, &model.Concurrency{Group: "deploy", CancelInProgress: "${{ matrix.env == 'prod' }}"})
+	assert.True(t, cancelInProgress)
+
+	_, cancelInProgress = evaluateConcurrency(ctx, ee, &model.Concurrency{Group: "deploy", CancelInProgress: "true"})
+	assert.True(t, cancelInProgress)
+}
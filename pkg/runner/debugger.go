@@ -0,0 +1,135 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/container"
+	"github.com/Leapfrog-DevOps/gha/pkg/exprparser"
+)
+
+// BreakAll is the step ID of Config.BreakBefore that pauses the run before every step
+const BreakAll = "*"
+
+// DebugAction is how the run goes on after the debugger paused it at a breakpoint
+type DebugAction int
+
+const (
+	DebugContinue DebugAction = iota // runs the step, or goes on with the failure of the failed step
+	DebugRerun                       // runs the failed step again
+)
+
+// Debugger pauses the run at a breakpoint until the user continues it, nil when the run isn't debugged.
+// The breakpoints are the steps of Config.BreakBefore and, with Config.BreakOnFailure, the failed steps.
+type Debugger func(ctx context.Context, breakpoint *Breakpoint) (DebugAction, error)
+
+// Breakpoint is a step the run is paused at, before the step runs or after the step failed
+type Breakpoint struct {
+	Job    string // the name of the job of the step
+	StepID string // the ID of the step
+	Step   string // the name of the step
+	Err    error  // the error of the failed step, nil before the step runs
+
+	rc   *RunContext
+	step step
+}
+
+// Evaluate returns the value of the expression in the contexts of the step, e.g. toJSON(needs),
+// the values that aren't strings are returned as JSON. The secrets in the value are masked.
+func (b *Breakpoint) Evaluate(ctx context.Context, expression string) (string, error) {
+	value, err := b.rc.NewStepExpressionEvaluator(ctx, b.step).evaluate(ctx, expression, exprparser.DefaultStatusCheckNone)
+	if err != nil {
+		return "", err
+	}
+	s, ok := value.(string)
+	if !ok {
+		out, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		s = string(out)
+	}
+	return b.rc.mask(s), nil
+}
+
+// Env returns the env of the step, the secrets in the values are masked
+func (b *Breakpoint) Env() map[string]string {
+	env := maps.Clone(*b.step.getEnv())
+	for k, v := range env {
+		env[k] = b.rc.mask(v)
+	}
+	return env
+}
+
+// Script returns the script of a run step, false for a step that uses an action
+func (b *Breakpoint) Script() (string, bool) {
+	sr, ok := b.step.(*stepRun)
+	if !ok {
+		return "", false
+	}
+	return sr.Step.Run, true
+}
+
+// SetScript replaces the script of a run step, the new script runs when the run goes on or the step runs again
+func (b *Breakpoint) SetScript(script string) error {
+	sr, ok := b.step.(*stepRun)
+	if !ok {
+		return fmt.Errorf("the step '%s' uses an action and has no script", b.Step)
+	}
+	// the step of the workflow is shared by the jobs of a matrix
+	stepModel := *sr.Step
+	stepModel.Run = script
+	sr.Step = &stepModel
+	return nil
+}
+
+// Shell runs an interactive shell in the job container on the terminal, with the env of the step
+func (b *Breakpoint) Shell(ctx context.Context, terminal *container.Terminal) error {
+	env := maps.Clone(*b.step.getEnv())
+	b.rc.ApplyExtraPath(ctx, &env)
+	shell := []string{"sh", "-c", "command -v bash >/dev/null 2>&1 && exec bash || exec sh"}
+	return b.rc.JobContainer.Exec(shell, env, "", "")(container.WithTerminal(ctx, terminal))
+}
+
+func (rc *RunContext) newBreakpoint(step step, err error) *Breakpoint {
+	stepModel := step.getStepModel()
+	return &Breakpoint{
+		Job:    rc.jobRunContext().String(),
+		StepID: stepModel.ID,
+		Step:   stepModel.String(),
+		Err:    err,
+		rc:     rc,
+		step:   step,
+	}
+}
+
+// debugStep runs the main stage of the step with the breakpoints of the debugger: the run is paused before
+// the steps of Config.BreakBefore and, with Config.BreakOnFailure, after the step failed, where the step
+// can run again
+func (rc *RunContext) debugStep(ctx context.Context, step step, stage stepStage, run func() error) error {
+	debugger := rc.Config.Debugger
+	if debugger == nil || stage != stepStageMain {
+		return run()
+	}
+	if slices.Contains(rc.Config.BreakBefore, BreakAll) || slices.Contains(rc.Config.BreakBefore, step.getStepModel().ID) {
+		if _, err := debugger(ctx, rc.newBreakpoint(step, nil)); err != nil {
+			return err
+		}
+	}
+	for {
+		err := run()
+		if err == nil || !rc.Config.BreakOnFailure || ctx.Err() != nil {
+			return err
+		}
+		action, debugErr := debugger(ctx, rc.newBreakpoint(step, err))
+		if debugErr != nil {
+			return debugErr
+		}
+		if action != DebugRerun {
+			return err
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+func TestDebugStep(t *testing.T) {
+	ctx := context.Background()
+	breakpoints := []string{}
+	failures := 0
+	var debugger Debugger = func(ctx context.Context, b *Breakpoint) (DebugAction, error) {
+		if b.Err == nil {
+			breakpoints = append(breakpoints, "before "+b.StepID)
+			greeting, err := b.Evaluate(ctx, "env.GREETING")
+			require.NoError(t, err)
+			assert.Equal(t, "hello", greeting)
+			script, ok := b.Script()
+			assert.True(t, ok)
+			assert.Equal(t, "make", script)
+			return DebugContinue, b.SetScript("make test")
+		}
+		breakpoints = append(breakpoints, fmt.Sprintf("failed %s: %v", b.StepID, b.Err))
+		failures++
+		if failures == 1 {
+			return DebugRerun, nil
+		}
+		return DebugContinue, nil
+	}
+
+	for _, tt := range []struct {
+		name        string
+		config      Config
+		fails       int
+		breakpoints []string
+		runs        int
+		err         bool
+	}{
+		{name: "not debugged", config: Config{BreakBefore: []string{BreakAll}, BreakOnFailure: true}, fails: 1, breakpoints: []string{}, runs: 1, err: true},
+		{name: "break before", config: Config{BreakBefore: []string{"make"}}, fails: 1, breakpoints: []string{"before make"}, runs: 1, err: true},
+		{name: "break before all", config: Config{BreakBefore: []string{BreakAll}}, breakpoints: []string{"before make"}, runs: 1},
+		{name: "break before other step", config: Config{BreakBefore: []string{"lint"}}, breakpoints: []string{}, runs: 1},
+		{name: "rerun failed step", config: Config{BreakBefore: []string{"make"}, BreakOnFailure: true}, fails: 1, breakpoints: []string{"before make", "failed make: exit status 2"}, runs: 2},
+		{name: "continue failed step", config: Config{BreakOnFailure: true}, fails: 2, breakpoints: []string{"failed make: exit status 2", "failed make: exit status 2"}, runs: 2, err: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			breakpoints, failures = []string{}, 0
+			config := tt.config
+			if tt.name != "not debugged" {
+				config.Debugger = debugger
+			}
+			rc := &RunContext{
+				Config: &config,
+				Run: &model.Run{
+					JobID:    "test",
+					Workflow: &model.Workflow{Jobs: map[string]*model.Job{"test": {}}},
+				},
+			}
+			stepModel := &model.Step{ID: "make", Run: "make"}
+			sr := &stepRun{Step: stepModel, RunContext: rc, env: map[string]string{"GREETING": "hello"}}
+
+			runs := 0
+			err := rc.debugStep(ctx, sr, stepStageMain, func() error {
+				runs++
+				if runs <= tt.fails {
+					return fmt.Errorf("exit status 2")
+				}
+				return nil
+			})
+			assert.Equal(t, tt.err, err != nil)
+			assert.Equal(t, tt.breakpoints, breakpoints)
+			assert.Equal(t, tt.runs, runs)
+			// the edited script runs, the step of the workflow is unchanged
+			if len(tt.breakpoints) > 0 && tt.breakpoints[0] == "before make" {
+				assert.Equal(t, "make test", sr.Step.Run)
+			}
+			assert.Equal(t, "make", stepModel.Run)
+		})
+	}
+}
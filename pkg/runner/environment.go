@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+// EnvironmentProtection contains the protection rules of a deployment environment
+type EnvironmentProtection struct {
+	RequiredReviewers bool          // the job waits for an approval before it starts
+	WaitTimer         time.Duration // the job waits this long before it starts
+}
+
+// EnvironmentValues returns the secrets or vars of a deployment environment, it's only called for the environment a job names
+type EnvironmentValues func(environment string) map[string]string
+
+// DeploymentApprover asks whether the job may deploy to a protected environment
+type DeploymentApprover func(ctx context.Context, job string, environment string) (bool, error)
+
+// withDeploymentEnvironment evaluates the deployment environment of the job and waits for its protection rules
+func (rc *RunContext) withDeploymentEnvironment(ctx context.Context) error {
+	environment := rc.Run.Job().DeploymentEnvironment()
+	if environment == nil {
+		return nil
+	}
+	name := rc.ExprEval.Interpolate(ctx, environment.Name)
+	if name == "" {
+		return nil
+	}
+
+	logger := common.Logger(ctx)
+	logger.Infof("\U0001F30E  Environment: %s", name)
+
+	rc.DeploymentEnvironment = &model.DeploymentEnvironment{Name: name, URL: environment.URL}
+	for _, secret := range environmentValues(rc.Config.EnvironmentSecrets, name) {
+		rc.AddMask(secret)
+	}
+	// make the secrets and vars of the environment available to expressions
+	rc.ExprEval = rc.NewExpressionEvaluator(ctx)
+
+	protection, ok := rc.Config.EnvironmentProtections[name]
+	if !ok || common.Dryrun(ctx) {
+		return nil
+	}
+
+	if protection.RequiredReviewers {
+		if rc.Config.DeploymentApprover == nil {
+			return fmt.Errorf("deployment to environment '%s' requires an approval, but gha isn't running interactively", name)
+		}
+		approved, err := rc.Config.DeploymentApprover(ctx, rc.String(), name)
+		if err != nil {
+			return err
+		}
+		if !approved {
+			return fmt.Errorf("deployment of job '%s' to environment '%s' was rejected", rc.String(), name)
+		}
+		logger.Infof("✅  Deployment to environment '%s' was approved", name)
+	}
+
+	if protection.WaitTimer > 0 {
+		logger.Infof("⏳  Waiting %s before deploying to environment '%s'", protection.WaitTimer, name)
+		var cancelled <-chan struct{}
+		if cctx := common.JobCancelContext(ctx); cctx != nil {
+			cancelled = cctx.Done()
+		}
+		select {
+		case <-time.After(protection.WaitTimer):
+		case <-cancelled:
+			return fmt.Errorf("deployment to environment '%s' was cancelled during its wait timer", name)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// withEnvironmentValues overlays the secrets or vars of the job's deployment environment
+func (rc *RunContext) withEnvironmentValues(values map[string]string, environments EnvironmentValues) map[string]string {
+	if rc.DeploymentEnvironment == nil {
+		return values
+	}
+	environment := environmentValues(environments, rc.DeploymentEnvironment.Name)
+	if len(environment) == 0 {
+		return values
+	}
+	merged := make(map[string]string, len(values))
+	for k, v := range values {
+		merged[k] = v
+	}
+	for k, v := range environment {
+		merged[k] = v
+	}
+	return merged
+}
+
+func environmentValues(environments EnvironmentValues, name string) map[string]string {
+	if environments == nil {
+		return nil
+	}
+	return environments(name)
+}
+
+// environmentURL evaluates the url of the deployment environment, it can refer to step outputs
+// and is evaluated once the job has finished
+func (rc *RunContext) environmentURL(ctx context.Context) string {
+	if rc.DeploymentEnvironment == nil || rc.DeploymentEnvironment.URL == "" {
+		return ""
+	}
+	return rc.NewExpressionEvaluator(ctx).Interpolate(ctx, rc.DeploymentEnvironment.URL)
+}
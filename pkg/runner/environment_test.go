@@ -0,0 +1,174 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/history"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDeploymentRunContext(t *testing.T, config *Config) *RunContext {
+	workflow, err := model.ReadWorkflow(strings.NewReader(`
+name: deploy
+on: push
+jobs:
+  deploy:
+    runs-on: ubuntu-latest
+    environment:
+      name: ${{ matrix.env }}
+      url: https://${{ vars.HOST }}
+    steps:
+    - run: echo
+`), false)
+	require.NoError(t, err)
+
+	rc := &RunContext{
+		Config:      config,
+		Run:         &model.Run{JobID: "deploy", Workflow: workflow},
+		Matrix:      map[string]interface{}{"env": "production"},
+		StepResults: map[string]*model.StepResult{},
+	}
+	rc.ExprEval = rc.NewExpressionEvaluator(context.Background())
+	return rc
+}
+
+func TestDeploymentEnvironmentValues(t *testing.T) {
+	rc := newDeploymentRunContext(t, &Config{
+		Secrets: map[string]string{"TOKEN": "default", "OTHER": "other"},
+		Vars:    map[string]string{"HOST": "localhost"},
+		EnvironmentSecrets: func(environment string) map[string]string {
+			return map[string]map[string]string{"production": {"TOKEN": "production"}}[environment]
+		},
+		EnvironmentVars: func(environment string) map[string]string {
+			return map[string]map[string]string{"production": {"HOST": "example.com"}}[environment]
+		},
+	})
+	ctx := context.Background()
+
+	require.NoError(t, rc.withDeploymentEnvironment(ctx))
+	assert.Equal(t, "production", rc.DeploymentEnvironment.Name)
+	assert.Equal(t, "production other", rc.ExprEval.Interpolate(ctx, "${{ secrets.TOKEN }} ${{ secrets.OTHER }}"))
+	assert.Equal(t, []string{"production"}, rc.Masks)
+	assert.Equal(t, "https://example.com", rc.environmentURL(ctx))
+	assert.Equal(t, "default", rc.Config.Secrets["TOKEN"])
+}
+
+func TestDeploymentEnvironmentProtection(t *testing.T) {
+	approver := func(approved bool) DeploymentApprover {
+		return func(_ context.Context, job string, environment string) (bool, error) {
+			assert.Equal(t, "deploy/deploy", job)
+			assert.Equal(t, "production", environment)
+			return approved, nil
+		}
+	}
+
+	table := []struct {
+		name       string
+		protection EnvironmentProtection
+		approver   DeploymentApprover
+		err        string
+	}{
+		{"approved", EnvironmentProtection{RequiredReviewers: true}, approver(true), ""},
+		{"rejected", EnvironmentProtection{RequiredReviewers: true}, approver(false), "deployment of job 'deploy/deploy' to environment 'production' was rejected"},
+		{"not interactive", EnvironmentProtection{RequiredReviewers: true}, nil, "deployment to environment 'production' requires an approval, but gha isn't running interactively"},
+		{"wait timer", EnvironmentProtection{WaitTimer: time.Millisecond}, nil, ""},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := newDeploymentRunContext(t, &Config{
+				EnvironmentProtections: map[string]EnvironmentProtection{"production": tt.protection},
+				DeploymentApprover:     tt.approver,
+			})
+			rc.Name = "deploy"
+
+			err := rc.withDeploymentEnvironment(context.Background())
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDeploymentEnvironmentRejected(t *testing.T) {
+	dir := t.TempDir()
+	workflows := filepath.Join(dir, ".github", "workflows")
+	require.NoError(t, os.MkdirAll(workflows, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(workflows, "ci.yml"), []byte(`name: CI
+on: push
+jobs:
+  deploy:
+    runs-on: ubuntu-latest
+    environment: production
+    steps:
+      - run: echo
+  release:
+    uses: ./.github/workflows/release.yml
+`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(workflows, "release.yml"), []byte(`name: Release
+on: workflow_call
+jobs:
+  publish:
+    runs-on: ubuntu-latest
+    environment: production
+    steps:
+      - run: echo
+`), 0o600))
+	planner, err := model.NewWorkflowPlanner(filepath.Join(workflows, "ci.yml"), true, false)
+	require.NoError(t, err)
+	plan, err := planner.PlanEvent("push")
+	require.NoError(t, err)
+
+	hooks := &recordingHooks{}
+	config := &Config{
+		Workdir:                dir,
+		EventName:              "push",
+		Platforms:              map[string]string{"ubuntu-latest": "node:16-buster-slim"},
+		Hooks:                  hooks,
+		History:                &history.Store{Dir: t.TempDir()},
+		EnvironmentProtections: map[string]EnvironmentProtection{"production": {RequiredReviewers: true}},
+		DeploymentApprover: func(context.Context, string, string) (bool, error) {
+			return false, nil
+		},
+	}
+	r, err := New(config)
+	require.NoError(t, err)
+
+	// the rejected job fails like a job with a failing step does
+	assert.Error(t, r.NewPlanExecutor(plan)(context.Background()))
+	for _, run := range plan.Stages[0].Runs {
+		// the calling job gets the result of the rejected job of the reusable workflow
+		assert.Equal(t, "failure", run.Job().Result, run.JobID)
+	}
+	assert.Contains(t, hooks.calls, "job end CI/deploy failure")
+	run, err := config.History.Get(1)
+	require.NoError(t, err)
+	assert.Equal(t, "failure", run.Conclusion)
+	for _, job := range run.Jobs {
+		assert.Equal(t, history.StatusCompleted, job.Status, job.Name)
+		assert.Equal(t, "failure", job.Conclusion, job.Name)
+		assert.False(t, job.StartedAt.IsZero(), job.Name)
+		assert.False(t, job.CompletedAt.IsZero(), job.Name)
+	}
+}
+
+func TestDeploymentEnvironmentWaitTimerCancelled(t *testing.T) {
+	rc := newDeploymentRunContext(t, &Config{
+		EnvironmentProtections: map[string]EnvironmentProtection{"production": {WaitTimer: time.Hour}},
+	})
+	cancelCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := rc.withDeploymentEnvironment(common.WithJobCancelContext(context.Background(), cancelCtx))
+	assert.EqualError(t, err, "deployment to environment 'production' was cancelled during its wait timer")
+}
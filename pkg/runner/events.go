@@ -0,0 +1,98 @@
+package runner
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/events"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+// newPlanEventsExecutor emits the plan of the run before its jobs are queued
+func newPlanEventsExecutor(plan *model.Plan, executor common.Executor) common.Executor {
+	return func(ctx context.Context) error {
+		if events.FromContext(ctx) == nil {
+			return executor(ctx)
+		}
+		p := &events.Plan{Workflows: []string{}, Stages: [][]string{}}
+		for _, stage := range plan.Stages {
+			jobIDs := make([]string, 0, len(stage.Runs))
+			for _, r := range stage.Runs {
+				jobIDs = append(jobIDs, r.JobID)
+				if !slices.Contains(p.Workflows, r.Workflow.Name) {
+					p.Workflows = append(p.Workflows, r.Workflow.Name)
+				}
+			}
+			p.Stages = append(p.Stages, jobIDs)
+		}
+		events.Emit(ctx, events.Event{Type: events.PlanCreated, Plan: p})
+		return executor(ctx)
+	}
+}
+
+// eventJob returns the job of the events of the run context, the job calling a composite action
+func (rc *RunContext) eventJob() *events.Job {
+	rc = rc.jobRunContext()
+	job := &events.Job{
+		ID:       rc.JobName,
+		Name:     rc.String(),
+		Workflow: rc.Run.Workflow.Name,
+		Matrix:   rc.Matrix,
+	}
+	if rc.caller != nil {
+		job.Caller = rc.caller.runContext.JobName
+	}
+	return job
+}
+
+// emitJobEvent emits an event of the job, the result is set for the finished jobs
+func (rc *RunContext) emitJobEvent(ctx context.Context, eventType events.Type, result string) {
+	if events.FromContext(ctx) == nil {
+		return
+	}
+	job := rc.eventJob()
+	job.Result = result
+	events.Emit(ctx, events.Event{Type: eventType, Job: job})
+}
+
+// emitStepEvent emits an event of a step of the job, of the current step if the event has no step
+func (rc *RunContext) emitStepEvent(ctx context.Context, event events.Event) {
+	if events.FromContext(ctx) == nil {
+		return
+	}
+	event.Job = rc.eventJob()
+	if event.Step == nil {
+		event.Step = &events.Step{ID: rc.CurrentStep}
+	}
+	// the values are masked like in the log of the job
+	if event.Output != nil {
+		event.Output = &events.Output{Name: event.Output.Name, Value: rc.mask(event.Output.Value)}
+	}
+	if event.Annotation != nil {
+		annotation := *event.Annotation
+		annotation.Message = rc.mask(annotation.Message)
+		annotation.Title = rc.mask(annotation.Title)
+		event.Annotation = &annotation
+	}
+	events.Emit(ctx, event)
+}
+
+// mask replaces the secrets and the masked values in s, like in the log of the job
+func (rc *RunContext) mask(s string) string {
+	if rc.Config.InsecureSecrets {
+		return s
+	}
+	for _, v := range rc.Config.Secrets {
+		if v != "" {
+			s = strings.ReplaceAll(s, v, "***")
+		}
+	}
+	for _, v := range rc.Masks {
+		if v != "" {
+			s = strings.ReplaceAll(s, v, "***")
+		}
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/events"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+func readEvents(t *testing.T, buf *bytes.Buffer) []events.Event {
+	var read []events.Event
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var event events.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		read = append(read, event)
+	}
+	return read
+}
+
+func TestPlanEventsExecutor(t *testing.T) {
+	buf := &bytes.Buffer{}
+	ctx := events.WithWriter(context.Background(), events.NewWriter(buf))
+	ci := &model.Workflow{Name: "CI"}
+	plan := &model.Plan{Stages: []*model.Stage{
+		{Runs: []*model.Run{{Workflow: ci, JobID: "build"}, {Workflow: &model.Workflow{Name: "Lint"}, JobID: "lint"}}},
+		{Runs: []*model.Run{{Workflow: ci, JobID: "test"}}},
+	}}
+	ran := false
+	err := newPlanEventsExecutor(plan, func(_ context.Context) error {
+		ran = true
+		return nil
+	})(ctx)
+	require.NoError(t, err)
+	assert.True(t, ran)
+
+	read := readEvents(t, buf)
+	require.Len(t, read, 1)
+	assert.Equal(t, events.PlanCreated, read[0].Type)
+	assert.Equal(t, &events.Plan{Workflows: []string{"CI", "Lint"}, Stages: [][]string{{"build", "lint"}, {"test"}}}, read[0].Plan)
+}
+
+func TestCommandEvents(t *testing.T) {
+	buf := &bytes.Buffer{}
+	ctx := events.WithWriter(context.Background(), events.NewWriter(buf))
+	workflow := &model.Workflow{Name: "CI", Jobs: map[string]*model.Job{"test": {}}}
+	rc := &RunContext{
+		Config:      &Config{Secrets: map[string]string{"TOKEN": "s3cr3t"}},
+		Run:         &model.Run{Workflow: workflow, JobID: "test"},
+		Name:        "test-1",
+		JobName:     "test",
+		Matrix:      map[string]interface{}{"os": "ubuntu"},
+		StepResults: map[string]*model.StepResult{"build": {Outputs: map[string]string{}}},
+		CurrentStep: "build",
+	}
+	handler := rc.commandHandler(ctx)
+	handler("::set-output name=token::s3cr3t\n")
+	handler("::warning file=main.go,line=2,title=Unused s3cr3t::token s3cr3t is unused\n")
+
+	job := &events.Job{ID: "test", Name: "CI/test-1", Workflow: "CI", Matrix: map[string]interface{}{"os": "ubuntu"}}
+	read := readEvents(t, buf)
+	require.Len(t, read, 2)
+	assert.Equal(t, events.OutputSet, read[0].Type)
+	assert.Equal(t, job, read[0].Job)
+	assert.Equal(t, &events.Step{ID: "build"}, read[0].Step)
+	assert.Equal(t, &events.Output{Name: "token", Value: "***"}, read[0].Output)
+	assert.Equal(t, events.AnnotationAdded, read[1].Type)
+	assert.Equal(t, &model.Annotation{Level: "warning", Message: "token *** is unused", Title: "Unused ***", File: "main.go", Line: 2}, read[1].Annotation)
+
+	// the step results keep the values
+	assert.Equal(t, "s3cr3t", rc.StepResults["build"].Outputs["token"])
+	assert.Equal(t, "token s3cr3t is unused", rc.StepResults["build"].Annotations[0].Message)
+}
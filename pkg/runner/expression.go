@@ -583,12 +583,12 @@ func getWorkflowSecrets(ctx context.Context, rc *RunContext) map[string]string {
 			secrets[k] = rc.caller.runContext.ExprEval.Interpolate(ctx, v)
 		}
 
-		return secrets
+		return rc.withEnvironmentValues(secrets, rc.Config.EnvironmentSecrets)
 	}
 
-	return rc.Config.Secrets
+	return rc.withEnvironmentValues(rc.Config.Secrets, rc.Config.EnvironmentSecrets)
 }
 
 func getWorkflowVars(_ context.Context, rc *RunContext) map[string]string {
-	return rc.Config.Vars
+	return rc.withEnvironmentValues(rc.Config.Vars, rc.Config.EnvironmentVars)
 }
@@ -0,0 +1,354 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/container"
+	"github.com/Leapfrog-DevOps/gha/pkg/history"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+type runHistoryContextKey string
+
+const runHistoryContextKeyVal = runHistoryContextKey("runner.runHistory")
+
+// runHistory records the jobs of a run, including the jobs of called reusable workflows
+type runHistory struct {
+	mu    sync.Mutex
+	run   *history.Run
+	store *history.Store // the history the run is checkpointed to, nil if the run is not recorded
+	jobs  []*jobHistory
+}
+
+func runHistoryFromContext(ctx context.Context) *runHistory {
+	if h, ok := ctx.Value(runHistoryContextKeyVal).(*runHistory); ok {
+		return h
+	}
+	return nil
+}
+
+func (h *runHistory) addJob(rc *RunContext) *jobHistory {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	job := &jobHistory{
+		run: h,
+		rc:  rc,
+		job: &history.Job{
+			ID:       rc.JobName,
+			Name:     rc.String(),
+			Workflow: rc.Run.Workflow.Name,
+			Matrix:   rc.Matrix,
+			Status:   "queued",
+			Steps:    []*history.Step{},
+		},
+	}
+	h.jobs = append(h.jobs, job)
+	h.run.Jobs = append(h.run.Jobs, job.job)
+	return job
+}
+
+// checkpoint writes the run to the history when a job completed, to resume its completed jobs if gha stops
+func (h *runHistory) checkpoint() {
+	if h == nil || h.store == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, job := range h.jobs {
+		job.mu.Lock()
+		defer job.mu.Unlock()
+	}
+	if err := h.store.Checkpoint(h.run); err != nil {
+		log.Warnf("Unable to checkpoint the run in the history: %v", err)
+	}
+}
+
+func (h *runHistory) complete(ctx context.Context, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, job := range h.jobs {
+		job.finish()
+	}
+	h.run.Status = history.StatusCompleted
+	h.run.CompletedAt = time.Now()
+	switch {
+	case ctx.Err() != nil:
+		h.run.Conclusion = "cancelled"
+	case err != nil:
+		h.run.Conclusion = "failure"
+	default:
+		h.run.Conclusion = "success"
+	}
+}
+
+// newRunHistoryExecutor records the run for the history and the reports of the config. The run is
+// added to the history when it starts and saved with the logs of its steps once it finished, the
+// reports are written then.
+func newRunHistoryExecutor(config *Config, plan *model.Plan, executor common.Executor) common.Executor {
+	return func(ctx context.Context) error {
+		if common.Dryrun(ctx) {
+			return executor(ctx)
+		}
+
+		run := &history.Run{
+			Event:     config.EventName,
+			Workdir:   config.Workdir,
+			Workflows: []string{},
+			Plan:      [][]string{},
+			Status:    history.StatusInProgress,
+			StartedAt: time.Now(),
+			Jobs:      []*history.Job{},
+		}
+		for _, stage := range plan.Stages {
+			jobIDs := make([]string, 0, len(stage.Runs))
+			for _, r := range stage.Runs {
+				jobIDs = append(jobIDs, r.JobID)
+				if !slices.Contains(run.Workflows, r.Workflow.Name) {
+					run.Workflows = append(run.Workflows, r.Workflow.Name)
+				}
+			}
+			run.Plan = append(run.Plan, jobIDs)
+		}
+		store := config.History
+		if store != nil {
+			if err := store.Create(run); err != nil {
+				log.Warnf("Unable to record the run in the history: %v", err)
+				store = nil
+			}
+		}
+		if store == nil && len(config.Reports) == 0 {
+			return executor(ctx)
+		}
+
+		h := &runHistory{run: run, store: store}
+		err := executor(context.WithValue(ctx, runHistoryContextKeyVal, h))
+		h.complete(ctx, err)
+		if store != nil {
+			if saveErr := store.Save(run); saveErr != nil {
+				log.Warnf("Unable to record the run in the history: %v", saveErr)
+			} else {
+				log.Infof("\U0001F4DC  Run #%d recorded, see `gha history show %d`", run.Number, run.Number)
+			}
+		}
+		for _, report := range config.Reports {
+			if reportErr := report.write(run); reportErr != nil {
+				err = errors.Join(err, fmt.Errorf("unable to write the %s report: %w", report.Format, reportErr))
+			} else {
+				log.Infof("\U0001F4CA  %s report written to %s", report.Format, report.Path)
+			}
+		}
+		return err
+	}
+}
+
+// jobHistory records the steps of a job and their output, its methods do nothing if the run is not recorded
+type jobHistory struct {
+	mu      sync.Mutex
+	run     *runHistory
+	rc      *RunContext
+	job     *history.Job
+	step    *history.Step     // the running step, the output lines are added to its log
+	env     map[string]string // the env of the job before the running step, to record the env it adds
+	path    []string          // the path of the job before the running step, to record the paths it adds
+	results map[*history.Step]*model.StepResult
+}
+
+func (j *jobHistory) start() {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.job.Status = "in_progress"
+	j.job.StartedAt = time.Now()
+}
+
+func (j *jobHistory) complete(conclusion string) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	j.job.Status = history.StatusCompleted
+	j.job.CompletedAt = time.Now()
+	j.job.Conclusion = conclusion
+	if outputs := j.rc.Run.Job().Outputs; len(outputs) > 0 {
+		j.job.Outputs = j.maskOutputs(outputs)
+	}
+	if environment := j.rc.DeploymentEnvironment; environment != nil {
+		j.job.Environment = environment.Name
+		j.job.EnvironmentURL = j.rc.mask(j.rc.deploymentURL)
+	}
+	if _, host := j.rc.JobContainer.(*container.HostEnvironment); j.rc.Config.ReuseContainers && j.rc.JobContainer != nil && !host {
+		// the steps of the job can be run again in its container
+		j.job.Container = j.rc.jobContainerName()
+	}
+	j.completeSteps()
+	j.mu.Unlock()
+	j.run.checkpoint()
+}
+
+// completeSteps records the results of the steps
+func (j *jobHistory) completeSteps() {
+	for step, result := range j.results {
+		if step.CompletedAt.IsZero() && !step.StartedAt.IsZero() {
+			// the run was interrupted
+			step.CompletedAt = time.Now()
+		}
+		step.Outcome = result.Outcome.String()
+		step.Conclusion = result.Conclusion.String()
+		step.Outputs = j.maskOutputs(result.Outputs)
+		// the masked outputs can't be restored to the steps after a restart either
+		step.Masked = step.Masked || !maps.Equal(step.Outputs, result.Outputs)
+		step.Annotations = nil
+		for _, annotation := range result.Annotations {
+			annotation.Message = j.rc.mask(annotation.Message)
+			annotation.Title = j.rc.mask(annotation.Title)
+			step.Annotations = append(step.Annotations, annotation)
+		}
+	}
+}
+
+// maskOutputs returns the outputs with the secrets and the masks of the job replaced, like in the logs of
+// the run, the outputs passing a secret are written to the history masked
+func (j *jobHistory) maskOutputs(outputs map[string]string) map[string]string {
+	if outputs == nil {
+		return nil
+	}
+	masked := make(map[string]string, len(outputs))
+	for k, v := range outputs {
+		masked[k] = j.rc.mask(v)
+	}
+	return masked
+}
+
+// finish completes the job and its steps at the end of the run, the jobs that did not run, e.g.
+// because of their `if`, get the result of the job in the workflow
+func (j *jobHistory) finish() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.completeSteps()
+	if j.job.Status == history.StatusCompleted {
+		return
+	}
+	j.job.Conclusion = j.rc.Run.Job().Result
+	if j.job.Conclusion == "" {
+		j.job.Conclusion = "skipped"
+	}
+	if !j.job.StartedAt.IsZero() {
+		j.job.CompletedAt = time.Now()
+	}
+	j.job.Status = history.StatusCompleted
+}
+
+// addStep records a stage of a step, its result is read at the end of the run
+func (j *jobHistory) addStep(stepID string, name string, stage stepStage, result *model.StepResult, started bool) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	step := &history.Step{
+		Number: len(j.job.Steps) + 1,
+		ID:     stepID,
+		Name:   name,
+		Stage:  strings.ToLower(stage.String()),
+	}
+	if stage != stepStageMain {
+		step.Name = stage.String() + " " + name
+	}
+	if j.rc != nil {
+		step.Substitute = j.rc.substitutes[stepID]
+	}
+	if started {
+		step.StartedAt = time.Now()
+		j.step = step
+		if j.rc != nil {
+			j.env = maps.Clone(j.rc.GlobalEnv)
+			j.path = slices.Clone(j.rc.ExtraPath)
+		}
+	}
+	if j.results == nil {
+		j.results = map[*history.Step]*model.StepResult{}
+	}
+	j.results[step] = result
+	j.job.Steps = append(j.job.Steps, step)
+}
+
+func (j *jobHistory) completeStep() {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.step != nil {
+		j.step.CompletedAt = time.Now()
+		if j.rc != nil && j.step.Stage == "main" {
+			j.recordFileCommands(j.step)
+			// the mocked actions of the steps of a composite action are substituted when it runs
+			j.step.Substitute = j.rc.substitutes[j.step.ID]
+		}
+		j.step = nil
+	}
+}
+
+// recordFileCommands records the env, path and state the step changed, a job restarted at a later step
+// restores them
+func (j *jobHistory) recordFileCommands(step *history.Step) {
+	mask := func(values map[string]string) map[string]string {
+		masked := make(map[string]string, len(values))
+		for k, v := range values {
+			masked[k] = j.rc.mask(v)
+			step.Masked = step.Masked || masked[k] != v
+		}
+		return masked
+	}
+	env := map[string]string{}
+	for k, v := range j.rc.GlobalEnv {
+		if previous, ok := j.env[k]; !ok || previous != v {
+			env[k] = v
+		}
+	}
+	if len(env) > 0 {
+		step.Env = mask(env)
+	}
+	for _, p := range j.rc.ExtraPath {
+		if !slices.Contains(j.path, p) {
+			step.Path = append(step.Path, p)
+		}
+	}
+	if state := j.rc.IntraActionState[step.ID]; len(state) > 0 {
+		step.State = mask(state)
+	}
+}
+
+func (j *jobHistory) log(line string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.step == nil {
+		return
+	}
+	j.step.AppendLog(time.Now(), line)
+}
+
+// historyLineHandler adds the output lines to the log of the running step of the job, masked like
+// in the log of the job
+func (rc *RunContext) historyLineHandler() common.LineHandler {
+	return func(line string) bool {
+		job := rc.jobRunContext().history
+		if job == nil {
+			return true
+		}
+		job.log(rc.mask(line))
+		return true
+	}
+}
@@ -0,0 +1,163 @@
+package runner
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/history"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+func TestRunHistoryExecutor(t *testing.T) {
+	workflow := &model.Workflow{Name: "CI", Jobs: map[string]*model.Job{"build": {}, "lint": {}}}
+	plan := &model.Plan{Stages: []*model.Stage{{Runs: []*model.Run{{Workflow: workflow, JobID: "build"}, {Workflow: workflow, JobID: "lint"}}}}}
+	config := &Config{EventName: "push", Workdir: "/home/octocat/repo", Secrets: map[string]string{"TOKEN": "s3cr3t"}, History: &history.Store{Dir: t.TempDir()}}
+
+	executor := newRunHistoryExecutor(config, plan, func(ctx context.Context) error {
+		h := runHistoryFromContext(ctx)
+		require.NotNil(t, h)
+
+		rc := &RunContext{Config: config, Run: &model.Run{Workflow: workflow, JobID: "build"}, Name: "build", JobName: "build",
+			substitutes: map[string]string{"deploy": "octo/deploy@v1 substituted by a no-op of the mock 'octo/*'"}}
+		rc.history = h.addJob(rc)
+		rc.history.start()
+
+		// the outputs and annotations passing secrets are recorded masked
+		result := &model.StepResult{Outputs: map[string]string{"version": "1.0", "token": "s3cr3t"}, Annotations: []model.Annotation{{Level: "warning", Message: "slow with s3cr3t"}}}
+		rc.GlobalEnv = map[string]string{"CI": "true"}
+		rc.ExtraPath = []string{"/usr/local/go/bin"}
+		rc.history.addStep("test", "Run make test", stepStageMain, result, true)
+		// the file commands of the step are recorded to restore them, the ones passing secrets masked
+		rc.GlobalEnv["GOFLAGS"] = "-mod=mod"
+		rc.ExtraPath = append([]string{"/home/runner/go/bin"}, rc.ExtraPath...)
+		rc.IntraActionState = map[string]map[string]string{"test": {"token": "s3cr3t"}}
+		// the output of the steps of composite actions is logged to the step of the job
+		composite := &RunContext{Config: config, Parent: rc, Masks: []string{"hidden"}}
+		composite.historyLineHandler()("using s3cr3t and hidden\n")
+		// the mocked actions of composite actions are substituted while the step runs
+		rc.substitutes["test"] = "octo/test@v1 substituted by a no-op of the mock 'octo/*'"
+		rc.history.completeStep()
+		rc.history.addStep("deploy", "Run make deploy", stepStageMain, &model.StepResult{Conclusion: model.StepStatusSkipped, Outcome: model.StepStatusSkipped,
+			Outputs: map[string]string{"url": "https://example.com"}}, false)
+		rc.historyLineHandler()("not logged to a step\n")
+		rc.Run.Job().Outputs = map[string]string{"version": "1.0", "token": "s3cr3t"}
+		rc.DeploymentEnvironment = &model.DeploymentEnvironment{Name: "production"}
+		rc.deploymentURL = "https://example.com/?token=s3cr3t"
+		rc.history.complete("success")
+
+		// the completed job is checkpointed while the run runs
+		checkpoint, err := config.History.Get(h.run.Number)
+		require.NoError(t, err)
+		assert.Equal(t, history.StatusInProgress, checkpoint.Status)
+		require.Len(t, checkpoint.Jobs, 1)
+		assert.Equal(t, "success", checkpoint.Jobs[0].Conclusion)
+		assert.Equal(t, map[string]string{"version": "1.0", "token": "***"}, checkpoint.Jobs[0].Outputs)
+		assert.Equal(t, "success", checkpoint.Jobs[0].Steps[0].Outcome)
+
+		skipped := &RunContext{Config: config, Run: &model.Run{Workflow: workflow, JobID: "lint"}, Name: "lint", JobName: "lint"}
+		skipped.history = h.addJob(skipped)
+		skipped.result("skipped")
+		return nil
+	})
+	require.NoError(t, executor(common.WithDryrun(context.Background(), false)))
+
+	run, err := config.History.Get(1)
+	require.NoError(t, err)
+	assert.Equal(t, "push", run.Event)
+	assert.Equal(t, []string{"CI"}, run.Workflows)
+	assert.Equal(t, [][]string{{"build", "lint"}}, run.Plan)
+	assert.Equal(t, history.StatusCompleted, run.Status)
+	assert.Equal(t, "success", run.Conclusion)
+	require.Len(t, run.Jobs, 2)
+
+	build := run.Jobs[0]
+	assert.Equal(t, "CI/build", build.Name)
+	assert.Equal(t, "success", build.Conclusion)
+	assert.Equal(t, map[string]string{"version": "1.0", "token": "***"}, build.Outputs)
+	assert.Equal(t, "production", build.Environment)
+	assert.Equal(t, "https://example.com/?token=***", build.EnvironmentURL)
+	require.Len(t, build.Steps, 2)
+	assert.Equal(t, "success", build.Steps[0].Conclusion)
+	assert.Equal(t, "main", build.Steps[0].Stage)
+	assert.Equal(t, map[string]string{"version": "1.0", "token": "***"}, build.Steps[0].Outputs)
+	assert.Equal(t, []model.Annotation{{Level: "warning", Message: "slow with ***"}}, build.Steps[0].Annotations)
+	assert.Equal(t, map[string]string{"GOFLAGS": "-mod=mod"}, build.Steps[0].Env)
+	assert.Equal(t, []string{"/home/runner/go/bin"}, build.Steps[0].Path)
+	assert.Equal(t, map[string]string{"token": "***"}, build.Steps[0].State)
+	assert.True(t, build.Steps[0].Masked)
+	assert.Nil(t, build.Steps[1].Env)
+	assert.False(t, build.Steps[1].Masked)
+	assert.Equal(t, "octo/test@v1 substituted by a no-op of the mock 'octo/*'", build.Steps[0].Substitute)
+	assert.Equal(t, "octo/deploy@v1 substituted by a no-op of the mock 'octo/*'", build.Steps[1].Substitute)
+	assert.False(t, build.Steps[0].CompletedAt.IsZero())
+	assert.Equal(t, "skipped", build.Steps[1].Conclusion)
+	assert.True(t, build.Steps[1].StartedAt.IsZero())
+
+	lint := run.Jobs[1]
+	assert.Equal(t, history.StatusCompleted, lint.Status)
+	assert.Equal(t, "skipped", lint.Conclusion)
+
+	data, err := config.History.Logs(1)
+	require.NoError(t, err)
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.Len(t, reader.File, 2)
+	assert.Equal(t, "CI_build/1_Run make test.txt", reader.File[0].Name)
+	f, err := reader.File[0].Open()
+	require.NoError(t, err)
+	log, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Regexp(t, `^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{7}Z using \*\*\* and \*\*\*\n$`, string(log))
+}
+
+func TestRunHistoryMaskedOutputs(t *testing.T) {
+	workflow := &model.Workflow{Name: "CI", Jobs: map[string]*model.Job{"build": {}}}
+	plan := &model.Plan{Stages: []*model.Stage{{Runs: []*model.Run{{Workflow: workflow, JobID: "build"}}}}}
+	config := &Config{Secrets: map[string]string{"TOKEN": "s3cr3t"}, History: &history.Store{Dir: t.TempDir()}}
+
+	executor := newRunHistoryExecutor(config, plan, func(ctx context.Context) error {
+		rc := &RunContext{Config: config, Run: &model.Run{Workflow: workflow, JobID: "build"}, Name: "build", JobName: "build"}
+		rc.history = runHistoryFromContext(ctx).addJob(rc)
+		rc.history.start()
+		rc.history.addStep("login", "Run login", stepStageMain, &model.StepResult{Outputs: map[string]string{"token": "s3cr3t"}}, true)
+		rc.history.completeStep()
+		rc.history.addStep("build", "Run make", stepStageMain, &model.StepResult{Outputs: map[string]string{"version": "1.0"}}, true)
+		rc.history.completeStep()
+		rc.history.complete("success")
+		return nil
+	})
+	require.NoError(t, executor(common.WithDryrun(context.Background(), false)))
+
+	// the step passing a secret to an output can't be restored by a restart at a later step
+	run, err := config.History.Get(1)
+	require.NoError(t, err)
+	require.Len(t, run.Jobs[0].Steps, 2)
+	assert.Equal(t, map[string]string{"token": "***"}, run.Jobs[0].Steps[0].Outputs)
+	assert.True(t, run.Jobs[0].Steps[0].Masked)
+	assert.False(t, run.Jobs[0].Steps[1].Masked)
+}
+
+func TestRunHistoryExecutorFailure(t *testing.T) {
+	config := &Config{History: &history.Store{Dir: t.TempDir()}}
+	executor := newRunHistoryExecutor(config, &model.Plan{}, func(_ context.Context) error {
+		return assert.AnError
+	})
+
+	assert.ErrorIs(t, executor(common.WithDryrun(context.Background(), false)), assert.AnError)
+	run, err := config.History.Get(1)
+	require.NoError(t, err)
+	assert.Equal(t, "failure", run.Conclusion)
+
+	// dry runs are not recorded
+	require.ErrorIs(t, executor(common.WithDryrun(context.Background(), true)), assert.AnError)
+	runs, err := config.History.List()
+	require.NoError(t, err)
+	assert.Len(t, runs, 1)
+}
@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/container"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+// runnerHookCommand returns the command running the hook script at the path, like a self-hosted runner
+// it runs the .ps1 scripts with PowerShell and the other scripts with bash
+func runnerHookCommand(path string) []string {
+	if strings.EqualFold(filepath.Ext(path), ".ps1") {
+		return []string{"pwsh", "-command", fmt.Sprintf(". '%s'", path)}
+	}
+	return []string{"bash", "-e", path}
+}
+
+// newRunnerHookExecutor runs the hook script of the runner as the pseudo-step name of the job, in the job
+// container or the host environment. The scripts are the ACTIONS_RUNNER_HOOK_JOB_STARTED and
+// ACTIONS_RUNNER_HOOK_JOB_COMPLETED scripts of a self-hosted runner, a failing script fails the job.
+func newRunnerHookExecutor(rc *RunContext, name string, stepID string, script string) common.Executor {
+	if script == "" {
+		return func(_ context.Context) error {
+			return nil
+		}
+	}
+	return common.NewFieldExecutor("step", name, common.NewFieldExecutor("stepid", []string{stepID}, func(ctx context.Context) error {
+		logger := common.Logger(ctx)
+		logger.Infof("⭐ Run %s", name)
+		err := runRunnerHook(ctx, rc, stepID, script)
+		if err != nil {
+			logger.WithField("stepResult", model.StepStatusFailure).Infof("  ❌  Failure - %s", name)
+			return fmt.Errorf("the hook script %s failed: %w", script, err)
+		}
+		logger.WithField("stepResult", model.StepStatusSuccess).Infof("  ✅  Success - %s", name)
+		return nil
+	}))
+}
+
+func runRunnerHook(ctx context.Context, rc *RunContext, stepID string, script string) error {
+	body, err := os.ReadFile(script)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("workflow/%s%s", strings.TrimPrefix(stepID, "--"), filepath.Ext(script))
+	if err := rc.JobContainer.Copy(rc.JobContainer.GetActPath(), &container.FileEntry{Name: name, Mode: 0o755, Body: string(body)})(ctx); err != nil {
+		return err
+	}
+
+	// like on a self-hosted runner, the hooks get the env of the runner and not the env of the workflow
+	env := map[string]string{"ACT": "true"}
+	maps.Copy(env, rc.Config.Env)
+	env = rc.withGithubEnv(ctx, rc.getGithubContext(ctx), env)
+	rc.ApplyExtraPath(ctx, &env)
+
+	rawLogger := common.Logger(ctx).WithField("raw_output", true)
+	logWriter := common.NewLineWriter(func(s string) bool {
+		if rc.Config.LogOutput {
+			rawLogger.Infof("%s", s)
+		} else {
+			rawLogger.Debugf("%s", s)
+		}
+		return true
+	})
+	oldout, olderr := rc.JobContainer.ReplaceLogWriter(logWriter, logWriter)
+	defer rc.JobContainer.ReplaceLogWriter(oldout, olderr)
+
+	return rc.JobContainer.Exec(runnerHookCommand(fmt.Sprintf("%s/%s", rc.JobContainer.GetActPath(), name)), env, "", "")(ctx)
+}
+
+// newJobCompletedHookExecutor runs the job completed hook script, also when the job was cancelled
+func newJobCompletedHookExecutor(rc *RunContext, script string) common.Executor {
+	executor := newRunnerHookExecutor(rc, "A job completed hook", "--job-completed-hook", script)
+	return func(ctx context.Context) error {
+		if ctx.Err() != nil {
+			// the hook cleans up after the job, like the post steps it runs when the run was aborted
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(common.WithLogger(context.Background(), common.Logger(ctx)), 5*time.Minute)
+			defer cancel()
+		}
+		return executor(ctx)
+	}
+}
@@ -0,0 +1,156 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/container"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+// hookContainerMock records the files copied to the container and the commands executed in it
+type hookContainerMock struct {
+	jobContainerMock
+	order *[]string
+	files map[string]string
+	fail  string // the commands containing fail fail
+}
+
+func (m *hookContainerMock) GetActPath() string {
+	return "/var/run/act"
+}
+
+func (m *hookContainerMock) Copy(destPath string, files ...*container.FileEntry) common.Executor {
+	return func(_ context.Context) error {
+		for _, f := range files {
+			m.files[destPath+"/"+f.Name] = f.Body
+		}
+		return nil
+	}
+}
+
+func (m *hookContainerMock) Exec(command []string, env map[string]string, _, _ string) common.Executor {
+	return func(_ context.Context) error {
+		*m.order = append(*m.order, fmt.Sprintf("exec %s GITHUB_JOB=%s", strings.Join(command, " "), env["GITHUB_JOB"]))
+		if m.fail != "" && strings.Contains(strings.Join(command, " "), m.fail) {
+			return fmt.Errorf("exit status 1")
+		}
+		return nil
+	}
+}
+
+func TestRunnerHookScripts(t *testing.T) {
+	dir := t.TempDir()
+	started := filepath.Join(dir, "started.sh")
+	completed := filepath.Join(dir, "completed.ps1")
+	require.NoError(t, os.WriteFile(started, []byte("rm -rf \"$GITHUB_WORKSPACE\"/*"), 0o755))
+	require.NoError(t, os.WriteFile(completed, []byte("Write-Host done"), 0o755))
+
+	for _, tt := range []struct {
+		name   string
+		fail   string
+		order  []string
+		result string
+	}{
+		{
+			name: "success",
+			order: []string{
+				"startContainer",
+				"exec bash -e /var/run/act/workflow/job-started-hook.sh GITHUB_JOB=test",
+				"step1",
+				"post1",
+				"exec pwsh -command . '/var/run/act/workflow/job-completed-hook.ps1' GITHUB_JOB=test",
+				"stopContainer",
+				"interpolateOutputs",
+				"closeContainer",
+			},
+			result: "success",
+		},
+		{
+			name: "failing job started hook",
+			fail: "job-started-hook",
+			order: []string{
+				"startContainer",
+				"exec bash -e /var/run/act/workflow/job-started-hook.sh GITHUB_JOB=test",
+				"exec pwsh -command . '/var/run/act/workflow/job-completed-hook.ps1' GITHUB_JOB=test",
+				"interpolateOutputs",
+				"closeContainer",
+			},
+			result: "failure",
+		},
+		{
+			name: "failing job completed hook",
+			fail: "job-completed-hook",
+			order: []string{
+				"startContainer",
+				"exec bash -e /var/run/act/workflow/job-started-hook.sh GITHUB_JOB=test",
+				"step1",
+				"post1",
+				"exec pwsh -command . '/var/run/act/workflow/job-completed-hook.ps1' GITHUB_JOB=test",
+				"interpolateOutputs",
+				"closeContainer",
+			},
+			result: "failure",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := common.WithJobErrorContainer(context.Background())
+			order := []string{}
+			record := func(name string) func(context.Context) error {
+				return func(_ context.Context) error {
+					order = append(order, name)
+					return nil
+				}
+			}
+			jobContainer := &hookContainerMock{order: &order, files: map[string]string{}, fail: tt.fail}
+			rc := &RunContext{
+				JobContainer: jobContainer,
+				Run: &model.Run{
+					JobID:    "test",
+					Workflow: &model.Workflow{Jobs: map[string]*model.Job{"test": {}}},
+				},
+				Config:           &Config{JobStartedHook: started, JobCompletedHook: completed},
+				nodeToolFullPath: "node",
+			}
+			rc.ExprEval = rc.NewExpressionEvaluator(ctx)
+
+			stepModel := &model.Step{ID: "1"}
+			sm := &stepMock{}
+			sm.On("pre").Return(func(_ context.Context) error { return nil })
+			sm.On("main").Return(record("step1"))
+			sm.On("post").Return(record("post1"))
+			sfm := &stepFactoryMock{}
+			sfm.On("newStep", stepModel, rc).Return(sm, nil)
+			jim := &jobInfoMock{}
+			jim.On("steps").Return([]*model.Step{stepModel})
+			jim.On("matrix").Return(map[string]interface{}{})
+			jim.On("startContainer").Return(record("startContainer"))
+			if tt.result == "success" {
+				// the container of a failed job is kept
+				jim.On("stopContainer").Return(record("stopContainer"))
+			}
+			jim.On("interpolateOutputs").Return(record("interpolateOutputs"))
+			jim.On("closeContainer").Return(record("closeContainer"))
+			jim.On("result", tt.result)
+
+			err := newJobExecutor(jim, sfm, rc)(ctx)
+			if tt.fail == "job-started-hook" {
+				assert.ErrorContains(t, err, "the hook script "+started+" failed: exit status 1")
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.order, order)
+			assert.Equal(t, tt.result == "failure", common.JobError(ctx) != nil)
+			assert.Equal(t, "rm -rf \"$GITHUB_WORKSPACE\"/*", jobContainer.files["/var/run/act/workflow/job-started-hook.sh"])
+			jim.AssertExpectations(t)
+		})
+	}
+}
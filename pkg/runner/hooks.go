@@ -0,0 +1,185 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/events"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+// ErrSkipJob is returned by Hooks.OnJobStart to skip the job, like a job with a false `if`
+var ErrSkipJob = errors.New("skip job")
+
+// Hooks are called at the lifecycle events of the run by programs that embed the runner. The jobs of
+// a stage run concurrently, so the hooks must be safe to call from several goroutines. Embed
+// NoopHooks to implement only some of them.
+type Hooks interface {
+	// OnPlanStart is called before the first stage of the plan
+	OnPlanStart(ctx context.Context, plan *model.Plan)
+	// OnPlanEnd is called after the plan ran, with the error of the run
+	OnPlanEnd(ctx context.Context, plan *model.Plan, err error)
+	// OnJobStart is called before a job runs, once its `if` passed. It returns ErrSkipJob to skip
+	// the job, or any other error to fail it without running it.
+	OnJobStart(ctx context.Context, job *HookJob) error
+	// OnJobEnd is called with the result of the job: success, failure or skipped
+	OnJobEnd(ctx context.Context, job *HookJob, result string)
+	// OnStepStart is called before a stage of a step runs
+	OnStepStart(ctx context.Context, job *HookJob, step *HookStep)
+	// OnStepEnd is called with the result of a stage of a step, also for skipped steps
+	OnStepEnd(ctx context.Context, job *HookJob, step *HookStep, result *model.StepResult)
+	// OnOutput is called when a step sets an output, the value is not masked
+	OnOutput(ctx context.Context, job *HookJob, stepID string, name string, value string)
+}
+
+// HookJob is the job of a hook, a leg of a matrix is a job of its own with a unique name
+type HookJob struct {
+	ID       string
+	Name     string
+	Workflow string
+	Matrix   map[string]interface{}
+	Caller   string // the ID of the job calling the reusable workflow of the job
+	Job      *model.Job
+	cancel   *jobCancel
+}
+
+// Cancel cancels the job like Ctrl+C does, its `if: always()` steps, post steps and cleanup still run.
+// A job cancelled before it started is cancelled as soon as it starts. Its result is cancelled.
+func (j *HookJob) Cancel() {
+	j.cancel.request()
+}
+
+// jobCancel cancels the running job on the request of a hook
+type jobCancel struct {
+	mu        sync.Mutex
+	cancel    context.CancelCauseFunc
+	requested bool
+}
+
+// errHookCancelled is the cause of the cancellation by a hook, it gives the job the result cancelled
+var errHookCancelled = fmt.Errorf("a hook %w", errJobCancelled)
+
+// set sets the cancel func of the running job, it is called right away if the job was already cancelled
+func (c *jobCancel) set(cancel context.CancelCauseFunc) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cancel = cancel
+	if c.requested {
+		cancel(errHookCancelled)
+	}
+}
+
+func (c *jobCancel) request() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requested = true
+	if c.cancel != nil {
+		c.cancel(errHookCancelled)
+	}
+}
+
+// HookStep is a stage of a step of a hook
+type HookStep struct {
+	ID    string
+	Name  string
+	Stage string // pre, main or post
+	Step  *model.Step
+}
+
+// NoopHooks implements the Hooks with hooks that do nothing
+type NoopHooks struct{}
+
+func (NoopHooks) OnPlanStart(context.Context, *model.Plan)                          {}
+func (NoopHooks) OnPlanEnd(context.Context, *model.Plan, error)                     {}
+func (NoopHooks) OnJobStart(context.Context, *HookJob) error                        { return nil }
+func (NoopHooks) OnJobEnd(context.Context, *HookJob, string)                        {}
+func (NoopHooks) OnStepStart(context.Context, *HookJob, *HookStep)                  {}
+func (NoopHooks) OnStepEnd(context.Context, *HookJob, *HookStep, *model.StepResult) {}
+func (NoopHooks) OnOutput(context.Context, *HookJob, string, string, string)        {}
+
+// hooks returns the hooks of the config, nil if it has none
+func (rc *RunContext) hooks() Hooks {
+	if rc.Config == nil {
+		return nil
+	}
+	return rc.Config.Hooks
+}
+
+// newPlanHooksExecutor calls the plan hooks around the run
+func newPlanHooksExecutor(hooks Hooks, plan *model.Plan, executor common.Executor) common.Executor {
+	return func(ctx context.Context) error {
+		hooks.OnPlanStart(ctx, plan)
+		err := executor(ctx)
+		hooks.OnPlanEnd(ctx, plan, err)
+		return err
+	}
+}
+
+// hookJob returns the job of the hooks of the run context, the job calling a composite action
+func (rc *RunContext) hookJob() *HookJob {
+	rc = rc.jobRunContext()
+	job := &HookJob{
+		ID:       rc.JobName,
+		Name:     rc.String(),
+		Workflow: rc.Run.Workflow.Name,
+		Matrix:   rc.Matrix,
+		Job:      rc.Run.Job(),
+		cancel:   rc.cancel,
+	}
+	if rc.caller != nil {
+		job.Caller = rc.caller.runContext.JobName
+	}
+	return job
+}
+
+func newHookStep(stepModel *model.Step, name string, stage stepStage) *HookStep {
+	return &HookStep{ID: stepModel.ID, Name: name, Stage: strings.ToLower(stage.String()), Step: stepModel}
+}
+
+// jobFinished reports the result of the job to the events and the hooks
+func (rc *RunContext) jobFinished(ctx context.Context, result string) {
+	rc.emitJobEvent(ctx, events.JobFinished, result)
+	if hooks := rc.hooks(); hooks != nil {
+		hooks.OnJobEnd(ctx, rc.hookJob(), result)
+	}
+}
+
+// stepStarted reports the start of a stage of the step to the events and the hooks
+func (rc *RunContext) stepStarted(ctx context.Context, stepModel *model.Step, name string, stage stepStage) {
+	rc.emitStepEvent(ctx, events.Event{Type: events.StepStarted, Step: &events.Step{ID: stepModel.ID, Name: name, Stage: strings.ToLower(stage.String())}})
+	if hooks := rc.hooks(); hooks != nil {
+		hooks.OnStepStart(ctx, rc.hookJob(), newHookStep(stepModel, name, stage))
+	}
+}
+
+// stepFinished reports the result of a stage of the step to the events and the hooks
+func (rc *RunContext) stepFinished(ctx context.Context, stepModel *model.Step, name string, stage stepStage, result *model.StepResult) {
+	rc.emitStepEvent(ctx, events.Event{Type: events.StepFinished, Step: &events.Step{
+		ID:         stepModel.ID,
+		Name:       name,
+		Stage:      strings.ToLower(stage.String()),
+		Outcome:    result.Outcome.String(),
+		Conclusion: result.Conclusion.String(),
+	}})
+	if hooks := rc.hooks(); hooks != nil {
+		hooks.OnStepEnd(ctx, rc.hookJob(), newHookStep(stepModel, name, stage), result)
+	}
+}
+
+// startJobHook asks the hooks whether the job may run
+func (rc *RunContext) startJobHook(ctx context.Context) error {
+	if hooks := rc.hooks(); hooks != nil {
+		return hooks.OnJobStart(ctx, rc.hookJob())
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+// recordingHooks records the calls of the hooks, and skips or vetoes the jobs of the map
+type recordingHooks struct {
+	NoopHooks
+	mu    sync.Mutex
+	calls []string
+	start map[string]error
+}
+
+func (h *recordingHooks) record(format string, args ...interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.calls = append(h.calls, fmt.Sprintf(format, args...))
+}
+
+func (h *recordingHooks) OnPlanStart(_ context.Context, plan *model.Plan) {
+	h.record("plan start %d stages", len(plan.Stages))
+}
+
+func (h *recordingHooks) OnPlanEnd(_ context.Context, _ *model.Plan, err error) {
+	h.record("plan end %v", err)
+}
+
+func (h *recordingHooks) OnJobStart(_ context.Context, job *HookJob) error {
+	h.record("job start %s", job.Name)
+	// the legs of a matrix are skipped or vetoed by their name
+	if err, ok := h.start[job.Name]; ok {
+		return err
+	}
+	return h.start[job.ID]
+}
+
+func (h *recordingHooks) OnJobEnd(_ context.Context, job *HookJob, result string) {
+	h.record("job end %s %s", job.Name, result)
+}
+
+func (h *recordingHooks) OnStepStart(_ context.Context, job *HookJob, step *HookStep) {
+	h.record("step start %s %s %s %s", job.Name, step.ID, step.Stage, step.Name)
+}
+
+func (h *recordingHooks) OnStepEnd(_ context.Context, job *HookJob, step *HookStep, result *model.StepResult) {
+	h.record("step end %s %s %s %s", job.Name, step.ID, result.Outcome, result.Conclusion)
+}
+
+func (h *recordingHooks) OnOutput(_ context.Context, job *HookJob, stepID string, name string, value string) {
+	h.record("output %s %s %s=%s", job.Name, stepID, name, value)
+}
+
+func TestHooksSkipAndVetoJobs(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ci.yml"), []byte(`name: CI
+on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    strategy:
+      fail-fast: false
+      max-parallel: 1
+      matrix:
+        version: [1, 2]
+    steps:
+      - run: exit 1
+  deploy:
+    runs-on: ubuntu-latest
+    steps:
+      - run: exit 1
+  lint:
+    runs-on: ubuntu-latest
+    if: false
+    steps:
+      - run: exit 1
+  test:
+    runs-on: ubuntu-latest
+    steps:
+      - run: exit 1
+`), 0o600))
+	planner, err := model.NewWorkflowPlanner(dir, true, false)
+	require.NoError(t, err)
+	plan, err := planner.PlanEvent("push")
+	require.NoError(t, err)
+
+	// the skipped second leg of the matrix doesn't overwrite the result of the vetoed first one
+	hooks := &recordingHooks{start: map[string]error{"deploy": errors.New("frozen"), "test": ErrSkipJob,
+		"CI/build-1": errors.New("frozen"), "CI/build-2": ErrSkipJob}}
+	r, err := New(&Config{
+		Workdir:   dir,
+		EventName: "push",
+		Platforms: map[string]string{"ubuntu-latest": "node:16-buster-slim"},
+		Hooks:     hooks,
+	})
+	require.NoError(t, err)
+
+	err = r.NewPlanExecutor(plan)(context.Background())
+	assert.Error(t, err)
+	for _, run := range plan.Stages[0].Runs {
+		assert.Equal(t, map[string]string{"build": "failure", "deploy": "failure", "lint": "skipped", "test": "skipped"}[run.JobID], run.Job().Result, run.JobID)
+	}
+	assert.ElementsMatch(t, []string{
+		"plan start 1 stages",
+		"job start CI/build-1",
+		"job end CI/build-1 failure",
+		"job start CI/build-2",
+		"job end CI/build-2 skipped",
+		"job start CI/deploy",
+		"job end CI/deploy failure",
+		"job end CI/lint skipped",
+		"job start CI/test",
+		"job end CI/test skipped",
+		fmt.Sprintf("plan end %v", err),
+	}, hooks.calls)
+	assert.Equal(t, "plan start 1 stages", hooks.calls[0])
+	assert.Equal(t, fmt.Sprintf("plan end %v", err), hooks.calls[len(hooks.calls)-1])
+}
+
+func TestStepAndOutputHooks(t *testing.T) {
+	hooks := &recordingHooks{}
+	workflow := &model.Workflow{Name: "CI", Jobs: map[string]*model.Job{"test": {}}}
+	rc := &RunContext{
+		Config:      &Config{Hooks: hooks, Secrets: map[string]string{"TOKEN": "s3cr3t"}},
+		Run:         &model.Run{Workflow: workflow, JobID: "test"},
+		Name:        "test",
+		JobName:     "test",
+		StepResults: map[string]*model.StepResult{"build": {Outputs: map[string]string{}}},
+		CurrentStep: "build",
+	}
+	step := &model.Step{ID: "build", Run: "make"}
+	result := &model.StepResult{Outcome: model.StepStatusFailure, Conclusion: model.StepStatusSuccess}
+
+	ctx := context.Background()
+	rc.stepStarted(ctx, step, "Run make", stepStagePost)
+	rc.commandHandler(ctx)("::set-output name=token::s3cr3t\n")
+	rc.stepFinished(ctx, step, "Run make", stepStagePost, result)
+
+	assert.Equal(t, []string{
+		"step start CI/test build post Run make",
+		"output CI/test build token=s3cr3t",
+		"step end CI/test build failure success",
+	}, hooks.calls)
+}
+
+func TestHookJobCancel(t *testing.T) {
+	rc := &RunContext{
+		Run:     &model.Run{Workflow: &model.Workflow{Name: "CI", Jobs: map[string]*model.Job{"test": {}}}, JobID: "test"},
+		Name:    "test",
+		JobName: "test",
+		cancel:  &jobCancel{},
+	}
+
+	// a job cancelled before it started is cancelled when it starts
+	rc.hookJob().Cancel()
+	ctx, cancel := context.WithCancelCause(context.Background())
+	rc.cancel.set(cancel)
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+
+	rc.cancel = &jobCancel{}
+	ctx, cancel = context.WithCancelCause(context.Background())
+	rc.cancel.set(cancel)
+	job := rc.hookJob()
+	assert.NoError(t, ctx.Err())
+	job.Cancel()
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	// the cancelled job gets the result cancelled instead of success
+	assert.ErrorIs(t, jobCancellationError(common.WithJobCancelContext(context.Background(), ctx)), errJobCancelled)
+	assert.EqualError(t, context.Cause(ctx), "a hook cancelled the job")
+
+	// the jobs of run contexts without a cancel are not cancelled
+	(&HookJob{}).Cancel()
+}
@@ -2,11 +2,13 @@ package runner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/Leapfrog-DevOps/gha/pkg/common"
 	"github.com/Leapfrog-DevOps/gha/pkg/model"
+	"github.com/Leapfrog-DevOps/gha/pkg/tracing"
 )
 
 type jobInfo interface {
@@ -63,6 +65,13 @@ func newJobExecutor(info jobInfo, sf stepFactory, rc *RunContext) common.Executo
 		return err
 	}
 
+	// the steps before the step the job restarts at ran in the resumed run
+	resumedSteps, err := rc.resumedSteps()
+	if err != nil {
+		return common.NewErrorExecutor(err)
+	}
+	restarted := resumedSteps == nil
+
 	for i, stepModel := range infoSteps {
 		if stepModel == nil {
 			return func(_ context.Context) error {
@@ -73,6 +82,12 @@ func newJobExecutor(info jobInfo, sf stepFactory, rc *RunContext) common.Executo
 			stepModel.ID = fmt.Sprintf("%d", i)
 		}
 
+		restarted = restarted || stepModel.ID == rc.Config.Resume.Step
+		if !restarted {
+			steps = append(steps, useStepLogger(rc, stepModel, stepStageMain, rc.newResumedStepExecutor(stepModel, resumedSteps[stepModel.ID])))
+			continue
+		}
+
 		step, err := sf.newStep(stepModel, rc)
 
 		if err != nil {
@@ -101,6 +116,10 @@ func newJobExecutor(info jobInfo, sf stepFactory, rc *RunContext) common.Executo
 		}
 	}
 
+	if !restarted {
+		return common.NewErrorExecutor(fmt.Errorf("the job '%s' has no step '%s' to restart at", rc.String(), rc.Config.Resume.Step))
+	}
+
 	var stopContainerExecutor common.Executor = func(ctx context.Context) error {
 		jobError := common.JobError(ctx)
 		var err error
@@ -119,9 +138,31 @@ func newJobExecutor(info jobInfo, sf stepFactory, rc *RunContext) common.Executo
 	}
 
 	var setJobResultExecutor common.Executor = func(ctx context.Context) error {
+		cancelled := false
+		if err := jobCancellationError(ctx); errors.Is(err, errJobCancelled) {
+			// the steps that failed or were skipped due to the cancellation don't fail the job
+			cancelled = true
+		} else if err != nil {
+			// steps skipped due to the cancellation don't report an error, its cause fails the job
+			_ = setJobError(ctx, err)
+		}
+		if url := rc.environmentURL(ctx); url != "" {
+			common.Logger(ctx).WithField("environmentUrl", url).Infof("\U0001F310  Environment %s: %s", rc.DeploymentEnvironment.Name, url)
+			rc.deploymentURL = url
+		}
 		jobError := common.JobError(ctx)
-		setJobResult(ctx, info, rc, jobError == nil)
+		result := "success"
+		switch {
+		case cancelled:
+			result = "cancelled"
+		case jobError != nil:
+			result = "failure"
+		}
+		setJobResult(ctx, info, rc, result)
 		setJobOutputs(ctx, rc)
+		rc.history.complete(result)
+		tracing.SpanFromContext(ctx).SetError(jobError)
+		rc.jobFinished(ctx, result)
 		return nil
 	}
 
@@ -129,12 +170,19 @@ func newJobExecutor(info jobInfo, sf stepFactory, rc *RunContext) common.Executo
 	pipeline = append(pipeline, preSteps...)
 	pipeline = append(pipeline, steps...)
 
+	jobStartedHookExecutor := newRunnerHookExecutor(rc, "A job started hook", "--job-started-hook", rc.Config.JobStartedHook).ThenError(setJobError)
+	var jobCompletedHookExecutor common.Executor = func(ctx context.Context) error {
+		// the steps ran, a failing hook fails the job without failing the run
+		_ = setJobError(ctx, newJobCompletedHookExecutor(rc, rc.Config.JobCompletedHook)(ctx))
+		return nil
+	}
+
 	return common.NewPipelineExecutor(
 		common.NewFieldExecutor("step", "Set up job", common.NewFieldExecutor("stepid", []string{"--setup-job"},
 			common.NewPipelineExecutor(common.NewInfoExecutor("\u2B50 Run Set up job"), info.startContainer(), rc.InitializeNodeTool()).
 				Then(common.NewFieldExecutor("stepResult", model.StepStatusSuccess, common.NewInfoExecutor("  \u2705  Success - Set up job"))).
 				ThenError(setJobError).OnError(common.NewFieldExecutor("stepResult", model.StepStatusFailure, common.NewInfoExecutor("  \u274C  Failure - Set up job"))))),
-		common.NewPipelineExecutor(pipeline...).
+		jobStartedHookExecutor.Then(common.NewPipelineExecutor(pipeline...).
 			Finally(func(ctx context.Context) error { //nolint:contextcheck
 				var cancel context.CancelFunc
 				if ctx.Err() == context.Canceled {
@@ -144,7 +192,8 @@ func newJobExecutor(info jobInfo, sf stepFactory, rc *RunContext) common.Executo
 					defer cancel()
 				}
 				return postExecutor(ctx)
-			}).
+			})).
+			Finally(jobCompletedHookExecutor).
 			Finally(common.NewFieldExecutor("step", "Complete job", common.NewFieldExecutor("stepid", []string{"--complete-job"},
 				common.NewInfoExecutor("\u2B50 Run Complete job").
 					Finally(stopContainerExecutor).
@@ -154,18 +203,17 @@ func newJobExecutor(info jobInfo, sf stepFactory, rc *RunContext) common.Executo
 					))))).Finally(setJobResultExecutor)
 }
 
-func setJobResult(ctx context.Context, info jobInfo, rc *RunContext, success bool) {
+func setJobResult(ctx context.Context, info jobInfo, rc *RunContext, result string) {
 	logger := common.Logger(ctx)
 
-	jobResult := "success"
+	jobResult := result
 	// we have only one result for a whole matrix build, so we need
-	// to keep an existing result state if we run a matrix
-	if len(info.matrix()) > 0 && rc.Run.Job().Result != "" {
-		jobResult = rc.Run.Job().Result
-	}
-
-	if !success {
-		jobResult = "failure"
+	// to keep an existing result state if we run a matrix, a failed
+	// leg fails it and a cancelled leg cancels it
+	if existing := rc.Run.Job().Result; len(info.matrix()) > 0 && existing != "" && jobResult != "failure" {
+		if existing == "failure" || jobResult == "success" {
+			jobResult = existing
+		}
 	}
 
 	info.result(jobResult)
@@ -175,8 +223,13 @@ func setJobResult(ctx context.Context, info jobInfo, rc *RunContext, success boo
 	}
 
 	jobResultMessage := "succeeded"
-	if jobResult != "success" {
+	switch jobResult {
+	case "failure":
 		jobResultMessage = "failed"
+	case "cancelled":
+		jobResultMessage = "cancelled"
+	case "skipped":
+		jobResultMessage = "skipped"
 	}
 
 	logger.WithField("jobResult", jobResult).Infof("\U0001F3C1  Job %s", jobResultMessage)
@@ -202,7 +255,7 @@ func useStepLogger(rc *RunContext, stepModel *model.Step, stage stepStage, execu
 		ctx = withStepLogger(ctx, stepModel.ID, rc.ExprEval.Interpolate(ctx, stepModel.String()), stage.String())
 
 		rawLogger := common.Logger(ctx).WithField("raw_output", true)
-		logWriter := common.NewLineWriter(rc.commandHandler(ctx), func(s string) bool {
+		logWriter := common.NewLineWriter(rc.commandHandler(ctx), rc.historyLineHandler(), func(s string) bool {
 			if rc.Config.LogOutput {
 				rawLogger.Infof("%s", s)
 			} else {
@@ -337,3 +337,69 @@ func TestNewJobExecutor(t *testing.T) {
 		})
 	}
 }
+
+func TestNewJobExecutorCancelled(t *testing.T) {
+	table := []struct {
+		name   string
+		cause  error
+		main   error
+		result string
+		err    string
+	}{
+		// the step is skipped because of the cancellation, so it doesn't report an error
+		{"timeout", fmt.Errorf("the job has exceeded the maximum execution time of 1 minutes"), nil, "failure", "the job has exceeded the maximum execution time of 1 minutes"},
+		// the step killed by the cancellation of fail-fast doesn't fail the job
+		{"fail-fast", fmt.Errorf("matrix job 1 of 2 failed, fail-fast %w", errJobCancelled), fmt.Errorf("exit with `FAILURE`: 143"), "cancelled", "exit with `FAILURE`: 143"},
+		// a later job of the concurrency group cancels the job in progress, like on GitHub
+		{"cancel-in-progress", cancelInProgressError("deploy"), fmt.Errorf("exit with `FAILURE`: 143"), "cancelled", "exit with `FAILURE`: 143"},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.name, func(t *testing.T) {
+			cancelCtx, cancel := context.WithCancelCause(context.Background())
+			cancel(tt.cause)
+			ctx := common.WithJobCancelContext(common.WithJobErrorContainer(context.Background()), cancelCtx)
+
+			jim := &jobInfoMock{}
+			sfm := &stepFactoryMock{}
+			rc := &RunContext{
+				JobContainer: &jobContainerMock{},
+				Run: &model.Run{
+					JobID: "test",
+					Workflow: &model.Workflow{
+						Jobs: map[string]*model.Job{
+							"test": {},
+						},
+					},
+				},
+				Config:           &Config{},
+				nodeToolFullPath: "node",
+			}
+			rc.ExprEval = rc.NewExpressionEvaluator(ctx)
+
+			stepModel := &model.Step{ID: "1"}
+			sm := &stepMock{}
+			sfm.On("newStep", stepModel, rc).Return(sm, nil)
+			sm.On("pre").Return(func(_ context.Context) error { return nil })
+			sm.On("main").Return(func(_ context.Context) error { return tt.main })
+			sm.On("post").Return(func(_ context.Context) error { return nil })
+
+			jim.On("steps").Return([]*model.Step{stepModel})
+			jim.On("matrix").Return(map[string]interface{}{})
+			jim.On("startContainer").Return(func(_ context.Context) error { return nil })
+			// the container of a job with an error is kept
+			jim.On("stopContainer").Return(func(_ context.Context) error { return nil }).Maybe()
+			jim.On("interpolateOutputs").Return(func(_ context.Context) error { return nil })
+			jim.On("closeContainer").Return(func(_ context.Context) error { return nil })
+			jim.On("result", tt.result)
+
+			err := newJobExecutor(jim, sfm, rc)(ctx)
+			assert.Nil(t, err)
+			assert.EqualError(t, common.JobError(ctx), tt.err)
+
+			jim.AssertExpectations(t)
+			sfm.AssertExpectations(t)
+			sm.AssertExpectations(t)
+		})
+	}
+}
@@ -183,22 +183,32 @@ func (f *maskedFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 type jobLogFormatter struct {
 	color          int
 	logPrefixJobID bool
+	groupDepth     int // the lines of ::group:: sections are indented
 }
 
 func (f *jobLogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	b := &bytes.Buffer{}
 
+	groupEvent := entry.Data["groupEvent"]
+	if groupEvent == "end" && f.groupDepth > 0 {
+		f.groupDepth--
+	}
+	indent := strings.Repeat("  ", f.groupDepth)
+	if groupEvent == "start" {
+		f.groupDepth++
+	}
+
 	if f.isColored(entry) {
-		f.printColored(b, entry)
+		f.printColored(b, entry, indent)
 	} else {
-		f.print(b, entry)
+		f.print(b, entry, indent)
 	}
 
 	b.WriteByte('\n')
 	return b.Bytes(), nil
 }
 
-func (f *jobLogFormatter) printColored(b *bytes.Buffer, entry *logrus.Entry) {
+func (f *jobLogFormatter) printColored(b *bytes.Buffer, entry *logrus.Entry, indent string) {
 	entry.Message = strings.TrimSuffix(entry.Message, "\n")
 
 	var job any
@@ -214,15 +224,15 @@ func (f *jobLogFormatter) printColored(b *bytes.Buffer, entry *logrus.Entry) {
 	}
 
 	if entry.Data["raw_output"] == true {
-		fmt.Fprintf(b, "\x1b[%dm|\x1b[0m %s", f.color, entry.Message)
+		fmt.Fprintf(b, "\x1b[%dm|\x1b[0m %s%s", f.color, indent, entry.Message)
 	} else if entry.Data["dryrun"] == true {
-		fmt.Fprintf(b, "\x1b[1m\x1b[%dm\x1b[7m*DRYRUN*\x1b[0m \x1b[%dm[%s] \x1b[0m%s%s", gray, f.color, job, debugFlag, entry.Message)
+		fmt.Fprintf(b, "\x1b[1m\x1b[%dm\x1b[7m*DRYRUN*\x1b[0m \x1b[%dm[%s] \x1b[0m%s%s%s", gray, f.color, job, indent, debugFlag, entry.Message)
 	} else {
-		fmt.Fprintf(b, "\x1b[%dm[%s] \x1b[0m%s%s", f.color, job, debugFlag, entry.Message)
+		fmt.Fprintf(b, "\x1b[%dm[%s] \x1b[0m%s%s%s", f.color, job, indent, debugFlag, entry.Message)
 	}
 }
 
-func (f *jobLogFormatter) print(b *bytes.Buffer, entry *logrus.Entry) {
+func (f *jobLogFormatter) print(b *bytes.Buffer, entry *logrus.Entry, indent string) {
 	entry.Message = strings.TrimSuffix(entry.Message, "\n")
 
 	var job any
@@ -238,11 +248,11 @@ func (f *jobLogFormatter) print(b *bytes.Buffer, entry *logrus.Entry) {
 	}
 
 	if entry.Data["raw_output"] == true {
-		fmt.Fprintf(b, "[%s]   | %s", job, entry.Message)
+		fmt.Fprintf(b, "[%s]   | %s%s", job, indent, entry.Message)
 	} else if entry.Data["dryrun"] == true {
-		fmt.Fprintf(b, "*DRYRUN* [%s] %s%s", job, debugFlag, entry.Message)
+		fmt.Fprintf(b, "*DRYRUN* [%s] %s%s%s", job, indent, debugFlag, entry.Message)
 	} else {
-		fmt.Fprintf(b, "[%s] %s%s", job, debugFlag, entry.Message)
+		fmt.Fprintf(b, "[%s] %s%s%s", job, indent, debugFlag, entry.Message)
 	}
 }
 
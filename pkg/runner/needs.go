@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"maps"
+	"slices"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+// NeedsStub is the result and the outputs of a needed job that doesn't run, in the shape of the `needs` context
+type NeedsStub struct {
+	Result  string            `json:"result"`
+	Outputs map[string]string `json:"outputs"`
+}
+
+// stubNeeds gives the needed jobs that aren't in the plan their stubbed result, success by default, and
+// outputs, so the `needs` context of the planned jobs is set without running them
+func stubNeeds(plan *model.Plan, stubs map[string]NeedsStub) {
+	stubbed := map[string]bool{}
+	for _, stage := range plan.Stages {
+		for _, run := range stage.Runs {
+			for _, need := range run.Job().Needs() {
+				job := run.Workflow.GetJob(need)
+				if job == nil || planned(plan, run.Workflow, need) {
+					continue
+				}
+				stub := stubs[need]
+				job.Result = stub.Result
+				if job.Result == "" {
+					job.Result = "success"
+				}
+				job.Outputs = map[string]string{}
+				for k, v := range stub.Outputs {
+					job.Outputs[k] = v
+				}
+				if !stubbed[need] {
+					log.Infof("\U0001F9E9  Job '%s' doesn't run, the jobs that need it get the result %s and %d outputs", need, job.Result, len(job.Outputs))
+				}
+				stubbed[need] = true
+			}
+		}
+	}
+	for _, need := range slices.Sorted(maps.Keys(stubs)) {
+		if !stubbed[need] {
+			log.Warnf("The job '%s' isn't needed by a job that runs without it, its stubbed result and outputs are ignored", need)
+		}
+	}
+}
+
+func planned(plan *model.Plan, w *model.Workflow, jobID string) bool {
+	for _, stage := range plan.Stages {
+		for _, run := range stage.Runs {
+			if run.Workflow == w && run.JobID == jobID {
+				return true
+			}
+		}
+	}
+	return false
+}
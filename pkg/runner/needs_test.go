@@ -0,0 +1,50 @@
+package runner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/exprparser"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+func TestStubNeeds(t *testing.T) {
+	var workflow model.Workflow
+	require.NoError(t, yaml.Unmarshal([]byte(`
+name: CI
+jobs:
+  build:
+    outputs:
+      version: ${{ steps.version.outputs.version }}
+    steps: [{run: make}]
+  lint:
+    steps: [{run: make lint}]
+  deploy:
+    needs: [build, lint]
+    steps: [{run: make deploy}]
+`), &workflow))
+	plan := &model.Plan{Stages: []*model.Stage{{Runs: []*model.Run{{Workflow: &workflow, JobID: "deploy"}}}}}
+
+	stubNeeds(plan, map[string]NeedsStub{
+		"build": {Outputs: map[string]string{"version": "1.2.3"}},
+		"test":  {Result: "failure"},
+	})
+
+	rc := &RunContext{Config: &Config{}, Run: plan.Stages[0].Runs[0]}
+	ctx := context.Background()
+	for expr, expected := range map[string]interface{}{
+		"needs.build.outputs.version": "1.2.3",
+		"needs.build.result":          "success",
+		// the needed jobs without stub succeed without outputs
+		"needs.lint.result":  "success",
+		"toJSON(needs.lint)": "{\n  \"outputs\": {},\n  \"result\": \"success\"\n}",
+	} {
+		v, err := rc.NewExpressionEvaluator(ctx).evaluate(ctx, expr, exprparser.DefaultStatusCheckNone)
+		require.NoError(t, err, expr)
+		assert.Equal(t, expected, v, expr)
+	}
+}
@@ -0,0 +1,130 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+	"github.com/Leapfrog-DevOps/gha/pkg/oidc"
+	"github.com/adrg/xdg"
+)
+
+// oidcStatusFile is the status of the OIDC server written by 'gha oidc start'
+var oidcStatusFile = func() string {
+	return filepath.Join(xdg.StateHome, "gha", "oidc-status.json")
+}
+
+// readOIDCStatus returns the status of the running OIDC server, or nil if there is none
+func readOIDCStatus() *oidc.Status {
+	status, err := oidc.LoadStatus(oidcStatusFile())
+	if err != nil || !status.Running || status.IssuerURL == "" || status.Password == "" {
+		return nil
+	}
+	return status
+}
+
+func setOIDCVars(ctx context.Context, rc *RunContext, github *model.GithubContext, env map[string]string) {
+	status := readOIDCStatus()
+	if status == nil {
+		return
+	}
+
+	// the request token carries the claims of this job, the server mints its tokens from them
+	requestToken, err := oidc.NewRequestToken(status.Password, rc.oidcClaims(github), time.Now())
+	if err != nil {
+		common.Logger(ctx).Warnf("Unable to create the OIDC request token: %v", err)
+		return
+	}
+	requestURL := status.IssuerURL + "/token"
+	if status.Provider == "local" && rc.IsHostEnv(ctx) {
+		requestURL = strings.Replace(requestURL, oidc.LocalIssuerHost, "localhost", 1)
+	}
+	env["ACTIONS_ID_TOKEN_REQUEST_URL"] = requestURL
+	env["ACTIONS_ID_TOKEN_REQUEST_TOKEN"] = requestToken
+	// the request token mints ID tokens, it's masked in the logs like on GitHub
+	rc.AddMask(requestToken)
+}
+
+// withOIDCContainerOptions lets the job and service containers reach a local-only OIDC server
+func (rc *RunContext) withOIDCContainerOptions(options string) string {
+	if status := readOIDCStatus(); status == nil || status.Provider != "local" {
+		return options
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s --add-host=%s:host-gateway", options, oidc.LocalIssuerHost))
+}
+
+// oidcClaims returns the claims of the OIDC tokens requested by the job
+func (rc *RunContext) oidcClaims(github *model.GithubContext) oidc.Claims {
+	claims := oidc.Claims{
+		Ref:               github.Ref,
+		RefType:           github.RefType,
+		Sha:               github.Sha,
+		Repository:        github.Repository,
+		RepositoryOwner:   github.RepositoryOwner,
+		Actor:             github.Actor,
+		Workflow:          github.Workflow,
+		RunID:             github.RunID,
+		RunNumber:         github.RunNumber,
+		RunAttempt:        github.RunAttempt,
+		EventName:         github.EventName,
+		HeadRef:           github.HeadRef,
+		BaseRef:           github.BaseRef,
+		RunnerEnvironment: "self-hosted",
+	}
+	if rc.DeploymentEnvironment != nil {
+		claims.Environment = rc.DeploymentEnvironment.Name
+	}
+
+	// the workflow refs point at the workflow file that was triggered, and the reusable
+	// workflow running the job if there is one
+	root := rc
+	for root.caller != nil {
+		root = root.caller.runContext
+	}
+	claims.WorkflowRef = fmt.Sprintf("%s/.github/workflows/%s@%s", github.Repository, filepath.Base(root.Run.Workflow.File), github.Ref)
+	claims.WorkflowSha = github.Sha
+	claims.JobWorkflowRef = claims.WorkflowRef
+	claims.JobWorkflowSha = github.Sha
+	if rc.caller != nil {
+		uses := rc.caller.runContext.Run.Job().Uses
+		if strings.HasPrefix(uses, "./") {
+			claims.JobWorkflowRef = fmt.Sprintf("%s/%s@%s", github.Repository, strings.TrimPrefix(uses, "./"), github.Ref)
+		} else {
+			claims.JobWorkflowRef = uses
+			claims.JobWorkflowSha = ""
+		}
+	}
+	return claims
+}
+
+// OIDCClaims returns the claims of the OIDC tokens the job of the run would request, for the first
+// combination of its matrix. The protection rules of its deployment environment aren't evaluated.
+func OIDCClaims(ctx context.Context, config *Config, run *model.Run) (oidc.Claims, error) {
+	job := run.Job()
+	if job == nil {
+		return oidc.Claims{}, fmt.Errorf("unable to find job '%s'", run.JobID)
+	}
+	if jobType, err := job.Type(); err != nil {
+		return oidc.Claims{}, err
+	} else if jobType != model.JobTypeDefault {
+		return oidc.Claims{}, fmt.Errorf("job '%s' calls a reusable workflow, select one of the jobs of the called workflow instead", run.JobID)
+	}
+	r, err := New(config)
+	if err != nil {
+		return oidc.Claims{}, err
+	}
+
+	var matrix map[string]interface{}
+	if matrixes, err := job.GetMatrixes(); err == nil && len(matrixes) > 0 {
+		matrix = matrixes[0]
+	}
+	rc := r.(*runnerImpl).newRunContext(ctx, run, matrix)
+	if err := rc.withDeploymentEnvironment(common.WithDryrun(ctx, true)); err != nil {
+		return oidc.Claims{}, err
+	}
+	return rc.oidcClaims(rc.getGithubContext(ctx)), nil
+}
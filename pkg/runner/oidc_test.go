@@ -0,0 +1,92 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+	"github.com/Leapfrog-DevOps/gha/pkg/oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunContextOIDCClaims(t *testing.T) {
+	github := &model.GithubContext{
+		Repository: "octo-org/octo-repo",
+		Ref:        "refs/heads/main",
+		Sha:        "abc123",
+		EventName:  "push",
+	}
+	callerRC := &RunContext{
+		Run: &model.Run{
+			JobID: "call",
+			Workflow: &model.Workflow{
+				File: "ci.yml",
+				Jobs: map[string]*model.Job{
+					"call": {Uses: "octo-org/shared/.github/workflows/deploy.yml@v1"},
+				},
+			},
+		},
+	}
+	rc := &RunContext{
+		Run:                   &model.Run{JobID: "deploy", Workflow: &model.Workflow{File: "deploy.yml"}},
+		DeploymentEnvironment: &model.DeploymentEnvironment{Name: "production"},
+	}
+
+	claims := rc.oidcClaims(github)
+	assert.Equal(t, "octo-org/octo-repo/.github/workflows/deploy.yml@refs/heads/main", claims.WorkflowRef)
+	assert.Equal(t, claims.WorkflowRef, claims.JobWorkflowRef)
+	assert.Equal(t, "abc123", claims.JobWorkflowSha)
+	assert.Equal(t, "repo:octo-org/octo-repo:environment:production", claims.DefaultSubject())
+
+	rc.caller = &caller{runContext: callerRC}
+	claims = rc.oidcClaims(github)
+	assert.Equal(t, "octo-org/octo-repo/.github/workflows/ci.yml@refs/heads/main", claims.WorkflowRef)
+	assert.Equal(t, "octo-org/shared/.github/workflows/deploy.yml@v1", claims.JobWorkflowRef)
+	assert.Empty(t, claims.JobWorkflowSha)
+
+	callerRC.Run.Workflow.Jobs["call"].Uses = "./.github/workflows/deploy.yml"
+	claims = rc.oidcClaims(github)
+	assert.Equal(t, "octo-org/octo-repo/.github/workflows/deploy.yml@refs/heads/main", claims.JobWorkflowRef)
+	assert.Equal(t, "abc123", claims.JobWorkflowSha)
+}
+
+func TestSetOIDCVars(t *testing.T) {
+	statusFile := filepath.Join(t.TempDir(), "oidc-status.json")
+	defer func(f func() string) { oidcStatusFile = f }(oidcStatusFile)
+	oidcStatusFile = func() string { return statusFile }
+
+	rc := &RunContext{
+		Config: &Config{},
+		Run:    &model.Run{JobID: "build", Workflow: &model.Workflow{File: "ci.yml", Jobs: map[string]*model.Job{"build": {}}}},
+	}
+	github := &model.GithubContext{Repository: "octo-org/octo-repo", Ref: "refs/heads/main", EventName: "push"}
+	ctx := context.Background()
+
+	env := map[string]string{}
+	setOIDCVars(ctx, rc, github, env)
+	assert.NotContains(t, env, "ACTIONS_ID_TOKEN_REQUEST_URL")
+	assert.Equal(t, "", rc.withOIDCContainerOptions(""))
+
+	require.NoError(t, os.WriteFile(statusFile, []byte(`{"running": true, "provider": "local", "issuer_url": "http://host.docker.internal:8080", "password": "password"}`), 0o600))
+	setOIDCVars(ctx, rc, github, env)
+	assert.Equal(t, "http://host.docker.internal:8080/token", env["ACTIONS_ID_TOKEN_REQUEST_URL"])
+	claims, err := oidc.ParseRequestToken("password", env["ACTIONS_ID_TOKEN_REQUEST_TOKEN"])
+	require.NoError(t, err)
+	assert.Equal(t, "repo:octo-org/octo-repo:ref:refs/heads/main", claims.DefaultSubject())
+	assert.Contains(t, rc.Masks, env["ACTIONS_ID_TOKEN_REQUEST_TOKEN"])
+	assert.Equal(t, "--privileged --add-host=host.docker.internal:host-gateway", rc.withOIDCContainerOptions("--privileged"))
+
+	require.NoError(t, os.WriteFile(statusFile, []byte(`{"running": true, "provider": "ngrok", "issuer_url": "https://oidc.ngrok.app", "password": "password"}`), 0o600))
+	setOIDCVars(ctx, rc, github, env)
+	assert.Equal(t, "https://oidc.ngrok.app/token", env["ACTIONS_ID_TOKEN_REQUEST_URL"])
+	assert.Equal(t, "--privileged", rc.withOIDCContainerOptions("--privileged"))
+
+	// the status of a server started by an older gha
+	env = map[string]string{}
+	require.NoError(t, os.WriteFile(statusFile, []byte(`{"running": true, "ngrok_url": "https://legacy.ngrok.app", "password": "password"}`), 0o600))
+	setOIDCVars(ctx, rc, github, env)
+	assert.Equal(t, "https://legacy.ngrok.app/token", env["ACTIONS_ID_TOKEN_REQUEST_URL"])
+}
@@ -0,0 +1,249 @@
+package runner
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/events"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+// problemMatchersFile is a file registered with ::add-matcher::, e.g. by actions/setup-node
+type problemMatchersFile struct {
+	ProblemMatcher []problemMatcherConfig `json:"problemMatcher"`
+}
+
+type problemMatcherConfig struct {
+	Owner    string                 `json:"owner"`
+	Severity string                 `json:"severity"`
+	Pattern  []problemPatternConfig `json:"pattern"`
+}
+
+// problemPatternConfig holds the regexp of a line and the groups of the properties it matches
+type problemPatternConfig struct {
+	Regexp    string `json:"regexp"`
+	File      int    `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	Severity  int    `json:"severity"`
+	Code      int    `json:"code"`
+	Message   int    `json:"message"`
+	Loop      bool   `json:"loop"`
+}
+
+type problemPattern struct {
+	problemPatternConfig
+	re *regexp.Regexp
+}
+
+// problemMatcher turns the log lines matching its patterns into annotations. The patterns match
+// consecutive lines, the last one can loop to match a problem per line.
+type problemMatcher struct {
+	owner    string
+	severity string
+	patterns []*problemPattern
+
+	index  int               // the pattern the next line has to match
+	values map[string]string // the properties matched by the previous patterns
+}
+
+func newProblemMatcher(config problemMatcherConfig) (*problemMatcher, error) {
+	if config.Owner == "" {
+		return nil, fmt.Errorf("the problem matcher has no owner")
+	}
+	if len(config.Pattern) == 0 {
+		return nil, fmt.Errorf("the problem matcher '%s' has no patterns", config.Owner)
+	}
+	m := &problemMatcher{owner: config.Owner, severity: strings.ToLower(config.Severity)}
+	hasMessage := false
+	for i, p := range config.Pattern {
+		if p.Loop && (i != len(config.Pattern)-1 || i == 0) {
+			return nil, fmt.Errorf("only the last pattern of the problem matcher '%s' can loop, and not if it is the only one", config.Owner)
+		}
+		if p.Loop && p.Message == 0 {
+			return nil, fmt.Errorf("the loop pattern of the problem matcher '%s' has no message", config.Owner)
+		}
+		re, err := regexp.Compile(p.Regexp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp of the problem matcher '%s': %w", config.Owner, err)
+		}
+		hasMessage = hasMessage || p.Message > 0
+		m.patterns = append(m.patterns, &problemPattern{problemPatternConfig: p, re: re})
+	}
+	if !hasMessage {
+		return nil, fmt.Errorf("the problem matcher '%s' has no message", config.Owner)
+	}
+	return m, nil
+}
+
+// match returns the annotation of the problem the line completes, if there is one
+func (m *problemMatcher) match(line string) *model.Annotation {
+	if m.index > 0 {
+		if annotation, ok := m.matchPattern(line); ok {
+			return annotation
+		}
+		// the line ends the problem, it might start the next one
+		m.reset()
+	}
+	annotation, _ := m.matchPattern(line)
+	return annotation
+}
+
+func (m *problemMatcher) matchPattern(line string) (*model.Annotation, bool) {
+	pattern := m.patterns[m.index]
+	groups := pattern.re.FindStringSubmatch(line)
+	if groups == nil {
+		return nil, false
+	}
+
+	values := map[string]string{}
+	for k, v := range m.values {
+		values[k] = v
+	}
+	for name, group := range map[string]int{
+		"file":      pattern.File,
+		"line":      pattern.Line,
+		"column":    pattern.Column,
+		"endLine":   pattern.EndLine,
+		"endColumn": pattern.EndColumn,
+		"severity":  pattern.Severity,
+		"code":      pattern.Code,
+		"message":   pattern.Message,
+	} {
+		if group > 0 && group < len(groups) && groups[group] != "" {
+			values[name] = groups[group]
+		}
+	}
+
+	if m.index < len(m.patterns)-1 {
+		m.values = values
+		m.index++
+		return nil, true
+	}
+	if !pattern.Loop {
+		m.reset()
+	}
+	return m.annotation(values), true
+}
+
+func (m *problemMatcher) reset() {
+	m.index = 0
+	m.values = nil
+}
+
+func (m *problemMatcher) annotation(values map[string]string) *model.Annotation {
+	if values["message"] == "" {
+		return nil
+	}
+	severity := strings.ToLower(values["severity"])
+	if severity == "" {
+		severity = m.severity
+	}
+	switch {
+	case strings.HasPrefix(severity, "warn"):
+		severity = "warning"
+	case severity == "notice" || severity == "info":
+		severity = "notice"
+	default:
+		severity = "error"
+	}
+	return &model.Annotation{
+		Level:     severity,
+		Message:   values["message"],
+		Title:     values["code"],
+		File:      values["file"],
+		Line:      parseAnnotationPosition(values["line"]),
+		EndLine:   parseAnnotationPosition(values["endLine"]),
+		Col:       parseAnnotationPosition(values["column"]),
+		EndColumn: parseAnnotationPosition(values["endColumn"]),
+	}
+}
+
+// jobRunContext returns the run context of the job, the problem matchers of composite actions
+// apply to the rest of the job
+func (rc *RunContext) jobRunContext() *RunContext {
+	for rc.Parent != nil {
+		rc = rc.Parent
+	}
+	return rc
+}
+
+// addProblemMatchers registers the problem matchers of the file in the job container, replacing
+// the matchers with the same owner
+func (rc *RunContext) addProblemMatchers(ctx context.Context, file string) error {
+	if !path.IsAbs(file) && rc.JobContainer != nil {
+		file = path.Join(rc.JobContainer.ToContainerPath(rc.Config.Workdir), file)
+	}
+	archive, err := rc.JobContainer.GetContainerArchive(ctx, file)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	reader := tar.NewReader(archive)
+	if _, err := reader.Next(); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	var matchers problemMatchersFile
+	if err := json.Unmarshal(data, &matchers); err != nil {
+		return fmt.Errorf("invalid problem matchers in '%s': %w", file, err)
+	}
+	jobRC := rc.jobRunContext()
+	for _, config := range matchers.ProblemMatcher {
+		matcher, err := newProblemMatcher(config)
+		if err != nil {
+			return err
+		}
+		jobRC.removeProblemMatcher(matcher.owner)
+		jobRC.problemMatchers = append(jobRC.problemMatchers, matcher)
+		common.Logger(ctx).Debugf("Added problem matcher '%s'", matcher.owner)
+	}
+	return nil
+}
+
+func (rc *RunContext) removeProblemMatcher(owner string) {
+	jobRC := rc.jobRunContext()
+	matchers := jobRC.problemMatchers[:0]
+	for _, matcher := range jobRC.problemMatchers {
+		if matcher.owner != owner {
+			matchers = append(matchers, matcher)
+		}
+	}
+	jobRC.problemMatchers = matchers
+}
+
+// matchProblems records the problems the output line completes as annotations of the current step
+func (rc *RunContext) matchProblems(ctx context.Context, line string) {
+	line = strings.TrimRight(line, "\r\n")
+	for _, matcher := range rc.jobRunContext().problemMatchers {
+		annotation := matcher.match(line)
+		if annotation == nil {
+			continue
+		}
+		if rc.JobContainer != nil {
+			// the problems are reported relative to the workspace
+			workspace := rc.JobContainer.ToContainerPath(rc.Config.Workdir)
+			annotation.File = strings.TrimPrefix(annotation.File, strings.TrimSuffix(workspace, "/")+"/")
+		}
+		if result := rc.currentStageResult(); result != nil {
+			result.Annotations = append(result.Annotations, *annotation)
+		}
+		rc.emitStepEvent(ctx, events.Event{Type: events.AnnotationAdded, Annotation: annotation})
+		common.Logger(ctx).WithFields(logrus.Fields{"matcher": matcher.owner, "annotation": *annotation}).Debugf("  \U0001F50E  %s: %s", annotation.Level, annotation)
+	}
+}
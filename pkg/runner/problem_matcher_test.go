@@ -0,0 +1,127 @@
+package runner
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+const tscMatchers = `{
+  "problemMatcher": [{
+    "owner": "tsc",
+    "pattern": [{
+      "regexp": "^([^\\s].*)[\\(:](\\d+)[,:](\\d+)(?:\\):\\s+|\\s+-\\s+)(error|warning|info)\\s+(TS\\d+)\\s*:\\s*(.*)$",
+      "file": 1, "line": 2, "column": 3, "severity": 4, "code": 5, "message": 6
+    }]
+  }]
+}`
+
+const eslintMatchers = `{
+  "problemMatcher": [{
+    "owner": "eslint-stylish",
+    "pattern": [
+      {"regexp": "^([^\\s].*)$", "file": 1},
+      {"regexp": "^\\s+(\\d+):(\\d+)\\s+(error|warning|info)\\s+(.*)\\s\\s+(.*)$", "line": 1, "column": 2, "severity": 3, "message": 4, "code": 5, "loop": true}
+    ]
+  }]
+}`
+
+func matchersArchive(t *testing.T, content string) io.ReadCloser {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "matchers.json", Mode: 0o644, Size: int64(len(content))}))
+	_, err := tw.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	return io.NopCloser(buf)
+}
+
+func newProblemMatcherRunContext(t *testing.T, matchers ...string) *RunContext {
+	cm := &containerMock{}
+	for i, content := range matchers {
+		cm.On("GetContainerArchive", context.Background(), "/tmp/matchers"+string(rune('0'+i))+".json").Return(matchersArchive(t, content), nil)
+	}
+	rc := &RunContext{
+		Config:       &Config{Workdir: "/home/octocat/repo"},
+		JobContainer: cm,
+		CurrentStep:  "build",
+		StepResults:  map[string]*model.StepResult{"build": {Outputs: map[string]string{}}},
+	}
+	handler := rc.commandHandler(context.Background())
+	for i := range matchers {
+		handler("::add-matcher::/tmp/matchers" + string(rune('0'+i)) + ".json\n")
+	}
+	return rc
+}
+
+func TestProblemMatcher(t *testing.T) {
+	rc := newProblemMatcherRunContext(t, tscMatchers)
+	handler := rc.commandHandler(context.Background())
+
+	handler("src/index.ts(3,7): error TS2322: Type 'string' is not assignable to type 'number'.\n")
+	handler("Found 1 error.\n")
+	handler("/home/octocat/repo/src/app.ts:10:1 - warning TS6133: 'x' is declared but never read.\n")
+
+	assert.Equal(t, []model.Annotation{
+		{Level: "error", Message: "Type 'string' is not assignable to type 'number'.", Title: "TS2322", File: "src/index.ts", Line: 3, Col: 7},
+		{Level: "warning", Message: "'x' is declared but never read.", Title: "TS6133", File: "src/app.ts", Line: 10, Col: 1},
+	}, rc.StepResults["build"].Annotations)
+}
+
+func TestProblemMatcherLoop(t *testing.T) {
+	rc := newProblemMatcherRunContext(t, eslintMatchers)
+	handler := rc.commandHandler(context.Background())
+
+	for _, line := range []string{
+		"/home/octocat/repo/src/a.js\n",
+		"  1:10  error    'foo' is defined but never used  no-unused-vars\n",
+		"  2:1   warning  Unexpected console statement  no-console\n",
+		"src/b.js\n",
+		"  7:3  error  Missing semicolon  semi\n",
+		"\n",
+		"  9:9  error  Not a problem of a file  semi\n",
+	} {
+		handler(line)
+	}
+
+	assert.Equal(t, []model.Annotation{
+		{Level: "error", Message: "'foo' is defined but never used", Title: "no-unused-vars", File: "src/a.js", Line: 1, Col: 10},
+		{Level: "warning", Message: "Unexpected console statement", Title: "no-console", File: "src/a.js", Line: 2, Col: 1},
+		{Level: "error", Message: "Missing semicolon", Title: "semi", File: "src/b.js", Line: 7, Col: 3},
+	}, rc.StepResults["build"].Annotations)
+}
+
+func TestRemoveProblemMatcher(t *testing.T) {
+	rc := newProblemMatcherRunContext(t, tscMatchers, eslintMatchers)
+	require.Len(t, rc.problemMatchers, 2)
+
+	// the matchers of composite actions are registered for the job
+	composite := &RunContext{Parent: rc, Config: rc.Config}
+	composite.commandHandler(context.Background())("::remove-matcher owner=tsc::\n")
+	require.Len(t, rc.problemMatchers, 1)
+	assert.Equal(t, "eslint-stylish", rc.problemMatchers[0].owner)
+}
+
+func TestNewProblemMatcherErrors(t *testing.T) {
+	table := []struct {
+		config problemMatcherConfig
+		err    string
+	}{
+		{problemMatcherConfig{Owner: "a"}, "the problem matcher 'a' has no patterns"},
+		{problemMatcherConfig{Owner: "a", Pattern: []problemPatternConfig{{Regexp: "(", Message: 1}}}, "invalid regexp of the problem matcher 'a': error parsing regexp: missing closing ): `(`"},
+		{problemMatcherConfig{Owner: "a", Pattern: []problemPatternConfig{{Regexp: "(.*)", Message: 1, Loop: true}}}, "only the last pattern of the problem matcher 'a' can loop, and not if it is the only one"},
+		{problemMatcherConfig{Owner: "a", Pattern: []problemPatternConfig{{Regexp: "(.*)", File: 1}}}, "the problem matcher 'a' has no message"},
+	}
+
+	for _, tt := range table {
+		_, err := newProblemMatcher(tt.config)
+		assert.EqualError(t, err, tt.err)
+	}
+}
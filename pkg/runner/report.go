@@ -0,0 +1,341 @@
+package runner
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/history"
+)
+
+const (
+	ReportFormatJUnit = "junit"
+	ReportFormatSARIF = "sarif"
+)
+
+// Report is a file the results of the run are exported to at its end
+type Report struct {
+	Format string // ReportFormatJUnit or ReportFormatSARIF
+	Path   string
+}
+
+func (r Report) write(run *history.Run) error {
+	var write func(io.Writer, *history.Run) error
+	switch r.Format {
+	case ReportFormatJUnit:
+		write = writeJUnitReport
+	case ReportFormatSARIF:
+		write = writeSARIFReport
+	default:
+		return fmt.Errorf("unknown report format '%s'", r.Format)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.Path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.Create(r.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return write(file, run)
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Name     string            `xml:"name,attr"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Errors   int               `xml:"errors,attr"`
+	Skipped  int               `xml:"skipped,attr"`
+	Time     string            `xml:"time,attr"`
+	Suites   []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Errors    int              `xml:"errors,attr"`
+	Skipped   int              `xml:"skipped,attr"`
+	Time      string           `xml:"time,attr"`
+	Timestamp string           `xml:"timestamp,attr,omitempty"`
+	Cases     []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string          `xml:"name,attr"`
+	ClassName string          `xml:"classname,attr"`
+	Time      string          `xml:"time,attr"`
+	Skipped   *junitMessage   `xml:"skipped"`
+	Failures  []*junitMessage `xml:"failure"`
+	Error     *junitMessage   `xml:"error"`
+	SystemOut *junitOutput    `xml:"system-out"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",cdata"`
+}
+
+type junitOutput struct {
+	Text string `xml:",cdata"`
+}
+
+// junitColorCodes are the ANSI escape sequences of colored output, which are not allowed in XML
+var junitColorCodes = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// junitText returns the output without colors and the other control characters XML does not allow
+func junitText(s string) string {
+	s = junitColorCodes.ReplaceAllString(s, "")
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// writeJUnitReport writes the run as JUnit XML, with a testsuite per workflow and a testcase per
+// job and leg of a matrix. The failed steps of a job are its failures, the output of its steps is
+// its system-out.
+func writeJUnitReport(w io.Writer, run *history.Run) error {
+	report := &junitTestSuites{Name: "gha", Time: junitTime(run.StartedAt, run.CompletedAt)}
+	suites := map[string]*junitTestSuite{}
+	for _, job := range run.Jobs {
+		suite, ok := suites[job.Workflow]
+		if !ok {
+			suite = &junitTestSuite{Name: job.Workflow}
+			suites[job.Workflow] = suite
+			report.Suites = append(report.Suites, suite)
+		}
+		if suite.Timestamp == "" && !job.StartedAt.IsZero() {
+			suite.Timestamp = job.StartedAt.UTC().Format("2006-01-02T15:04:05")
+		}
+
+		testCase := &junitTestCase{
+			Name:      reportJobName(job),
+			ClassName: job.Workflow,
+			Time:      junitTime(job.StartedAt, job.CompletedAt),
+		}
+		out := &strings.Builder{}
+		if job.Environment != "" {
+			fmt.Fprintf(out, "environment: %s\n", strings.TrimSpace(job.Environment+" "+job.EnvironmentURL))
+		}
+		for _, step := range job.Steps {
+			fmt.Fprintf(out, "%s: %s%s\n", step.Name, step.Conclusion, junitStepTime(step))
+			if step.Outcome != step.Conclusion {
+				fmt.Fprintf(out, "  outcome: %s, continue-on-error\n", step.Outcome)
+			}
+			if step.Substitute != "" {
+				fmt.Fprintf(out, "  mocked: %s\n", step.Substitute)
+			}
+			output := junitText(step.Output())
+			out.WriteString(output)
+			if step.Conclusion == "failure" {
+				testCase.Failures = append(testCase.Failures, &junitMessage{
+					Message: fmt.Sprintf("%s failed", step.Name),
+					Type:    "step",
+					Text:    output,
+				})
+			}
+		}
+		if out.Len() > 0 {
+			testCase.SystemOut = &junitOutput{Text: out.String()}
+		}
+
+		suite.Tests++
+		switch job.Conclusion {
+		case "success":
+		case "skipped":
+			testCase.Skipped = &junitMessage{Message: "the job was skipped"}
+			suite.Skipped++
+		case "failure":
+			if len(testCase.Failures) == 0 {
+				// e.g. the job container did not start
+				testCase.Failures = append(testCase.Failures, &junitMessage{Message: "the job failed", Type: "job"})
+			}
+			suite.Failures++
+		default:
+			testCase.Error = &junitMessage{Message: fmt.Sprintf("the job did not complete: %s", job.Conclusion)}
+			suite.Errors++
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	for _, suite := range report.Suites {
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		report.Skipped += suite.Skipped
+		suite.Time = junitSuiteTime(suite, run)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// reportJobName returns the ID of the job with the values of its matrix, e.g. "test (node: 20, os: ubuntu)"
+func reportJobName(job *history.Job) string {
+	if len(job.Matrix) == 0 {
+		return job.ID
+	}
+	return fmt.Sprintf("%s (%s)", job.ID, matrixString(job.Matrix))
+}
+
+func junitStepTime(step *history.Step) string {
+	if step.StartedAt.IsZero() || step.CompletedAt.IsZero() {
+		return ""
+	}
+	return " [" + step.CompletedAt.Sub(step.StartedAt).Round(time.Millisecond).String() + "]"
+}
+
+func junitTime(start, end time.Time) string {
+	if start.IsZero() || end.Before(start) {
+		return "0.000"
+	}
+	return fmt.Sprintf("%.3f", end.Sub(start).Seconds())
+}
+
+// junitSuiteTime returns the time the jobs of the workflow took, from the first start to the last completion
+func junitSuiteTime(suite *junitTestSuite, run *history.Run) string {
+	var first, last *history.Job
+	for _, job := range run.Jobs {
+		if job.Workflow != suite.Name || job.StartedAt.IsZero() {
+			continue
+		}
+		if first == nil || job.StartedAt.Before(first.StartedAt) {
+			first = job
+		}
+		if last == nil || job.CompletedAt.After(last.CompletedAt) {
+			last = job
+		}
+	}
+	if first == nil {
+		return "0.000"
+	}
+	return junitTime(first.StartedAt, last.CompletedAt)
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string `json:"name"`
+	InformationURI string `json:"informationUri"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId,omitempty"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations,omitempty"`
+	Properties map[string]string `json:"properties"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine,omitempty"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// writeSARIFReport writes the annotations of the steps, reported with workflow commands or
+// problem matchers, as SARIF 2.1.0
+func writeSARIFReport(w io.Writer, run *history.Run) error {
+	results := []sarifResult{}
+	for _, job := range run.Jobs {
+		for _, step := range job.Steps {
+			for _, annotation := range step.Annotations {
+				result := sarifResult{
+					RuleID:  annotation.Title,
+					Level:   sarifLevel(annotation.Level),
+					Message: sarifMessage{Text: annotation.Message},
+					Properties: map[string]string{
+						"workflow": job.Workflow,
+						"job":      reportJobName(job),
+						"step":     step.Name,
+					},
+				}
+				if annotation.File != "" {
+					location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(annotation.File)}}}
+					if annotation.Line > 0 {
+						location.PhysicalLocation.Region = &sarifRegion{
+							StartLine:   annotation.Line,
+							StartColumn: annotation.Col,
+							EndLine:     annotation.EndLine,
+							EndColumn:   annotation.EndColumn,
+						}
+					}
+					result.Locations = []sarifLocation{location}
+				}
+				results = append(results, result)
+			}
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: sarifDriver{Name: "gha", InformationURI: "https://github.com/Leapfrog-DevOps/gha"}},
+			Results: results,
+		}},
+	})
+}
+
+func sarifLevel(level string) string {
+	switch level {
+	case "error":
+		return "error"
+	case "warning":
+		return "warning"
+	default:
+		return "note"
+	}
+}
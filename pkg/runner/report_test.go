@@ -0,0 +1,117 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/history"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+func newReportRun() *history.Run {
+	started := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	test := &history.Step{Number: 2, ID: "test", Name: "Run go test ./...", Conclusion: "failure", Outcome: "failure", StartedAt: started.Add(time.Second), CompletedAt: started.Add(3 * time.Second)}
+	test.AppendLog(started.Add(2*time.Second), "\x1b[31m--- FAIL: TestParse <x>\x1b[0m\n")
+	lint := &history.Step{Number: 1, ID: "lint", Name: "Run golangci-lint run", Conclusion: "success", Outcome: "failure", StartedAt: started, CompletedAt: started.Add(time.Second),
+		Substitute: "golangci/golangci-lint-action@v6 substituted by the local action ./mocks/lint of the mock 'golangci/*'",
+		Annotations: []model.Annotation{
+			{Level: "error", Message: "unused variable", Title: "unused", File: "main.go", Line: 10, Col: 5},
+			{Level: "notice", Message: "lint finished"},
+		}}
+	return &history.Run{
+		StartedAt:   started,
+		CompletedAt: started.Add(5 * time.Second),
+		Jobs: []*history.Job{
+			{ID: "test", Workflow: "CI", Matrix: map[string]interface{}{"os": "ubuntu", "go": "1.22"}, Conclusion: "failure", StartedAt: started, CompletedAt: started.Add(4 * time.Second), Steps: []*history.Step{lint, test}},
+			{ID: "deploy", Workflow: "CI", Conclusion: "skipped", Steps: []*history.Step{}},
+			{ID: "docs", Workflow: "Docs", Conclusion: "success", Environment: "github-pages", EnvironmentURL: "https://octo.github.io", StartedAt: started, CompletedAt: started.Add(2 * time.Second), Steps: []*history.Step{}},
+		},
+	}
+}
+
+func TestWriteJUnitReport(t *testing.T) {
+	out := &bytes.Buffer{}
+	require.NoError(t, writeJUnitReport(out, newReportRun()))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="gha" tests="3" failures="1" errors="0" skipped="1" time="5.000">
+  <testsuite name="CI" tests="2" failures="1" errors="0" skipped="1" time="4.000" timestamp="2024-05-01T10:00:00">
+    <testcase name="test (go: 1.22, os: ubuntu)" classname="CI" time="4.000">
+      <failure message="Run go test ./... failed" type="step"><![CDATA[--- FAIL: TestParse <x>
+]]></failure>
+      <system-out><![CDATA[Run golangci-lint run: success [1s]
+  outcome: failure, continue-on-error
+  mocked: golangci/golangci-lint-action@v6 substituted by the local action ./mocks/lint of the mock 'golangci/*'
+Run go test ./...: failure [2s]
+--- FAIL: TestParse <x>
+]]></system-out>
+    </testcase>
+    <testcase name="deploy" classname="CI" time="0.000">
+      <skipped message="the job was skipped"></skipped>
+    </testcase>
+  </testsuite>
+  <testsuite name="Docs" tests="1" failures="0" errors="0" skipped="0" time="2.000" timestamp="2024-05-01T10:00:00">
+    <testcase name="docs" classname="Docs" time="2.000">
+      <system-out><![CDATA[environment: github-pages https://octo.github.io
+]]></system-out>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, out.String())
+}
+
+func TestWriteSARIFReport(t *testing.T) {
+	out := &bytes.Buffer{}
+	require.NoError(t, writeSARIFReport(out, newReportRun()))
+
+	var sarif map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &sarif))
+	assert.Equal(t, "2.1.0", sarif["version"])
+	results := sarif["runs"].([]interface{})[0].(map[string]interface{})["results"].([]interface{})
+	require.Len(t, results, 2)
+	assert.Equal(t, map[string]interface{}{
+		"ruleId":  "unused",
+		"level":   "error",
+		"message": map[string]interface{}{"text": "unused variable"},
+		"locations": []interface{}{map[string]interface{}{"physicalLocation": map[string]interface{}{
+			"artifactLocation": map[string]interface{}{"uri": "main.go"},
+			"region":           map[string]interface{}{"startLine": float64(10), "startColumn": float64(5)},
+		}}},
+		"properties": map[string]interface{}{"workflow": "CI", "job": "test (go: 1.22, os: ubuntu)", "step": "Run golangci-lint run"},
+	}, results[0])
+	assert.Equal(t, "note", results[1].(map[string]interface{})["level"])
+	assert.NotContains(t, results[1], "locations")
+}
+
+func TestReportsExecutor(t *testing.T) {
+	dir := t.TempDir()
+	config := &Config{Reports: []Report{
+		{Format: ReportFormatJUnit, Path: filepath.Join(dir, "reports", "results.xml")},
+		{Format: ReportFormatSARIF, Path: filepath.Join(dir, "annotations.sarif")},
+	}}
+	workflow := &model.Workflow{Name: "CI", Jobs: map[string]*model.Job{"build": {}}}
+	executor := newRunHistoryExecutor(config, &model.Plan{}, func(ctx context.Context) error {
+		rc := &RunContext{Config: config, Run: &model.Run{Workflow: workflow, JobID: "build"}, Name: "build", JobName: "build"}
+		rc.history = runHistoryFromContext(ctx).addJob(rc)
+		rc.history.start()
+		rc.history.complete("failure")
+		return assert.AnError
+	})
+
+	// the reports are written without the history, also if the run failed
+	assert.ErrorIs(t, executor(common.WithDryrun(context.Background(), false)), assert.AnError)
+	junit, err := os.ReadFile(filepath.Join(dir, "reports", "results.xml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(junit), `<failure message="the job failed" type="job"></failure>`)
+	sarif, err := os.ReadFile(filepath.Join(dir, "annotations.sarif"))
+	require.NoError(t, err)
+	assert.Contains(t, string(sarif), `"results": []`)
+}
@@ -0,0 +1,174 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/history"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+// Resume is a recorded run of the history that is resumed: the jobs that succeeded in the run don't run
+// again and the jobs that need them get their recorded outputs, the failed jobs and the jobs that need
+// them run again
+type Resume struct {
+	Run  *history.Run // the resumed run
+	Job  string       // the ID of the job restarted at Step in its container kept with --reuse, empty to run the jobs from their start
+	Step string       // the ID of the step the job restarts at, the steps before it don't run again
+
+	mu  sync.Mutex
+	ran map[string]bool // the jobs that run again, by workflow and job ID
+}
+
+func resumeKey(run *model.Run) string {
+	return run.Workflow.Name + "/" + run.JobID
+}
+
+// recordedJob returns the job in the resumed run, nil if the job didn't run
+func (r *Resume) recordedJob(rc *RunContext) *history.Job {
+	for _, job := range r.Run.Jobs {
+		if job.Workflow == rc.Run.Workflow.Name && job.Name == rc.String() {
+			return job
+		}
+	}
+	return nil
+}
+
+// succeededJob returns the job in the resumed run when it succeeded and doesn't need to run again, nil when
+// it runs: when it didn't succeed, it needs a job that runs again, or it restarts at a step
+func (r *Resume) succeededJob(rc *RunContext) *history.Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job := r.recordedJob(rc)
+	runs := job == nil || job.Conclusion != "success" || rc.Run.JobID == r.Job
+	for _, need := range rc.Run.Job().Needs() {
+		// the needed jobs ran in the previous stages
+		runs = runs || r.ran[resumeKey(&model.Run{Workflow: rc.Run.Workflow, JobID: need})]
+	}
+	if runs {
+		if r.ran == nil {
+			r.ran = map[string]bool{}
+		}
+		r.ran[resumeKey(rc.Run)] = true
+		return nil
+	}
+	return job
+}
+
+// resumeJob skips the job when it succeeded in the resumed run, its recorded outputs are the outputs of the job
+func (rc *RunContext) resumeJob(ctx context.Context) bool {
+	resume := rc.Config.Resume
+	if resume == nil {
+		return false
+	}
+	job := resume.succeededJob(rc)
+	if job == nil {
+		return false
+	}
+	if rc.Run.Job().Outputs == nil {
+		rc.Run.Job().Outputs = map[string]string{}
+	}
+	for k, v := range job.Outputs {
+		rc.Run.Job().Outputs[k] = v
+	}
+	// a matrix has a single result, a failed leg fails it
+	if rc.Run.Job().Result == "" {
+		rc.result("success")
+	}
+	common.Logger(ctx).WithField("jobResult", "success").Infof("✅  Job succeeded in run #%d, skipping it", resume.Run.Number)
+	rc.history.complete("success")
+	rc.jobFinished(ctx, "success")
+	return true
+}
+
+// resumedSteps returns the recorded steps of the job restarted at a step by step ID, nil when the job runs
+// from its start
+func (rc *RunContext) resumedSteps() (map[string]*history.Step, error) {
+	resume := rc.Config.Resume
+	if resume == nil || resume.Job == "" || resume.Job != rc.Run.JobID {
+		return nil, nil
+	}
+	job := resume.recordedJob(rc)
+	if job == nil {
+		return nil, fmt.Errorf("the job '%s' didn't run in run #%d, it can't restart at the step '%s'", rc.String(), resume.Run.Number, resume.Step)
+	}
+	if job.Container == "" || !rc.Config.ReuseContainers {
+		return nil, fmt.Errorf("the job '%s' restarts at the step '%s' in its container, run #%d and the resumed run must run with --reuse to keep it", rc.String(), resume.Step, resume.Run.Number)
+	}
+	steps := map[string]*history.Step{}
+	for _, step := range job.Steps {
+		if step.ID == resume.Step {
+			break
+		}
+		if step.Stage != "main" {
+			continue
+		}
+		if step.Masked {
+			// the masked values can't be restored
+			return nil, fmt.Errorf("the step '%s' of the job '%s' passed a secret to an output, GITHUB_ENV or GITHUB_STATE in run #%d, the job can't restart at the step '%s'", step.ID, rc.String(), resume.Run.Number, resume.Step)
+		}
+		steps[step.ID] = step
+	}
+	return steps, nil
+}
+
+// newResumedStepExecutor skips the step that ran before the step the job restarts at, its recorded result
+// is the result of the step
+func (rc *RunContext) newResumedStepExecutor(stepModel *model.Step, recorded *history.Step) common.Executor {
+	return func(ctx context.Context) error {
+		result := &model.StepResult{
+			Outcome:    model.StepStatusSkipped,
+			Conclusion: model.StepStatusSkipped,
+			Outputs:    map[string]string{},
+		}
+		if recorded != nil {
+			_ = result.Outcome.UnmarshalText([]byte(recorded.Outcome))
+			_ = result.Conclusion.UnmarshalText([]byte(recorded.Conclusion))
+			for k, v := range recorded.Outputs {
+				result.Outputs[k] = v
+			}
+			rc.restoreFileCommands(stepModel.ID, recorded)
+		}
+		rc.StepResults[stepModel.ID] = result
+		common.Logger(ctx).WithField("stepResult", result.Outcome).Infof("⏭  Skipping step '%s', it ran in run #%d", stepModel, rc.Config.Resume.Run.Number)
+		return nil
+	}
+}
+
+// restoreFileCommands restores the env, path and state the skipped step changed with the file commands in the
+// resumed run, like the step changed them
+func (rc *RunContext) restoreFileCommands(stepID string, recorded *history.Step) {
+	if len(recorded.Env) > 0 {
+		if rc.Env == nil {
+			rc.Env = map[string]string{}
+		}
+		if rc.GlobalEnv == nil {
+			rc.GlobalEnv = map[string]string{}
+		}
+		mergeIntoMap := mergeIntoMapCaseSensitive
+		if rc.JobContainer != nil && rc.JobContainer.IsEnvironmentCaseInsensitive() {
+			mergeIntoMap = mergeIntoMapCaseInsensitive
+		}
+		mergeIntoMap(rc.Env, recorded.Env)
+		mergeIntoMap(rc.GlobalEnv, recorded.Env)
+	}
+	// the paths are recorded the last one first, like they're added
+	for i := len(recorded.Path) - 1; i >= 0; i-- {
+		extraPath := []string{recorded.Path[i]}
+		for _, p := range rc.ExtraPath {
+			if p != recorded.Path[i] {
+				extraPath = append(extraPath, p)
+			}
+		}
+		rc.ExtraPath = extraPath
+	}
+	if len(recorded.State) > 0 {
+		if rc.IntraActionState == nil {
+			rc.IntraActionState = map[string]map[string]string{}
+		}
+		rc.IntraActionState[stepID] = maps.Clone(recorded.State)
+	}
+}
@@ -0,0 +1,151 @@
+package runner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/history"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+func TestResumeJobs(t *testing.T) {
+	var workflow model.Workflow
+	require.NoError(t, yaml.Unmarshal([]byte(`
+name: CI
+jobs:
+  build:
+    outputs:
+      version: ${{ steps.version.outputs.version }}
+    steps: [{run: make}]
+  lint:
+    steps: [{run: make lint}]
+  test:
+    needs: build
+    steps: [{run: make test}]
+  docs:
+    needs: [lint, test]
+    steps: [{run: make docs}]
+  release:
+    needs: lint
+    steps: [{run: make release}]
+`), &workflow))
+	resume := &Resume{Run: &history.Run{Number: 3, Jobs: []*history.Job{
+		{ID: "build", Name: "CI/build", Workflow: "CI", Conclusion: "success", Outputs: map[string]string{"version": "1.2.3"}},
+		{ID: "lint", Name: "CI/lint", Workflow: "CI", Conclusion: "success"},
+		{ID: "test", Name: "CI/test", Workflow: "CI", Conclusion: "failure"},
+		{ID: "docs", Name: "CI/docs", Workflow: "CI", Conclusion: "success"},
+	}}}
+	config := &Config{Resume: resume}
+
+	ctx := context.Background()
+	skipped := map[string]bool{}
+	// the jobs of a stage run after the jobs of the previous stages
+	for _, jobID := range []string{"build", "lint", "test", "release", "docs"} {
+		rc := &RunContext{Config: config, Run: &model.Run{Workflow: &workflow, JobID: jobID}, Name: jobID}
+		skipped[jobID] = rc.resumeJob(ctx)
+	}
+	assert.Equal(t, map[string]bool{
+		"build": true,
+		"lint":  true,
+		// failed
+		"test": false,
+		// didn't run
+		"release": false,
+		// needs a job that runs again
+		"docs": false,
+	}, skipped)
+	// the jobs that need the skipped job get its recorded outputs
+	assert.Equal(t, map[string]string{"version": "1.2.3"}, workflow.GetJob("build").Outputs)
+	assert.Equal(t, "success", workflow.GetJob("build").Result)
+	assert.Equal(t, "", workflow.GetJob("test").Result)
+}
+
+func TestResumeFromStep(t *testing.T) {
+	recorded := &history.Job{ID: "test", Name: "CI/test", Workflow: "CI", Conclusion: "failure", Container: "gha-CI-test", Steps: []*history.Step{
+		{ID: "setup", Stage: "pre", Outcome: "success", Conclusion: "success"},
+		{ID: "setup", Stage: "main", Outcome: "success", Conclusion: "success", Outputs: map[string]string{"path": "/opt/tool"},
+			Env: map[string]string{"TOOL_HOME": "/opt/tool"}, Path: []string{"/opt/tool/sbin", "/opt/tool/bin"}, State: map[string]string{"cache": "hit"}},
+		{ID: "test", Stage: "main", Outcome: "failure", Conclusion: "failure"},
+	}}
+	masked := &history.Job{ID: "test", Name: "CI/test", Workflow: "CI", Conclusion: "failure", Container: "gha-CI-test", Steps: []*history.Step{
+		{ID: "setup", Stage: "main", Outcome: "success", Conclusion: "success", Env: map[string]string{"TOKEN": "***"}, Masked: true},
+		{ID: "test", Stage: "main", Outcome: "failure", Conclusion: "failure"},
+	}}
+
+	for _, tt := range []struct {
+		name   string
+		job    *history.Job
+		reuse  bool
+		step   string
+		order  []string
+		errMsg string
+	}{
+		{name: "restart at step", job: recorded, reuse: true, step: "test", order: []string{"startContainer", "test", "post-test", "stopContainer", "interpolateOutputs", "closeContainer"}},
+		{name: "container not kept", job: recorded, step: "test", errMsg: "run #3 and the resumed run must run with --reuse to keep it"},
+		{name: "secret env", job: masked, reuse: true, step: "test", errMsg: "the step 'setup' of the job 'CI/test' passed a secret to an output, GITHUB_ENV or GITHUB_STATE in run #3, the job can't restart at the step 'test'"},
+		{name: "unknown step", job: recorded, reuse: true, step: "lint", errMsg: "the job 'CI/test' has no step 'lint' to restart at"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := common.WithJobErrorContainer(context.Background())
+			order := []string{}
+			record := func(name string) func(context.Context) error {
+				return func(_ context.Context) error {
+					order = append(order, name)
+					return nil
+				}
+			}
+			rc := &RunContext{
+				JobContainer: &jobContainerMock{},
+				Run: &model.Run{
+					JobID:    "test",
+					Workflow: &model.Workflow{Name: "CI", Jobs: map[string]*model.Job{"test": {}}},
+				},
+				Name:             "test",
+				StepResults:      map[string]*model.StepResult{},
+				Config:           &Config{ReuseContainers: tt.reuse, Resume: &Resume{Run: &history.Run{Number: 3, Jobs: []*history.Job{tt.job}}, Job: "test", Step: tt.step}},
+				nodeToolFullPath: "node",
+			}
+			rc.ExprEval = rc.NewExpressionEvaluator(ctx)
+
+			setup := &model.Step{ID: "setup", Uses: "./setup"}
+			test := &model.Step{ID: "test", Run: "make test"}
+			sfm := &stepFactoryMock{}
+			sm := &stepMock{}
+			sm.On("pre").Return(func(_ context.Context) error { return nil })
+			sm.On("main").Return(record("test"))
+			sm.On("post").Return(record("post-test"))
+			sfm.On("newStep", test, rc).Return(sm, nil)
+			jim := &jobInfoMock{}
+			jim.On("steps").Return([]*model.Step{setup, test})
+			jim.On("matrix").Return(map[string]interface{}{})
+			jim.On("startContainer").Return(record("startContainer"))
+			jim.On("stopContainer").Return(record("stopContainer"))
+			jim.On("interpolateOutputs").Return(record("interpolateOutputs"))
+			jim.On("closeContainer").Return(record("closeContainer"))
+			jim.On("result", "success")
+
+			err := newJobExecutor(jim, sfm, rc)(ctx)
+			if tt.errMsg != "" {
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.order, order)
+			// the skipped step has its recorded result
+			require.Contains(t, rc.StepResults, "setup")
+			assert.Equal(t, model.StepStatusSuccess, rc.StepResults["setup"].Outcome)
+			assert.Equal(t, map[string]string{"path": "/opt/tool"}, rc.StepResults["setup"].Outputs)
+			// and the env, path and state it changed
+			assert.Equal(t, map[string]string{"TOOL_HOME": "/opt/tool"}, rc.GlobalEnv)
+			assert.Equal(t, "/opt/tool", rc.Env["TOOL_HOME"])
+			assert.Equal(t, []string{"/opt/tool/sbin", "/opt/tool/bin"}, rc.ExtraPath)
+			assert.Equal(t, map[string]string{"cache": "hit"}, rc.IntraActionState["setup"])
+			sfm.AssertExpectations(t)
+		})
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/Leapfrog-DevOps/gha/pkg/common"
 	"github.com/Leapfrog-DevOps/gha/pkg/common/git"
 	"github.com/Leapfrog-DevOps/gha/pkg/model"
+	"github.com/Leapfrog-DevOps/gha/pkg/tracing"
 )
 
 func newLocalReusableWorkflowExecutor(rc *RunContext) common.Executor {
@@ -48,7 +49,7 @@ func newActionCacheReusableWorkflowExecutor(rc *RunContext, filename string, rem
 	return func(ctx context.Context) error {
 		ghctx := rc.getGithubContext(ctx)
 		remoteReusableWorkflow.URL = ghctx.ServerURL
-		sha, err := rc.Config.ActionCache.Fetch(ctx, filename, remoteReusableWorkflow.CloneURL(), remoteReusableWorkflow.Ref, ghctx.Token)
+		sha, err := fetchAction(ctx, rc.Config.ActionCache, filename, remoteReusableWorkflow.CloneURL(), remoteReusableWorkflow.Ref, ghctx.Token)
 		if err != nil {
 			return err
 		}
@@ -101,13 +102,14 @@ func cloneIfRequired(rc *RunContext, remoteReusableWorkflow remoteReusableWorkfl
 		},
 		func(ctx context.Context) error {
 			remoteReusableWorkflow.URL = rc.getGithubContext(ctx).ServerURL
-			return git.NewGitCloneExecutor(git.NewGitCloneExecutorInput{
-				URL:         remoteReusableWorkflow.CloneURL(),
+			cloneURL := remoteReusableWorkflow.CloneURL()
+			return tracing.NewSpanExecutor("git clone "+cloneURL, git.NewGitCloneExecutor(git.NewGitCloneExecutorInput{
+				URL:         cloneURL,
 				Ref:         remoteReusableWorkflow.Ref,
 				Dir:         targetDirectory,
 				Token:       rc.Config.Token,
 				OfflineMode: rc.Config.ActionOfflineMode,
-			})(ctx)
+			}), tracing.String("gha.action.url", cloneURL), tracing.String("gha.action.ref", remoteReusableWorkflow.Ref))(ctx)
 		},
 		nil,
 	)
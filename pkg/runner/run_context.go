@@ -22,12 +22,17 @@ import (
 
 	"github.com/Leapfrog-DevOps/gha/pkg/common"
 	"github.com/Leapfrog-DevOps/gha/pkg/container"
+	"github.com/Leapfrog-DevOps/gha/pkg/events"
 	"github.com/Leapfrog-DevOps/gha/pkg/exprparser"
 	"github.com/Leapfrog-DevOps/gha/pkg/model"
+	"github.com/Leapfrog-DevOps/gha/pkg/tracing"
 	"github.com/docker/go-connections/nat"
 	"github.com/opencontainers/selinux/go-selinux"
 )
 
+// defaultJobTimeoutMinutes is the job timeout GitHub applies when timeout-minutes is not set
+const defaultJobTimeoutMinutes = 360
+
 // RunContext contains info about current job
 type RunContext struct {
 	Name                string
@@ -53,6 +58,16 @@ type RunContext struct {
 	caller              *caller // job calling this RunContext (reusable workflows)
 	Cancelled           bool
 	nodeToolFullPath    string
+	logGroups           []logGroup // groups of log lines the current step started
+	problemMatchers     []*problemMatcher
+	summaries           []string          // the GITHUB_STEP_SUMMARY content of the steps of the job
+	history             *jobHistory       // records the job when the run is recorded in the history
+	cancel              *jobCancel        // cancels the job on the request of a hook
+	substitutes         map[string]string // the substitutions of the mocked actions of the steps, by step ID
+	stageResult         *model.StepResult // the result of the running stage of the current step, also of its pre and post stages
+
+	DeploymentEnvironment *model.DeploymentEnvironment // the deployment environment of the job, with the name evaluated
+	deploymentURL         string                       // the evaluated url of the deployment environment, set when the job finished
 }
 
 func (rc *RunContext) AddMask(mask string) {
@@ -344,7 +359,7 @@ func (rc *RunContext) startJobContainer() common.Executor {
 				Privileged:     rc.Config.Privileged,
 				UsernsMode:     rc.Config.UsernsMode,
 				Platform:       rc.Config.ContainerArchitecture,
-				Options:        rc.ExprEval.Interpolate(ctx, spec.Options),
+				Options:        rc.withOIDCContainerOptions(rc.ExprEval.Interpolate(ctx, spec.Options)),
 				NetworkMode:    networkName,
 				NetworkAliases: []string{serviceID},
 				ExposedPorts:   exposedPorts,
@@ -410,7 +425,7 @@ func (rc *RunContext) startJobContainer() common.Executor {
 			Privileged:     rc.Config.Privileged,
 			UsernsMode:     rc.Config.UsernsMode,
 			Platform:       rc.Config.ContainerArchitecture,
-			Options:        rc.options(ctx),
+			Options:        rc.withOIDCContainerOptions(rc.options(ctx)),
 		})
 		if rc.JobContainer == nil {
 			return errors.New("Failed to create job container")
@@ -701,6 +716,23 @@ func (rc *RunContext) result(result string) {
 	rc.Run.Job().Result = result
 }
 
+// failJob fails the job before its steps run, its result is recorded and reported like the one of a failing step
+func (rc *RunContext) failJob(ctx context.Context, err error) {
+	common.Logger(ctx).Errorf("%v", err)
+	rc.history.start()
+	setJobResult(ctx, rc, rc, "failure")
+	rc.history.complete("failure")
+	rc.jobFinished(ctx, "failure")
+}
+
+// cancelJob cancels the job before it starts, its result is cancelled
+func (rc *RunContext) cancelJob(ctx context.Context, cause error) {
+	common.Logger(ctx).Infof("\U0001F6A7  Job not started, %v", cause)
+	setJobResult(ctx, rc, rc, "cancelled")
+	rc.history.complete("cancelled")
+	rc.jobFinished(ctx, "cancelled")
+}
+
 func (rc *RunContext) steps() []*model.Step {
 	return rc.Run.Job().Steps
 }
@@ -722,17 +754,103 @@ func (rc *RunContext) Executor() (common.Executor, error) {
 	}
 
 	return func(ctx context.Context) error {
+		if rc.resumeJob(ctx) {
+			return nil
+		}
+		if err := jobCancellationError(ctx); errors.Is(err, errJobCancelled) {
+			// e.g. a queued leg of a matrix cancelled by fail-fast
+			rc.cancelJob(ctx, err)
+			return nil
+		}
 		res, err := rc.isEnabled(ctx)
 		if err != nil {
+			rc.jobFinished(ctx, "failure")
 			return err
 		}
 		if res {
-			return executor(ctx)
+			if err := rc.startJobHook(ctx); errors.Is(err, ErrSkipJob) {
+				common.Logger(ctx).Infof("Skipping job '%s' due to a hook", rc.String())
+				setJobResult(ctx, rc, rc, "skipped")
+				rc.history.complete("skipped")
+				rc.jobFinished(ctx, "skipped")
+				return nil
+			} else if err != nil {
+				rc.failJob(ctx, fmt.Errorf("the job was vetoed by a hook: %w", err))
+				return nil
+			}
+			if err := rc.withDeploymentEnvironment(ctx); err != nil {
+				rc.failJob(ctx, err)
+				return nil
+			}
+			ctx, release, err := rc.withJobConcurrency(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
+			ctx, cancel := rc.withJobTimeout(ctx)
+			defer cancel()
+			// the hooks cancel the job with a cause, so its result is cancelled
+			cancelCtx, cancelJob := context.WithCancelCause(common.JobCancelContext(ctx))
+			defer cancelJob(nil)
+			ctx = common.WithJobCancelContext(ctx, cancelCtx)
+			rc.cancel.set(cancelJob)
+			rc.history.start()
+			rc.emitJobEvent(ctx, events.JobStarted, "")
+			ctx, span := tracing.Start(ctx, rc.String(), rc.spanAttributes()...)
+			defer span.End()
+			err = executor(ctx)
+			span.SetError(err)
+			if jobType != model.JobTypeDefault {
+				// the jobs of the reusable workflow set the result of the calling job
+				rc.jobFinished(ctx, rc.Run.Job().Result)
+			}
+			return err
 		}
+		rc.jobFinished(ctx, "skipped")
 		return nil
 	}, nil
 }
 
+// withJobTimeout installs the job's timeout-minutes deadline as the job cancel context,
+// so that a timeout cancels the running step like a graceful cancellation does, while
+// `if: always()` steps, post steps and the container cleanup still get to run.
+func (rc *RunContext) withJobTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeoutMinutes := int64(defaultJobTimeoutMinutes)
+	if timeout := rc.ExprEval.Interpolate(ctx, rc.Run.Job().TimeoutMinutes); timeout != "" {
+		if minutes, err := strconv.ParseInt(timeout, 10, 64); err == nil && minutes > 0 {
+			timeoutMinutes = minutes
+		} else {
+			common.Logger(ctx).Warnf("Invalid value '%s' for 'timeout-minutes', using the default of %d minutes", timeout, defaultJobTimeoutMinutes)
+		}
+	}
+
+	parent := common.JobCancelContext(ctx)
+	if parent == nil {
+		parent = context.Background()
+	}
+	cancelCtx, cancel := context.WithTimeoutCause(parent, time.Duration(timeoutMinutes)*time.Minute,
+		fmt.Errorf("the job has exceeded the maximum execution time of %d minutes", timeoutMinutes))
+	return common.WithJobCancelContext(ctx, cancelCtx), cancel
+}
+
+// errJobCancelled is wrapped by the causes of the cancellations that give the job the result
+// cancelled, e.g. fail-fast or cancel-in-progress
+var errJobCancelled = errors.New("cancelled the job")
+
+// jobCancellationError returns why the job cancel context was cancelled, if the reason fails
+// the job like timeout-minutes does, or wraps errJobCancelled like fail-fast and a cancel-in-progress
+// concurrency group do. A plain cancellation, e.g. by Ctrl+C, only cancels the running steps.
+func jobCancellationError(ctx context.Context) error {
+	cctx := common.JobCancelContext(ctx)
+	if cctx == nil || cctx.Err() == nil {
+		return nil
+	}
+	if cause := context.Cause(cctx); !errors.Is(cause, cctx.Err()) {
+		return cause
+	}
+	return nil
+}
+
 func (rc *RunContext) containerImage(ctx context.Context) string {
 	job := rc.Run.Job()
 
@@ -1063,7 +1181,7 @@ func (rc *RunContext) withGithubEnv(ctx context.Context, github *model.GithubCon
 	}
 
 	// Set OIDC environment variables for AWS authentication
-	setOIDCVars(rc, env)
+	setOIDCVars(ctx, rc, github, env)
 
 	for _, platformName := range rc.runsOnPlatformNames(ctx) {
 		if platformName != "" {
@@ -1099,22 +1217,6 @@ func setActionRuntimeVars(rc *RunContext, env map[string]string) {
 	env["ACTIONS_RUNTIME_TOKEN"] = actionsRuntimeToken
 }
 
-func setOIDCVars(rc *RunContext, env map[string]string) {
-	// Check if OIDC server is running by looking for status file
-	statusFile := os.ExpandEnv("$HOME/.local/state/gha/oidc-status.json")
-	if data, err := os.ReadFile(statusFile); err == nil {
-		var status struct {
-			Running  bool   `json:"running"`
-			NgrokURL string `json:"ngrok_url"`
-			Password string `json:"password"`
-		}
-		if json.Unmarshal(data, &status) == nil && status.Running && status.NgrokURL != "" && status.Password != "" {
-			env["ACTIONS_ID_TOKEN_REQUEST_URL"] = status.NgrokURL + "/token"
-			env["ACTIONS_ID_TOKEN_REQUEST_TOKEN"] = status.Password
-		}
-	}
-}
-
 func (rc *RunContext) handleCredentials(ctx context.Context) (string, string, error) {
 	// TODO: remove below 2 lines when we can release gha with breaking changes
 	username := rc.Config.Secrets["DOCKER_USERNAME"]
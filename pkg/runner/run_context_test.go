@@ -9,7 +9,9 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
 	"github.com/Leapfrog-DevOps/gha/pkg/exprparser"
 	"github.com/Leapfrog-DevOps/gha/pkg/model"
 	"github.com/golang-jwt/jwt/v5"
@@ -726,3 +728,58 @@ func TestSetRuntimeVariablesWithRunID(t *testing.T) {
 	assert.True(t, ok, "scp claim exists")
 	assert.Equal(t, "Actions.Results:45:45", scp, "contains expected scp claim")
 }
+
+func TestRunContextWithJobTimeout(t *testing.T) {
+	tests := []struct {
+		description string
+		job         string
+		matrix      map[string]interface{}
+		want        time.Duration
+	}{
+		{
+			description: "defaults to 360 minutes",
+			job:         `runs-on: ubuntu-latest`,
+			want:        360 * time.Minute,
+		},
+		{
+			description: "uses timeout-minutes",
+			job: `runs-on: ubuntu-latest
+timeout-minutes: 5`,
+			want: 5 * time.Minute,
+		},
+		{
+			description: "evaluates expressions",
+			job: `runs-on: ubuntu-latest
+timeout-minutes: ${{ matrix.timeout }}`,
+			matrix: map[string]interface{}{"timeout": 10},
+			want:   10 * time.Minute,
+		},
+		{
+			description: "falls back to the default for invalid values",
+			job: `runs-on: ubuntu-latest
+timeout-minutes: soon`,
+			want: 360 * time.Minute,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			rc := createIfTestRunContext(map[string]*model.Job{
+				"job1": createJob(t, test.job, ""),
+			})
+			rc.Matrix = test.matrix
+			rc.ExprEval = rc.NewExpressionEvaluator(context.Background())
+
+			start := time.Now()
+			ctx, cancel := rc.withJobTimeout(context.Background())
+			defer cancel()
+
+			cctx := common.JobCancelContext(ctx)
+			assert.NotNil(t, cctx)
+			deadline, ok := cctx.Deadline()
+			assert.True(t, ok)
+			assert.WithinDuration(t, start.Add(test.want), deadline, time.Second)
+			assert.NoError(t, jobCancellationError(ctx))
+		})
+	}
+}
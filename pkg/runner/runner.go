@@ -6,9 +6,13 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"strings"
 
 	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/events"
+	"github.com/Leapfrog-DevOps/gha/pkg/history"
 	"github.com/Leapfrog-DevOps/gha/pkg/model"
+	"github.com/Leapfrog-DevOps/gha/pkg/tracing"
 	docker_container "github.com/docker/docker/api/types/container"
 	log "github.com/sirupsen/logrus"
 )
@@ -20,48 +24,69 @@ type Runner interface {
 
 // Config contains the config for a new runner
 type Config struct {
-	Actor                              string                       // the user that triggered the event
-	Workdir                            string                       // path to working directory
-	ActionCacheDir                     string                       // path used for caching action contents
-	ActionOfflineMode                  bool                         // when offline, use caching action contents
-	BindWorkdir                        bool                         // bind the workdir to the job container
-	EventName                          string                       // name of event to run
-	EventPath                          string                       // path to JSON file to use for event.json in containers
-	DefaultBranch                      string                       // name of the main branch for this repository
-	ReuseContainers                    bool                         // reuse containers to maintain state
-	ForcePull                          bool                         // force pulling of the image, even if already present
-	ForceRebuild                       bool                         // force rebuilding local docker image action
-	LogOutput                          bool                         // log the output from docker run
-	JSONLogger                         bool                         // use json or text logger
-	LogPrefixJobID                     bool                         // switches from the full job name to the job id
-	Env                                map[string]string            // env for containers
-	Inputs                             map[string]string            // manually passed action inputs
-	Secrets                            map[string]string            // list of secrets
-	Vars                               map[string]string            // list of vars
-	Token                              string                       // GitHub token
-	InsecureSecrets                    bool                         // switch hiding output when printing to terminal
-	Platforms                          map[string]string            // list of platforms
-	Privileged                         bool                         // use privileged mode
-	UsernsMode                         string                       // user namespace to use
-	ContainerArchitecture              string                       // Desired OS/architecture platform for running containers
-	ContainerDaemonSocket              string                       // Path to Docker daemon socket
-	ContainerOptions                   string                       // Options for the job container
-	UseGitIgnore                       bool                         // controls if paths in .gitignore should not be copied into container, default true
-	GitHubInstance                     string                       // GitHub instance to use, default "github.com"
-	ContainerCapAdd                    []string                     // list of kernel capabilities to add to the containers
-	ContainerCapDrop                   []string                     // list of kernel capabilities to remove from the containers
-	AutoRemove                         bool                         // controls if the container is automatically removed upon workflow completion
-	ArtifactServerPath                 string                       // the path where the artifact server stores uploads
-	ArtifactServerAddr                 string                       // the address the artifact server binds to
-	ArtifactServerPort                 string                       // the port the artifact server binds to
-	NoSkipCheckout                     bool                         // do not skip actions/checkout
-	RemoteName                         string                       // remote name in local git repo config
-	ReplaceGheActionWithGithubCom      []string                     // Use actions from GitHub Enterprise instance to GitHub
-	ReplaceGheActionTokenWithGithubCom string                       // Token of private action repo on GitHub.
-	Matrix                             map[string]map[string]bool   // Matrix config to run
-	ContainerNetworkMode               docker_container.NetworkMode // the network mode of job containers (the value of --network)
-	ActionCache                        ActionCache                  // Use a custom ActionCache Implementation
-	ConcurrentJobs                     int                          // Number of max concurrent jobs
+	Actor                              string                           // the user that triggered the event
+	Workdir                            string                           // path to working directory
+	ActionCacheDir                     string                           // path used for caching action contents
+	ActionOfflineMode                  bool                             // when offline, use caching action contents
+	BindWorkdir                        bool                             // bind the workdir to the job container
+	EventName                          string                           // name of event to run
+	EventPath                          string                           // path to JSON file to use for event.json in containers
+	DefaultBranch                      string                           // name of the main branch for this repository
+	ReuseContainers                    bool                             // reuse containers to maintain state
+	ForcePull                          bool                             // force pulling of the image, even if already present
+	ForceRebuild                       bool                             // force rebuilding local docker image action
+	LogOutput                          bool                             // log the output from docker run
+	JSONLogger                         bool                             // use json or text logger
+	LogPrefixJobID                     bool                             // switches from the full job name to the job id
+	Env                                map[string]string                // env for containers
+	Inputs                             map[string]string                // manually passed action inputs
+	Secrets                            map[string]string                // list of secrets
+	Vars                               map[string]string                // list of vars
+	Token                              string                           // GitHub token
+	InsecureSecrets                    bool                             // switch hiding output when printing to terminal
+	Platforms                          map[string]string                // list of platforms
+	Privileged                         bool                             // use privileged mode
+	UsernsMode                         string                           // user namespace to use
+	ContainerArchitecture              string                           // Desired OS/architecture platform for running containers
+	ContainerDaemonSocket              string                           // Path to Docker daemon socket
+	ContainerOptions                   string                           // Options for the job container
+	UseGitIgnore                       bool                             // controls if paths in .gitignore should not be copied into container, default true
+	GitHubInstance                     string                           // GitHub instance to use, default "github.com"
+	ContainerCapAdd                    []string                         // list of kernel capabilities to add to the containers
+	ContainerCapDrop                   []string                         // list of kernel capabilities to remove from the containers
+	AutoRemove                         bool                             // controls if the container is automatically removed upon workflow completion
+	ArtifactServerPath                 string                           // the path where the artifact server stores uploads
+	ArtifactServerAddr                 string                           // the address the artifact server binds to
+	ArtifactServerPort                 string                           // the port the artifact server binds to
+	NoSkipCheckout                     bool                             // do not skip actions/checkout
+	RemoteName                         string                           // remote name in local git repo config
+	ReplaceGheActionWithGithubCom      []string                         // Use actions from GitHub Enterprise instance to GitHub
+	ReplaceGheActionTokenWithGithubCom string                           // Token of private action repo on GitHub.
+	Matrix                             map[string]map[string]bool       // Matrix config to run
+	ContainerNetworkMode               docker_container.NetworkMode     // the network mode of job containers (the value of --network)
+	ActionCache                        ActionCache                      // Use a custom ActionCache Implementation
+	ConcurrentJobs                     int                              // Number of max concurrent jobs
+	EnvironmentSecrets                 EnvironmentValues                // secrets of deployment environments, overlaid on Secrets
+	EnvironmentVars                    EnvironmentValues                // vars of deployment environments, overlaid on Vars
+	EnvironmentProtections             map[string]EnvironmentProtection // protection rules of deployment environments
+	DeploymentApprover                 DeploymentApprover               // asks for the approval of protected deployments, nil when not interactive
+	ReportDir                          string                           // directory the job summaries are written to at the end of the run
+	History                            *history.Store                   // records the run in the history, nil to not record it
+	Reports                            []Report                         // files the results of the run are exported to at its end
+	TraceFile                          string                           // file the spans of the run are written to as OTLP/JSON
+	TraceEndpoint                      *tracing.Endpoint                // OTLP HTTP endpoint the spans of the run are exported to
+	Hooks                              Hooks                            // called at the lifecycle events of the run by programs embedding the runner
+	JobStartedHook                     string                           // script run before the steps of every job, like ACTIONS_RUNNER_HOOK_JOB_STARTED
+	JobCompletedHook                   string                           // script run after the steps of every job, like ACTIONS_RUNNER_HOOK_JOB_COMPLETED
+	Debugger                           Debugger                         // pauses the run at the breakpoints of the steps, nil when the run isn't debugged
+	BreakBefore                        []string                         // IDs of the steps the debugger pauses the run before, BreakAll for every step
+	BreakOnFailure                     bool                             // the debugger pauses the run after a step failed
+	Resume                             *Resume                          // the recorded run that is resumed, nil to run all the jobs
+	NeedsStubs                         map[string]NeedsStub             // the result and outputs of the needed jobs that don't run because they aren't planned, by job ID
+	SkipSteps                          []string                         // the steps that don't run, as <job-id>.<step-id>
+	OnlySteps                          []string                         // the only steps that run in their jobs, as <job-id>.<step-id>, the jobs without any run all their steps
+	MockSteps                          map[string]StepMock              // the steps that set their mocked outputs and outcome instead of running, by <job-id>.<step-id>
+	ActionMocks                        []ActionMock                     // substitutes of the actions of the steps, the first mock matching the `uses` of a step applies
 }
 
 func (config *Config) GetConcurrentJobs() int {
@@ -121,13 +146,16 @@ func (runner *runnerImpl) configure() (Runner, error) {
 // NewPlanExecutor ...
 func (runner *runnerImpl) NewPlanExecutor(plan *model.Plan) common.Executor {
 	maxJobNameLen := 0
+	if runner.caller == nil {
+		stubNeeds(plan, runner.config.NeedsStubs)
+	}
 
 	stagePipeline := make([]common.Executor, 0)
 	log.Debugf("Plan Stages: %v", plan.Stages)
 
 	for i := range plan.Stages {
 		stage := plan.Stages[i]
-		stagePipeline = append(stagePipeline, func(ctx context.Context) error {
+		stagePipeline = append(stagePipeline, tracing.NewSpanExecutor(fmt.Sprintf("Stage %d", i+1), func(ctx context.Context) error {
 			pipeline := make([]common.Executor, 0)
 			for _, run := range stage.Runs {
 				log.Debugf("Stages Runs: %v", stage.Runs)
@@ -193,6 +221,13 @@ func (runner *runnerImpl) NewPlanExecutor(plan *model.Plan) common.Executor {
 					if len(matrixes) > 1 {
 						rc.Name = fmt.Sprintf("%s-%d", rc.Name, i+1)
 					}
+					if summaries := jobSummariesFromContext(ctx); summaries != nil {
+						summaries.add(rc)
+					}
+					if h := runHistoryFromContext(ctx); h != nil {
+						rc.history = h.addJob(rc)
+					}
+					rc.emitJobEvent(ctx, events.JobQueued, "")
 					if len(rc.String()) > maxJobNameLen {
 						maxJobNameLen = len(rc.String())
 					}
@@ -204,26 +239,83 @@ func (runner *runnerImpl) NewPlanExecutor(plan *model.Plan) common.Executor {
 							return err
 						}
 
-						return executor(common.WithJobErrorContainer(WithJobLogger(ctx, rc.Run.JobID, jobName, rc.Config, &rc.Masks, matrix)))
+						return executor(WithJobLogger(ctx, rc.Run.JobID, jobName, rc.Config, &rc.Masks, matrix))
 					})
 				}
-				pipeline = append(pipeline, common.NewParallelExecutor(maxParallel, stageExecutor...))
+				failFast := job.Strategy != nil && job.Strategy.FailFast
+				pipeline = append(pipeline, newMatrixExecutor(failFast, maxParallel, stageExecutor...))
 			}
 
 			log.Debugf("PlanExecutor concurrency: %d", runner.config.GetConcurrentJobs())
 			return common.NewParallelExecutor(runner.config.GetConcurrentJobs(), pipeline...)(ctx)
-		})
+		}, tracing.Int("gha.stage", i+1), tracing.String("gha.stage.jobs", strings.Join(stage.GetJobIDs(), ","))))
 	}
 
-	return common.NewPipelineExecutor(stagePipeline...).Then(handleFailure(plan))
+	executor := runner.newWorkflowConcurrencyExecutor(plan, common.NewPipelineExecutor(stagePipeline...)).Then(handleFailure(plan))
+	if runner.caller == nil {
+		// the jobs of called reusable workflows are reported and recorded with the jobs of the run
+		executor = newPlanEventsExecutor(plan, executor)
+		if runner.config.Hooks != nil {
+			executor = newPlanHooksExecutor(runner.config.Hooks, plan, executor)
+		}
+		if runner.config.ReportDir != "" {
+			executor = newJobSummariesExecutor(runner.config.ReportDir, executor)
+		}
+		if runner.config.History != nil || len(runner.config.Reports) > 0 {
+			executor = newRunHistoryExecutor(runner.config, plan, executor)
+		}
+		if runner.config.TraceFile != "" || runner.config.TraceEndpoint != nil {
+			executor = newTraceExecutor(runner.config, plan, executor)
+		}
+	}
+	return executor
+}
+
+// newMatrixExecutor runs the jobs of a matrix, at most maxParallel at the same time.
+// With fail-fast enabled the first failing job cancels the in-flight jobs of the matrix
+// through the job cancel context, so their `cancelled()` steps still run, and the jobs
+// that are still queued are not started at all. Both get the result cancelled.
+func newMatrixExecutor(failFast bool, maxParallel int, jobs ...common.Executor) common.Executor {
+	return func(ctx context.Context) error {
+		parent := common.JobCancelContext(ctx)
+		if parent == nil {
+			parent = context.Background()
+		}
+		matrixCtx, cancelMatrix := context.WithCancelCause(parent)
+		defer cancelMatrix(nil)
+
+		executors := make([]common.Executor, 0, len(jobs))
+		for i, job := range jobs {
+			executors = append(executors, func(ctx context.Context) error {
+				ctx = common.WithJobErrorContainer(ctx)
+				if !failFast {
+					return job(ctx)
+				}
+				if matrixCtx.Err() != nil {
+					// the job records its cancellation without starting
+					common.Logger(ctx).Infof("\U0001F6A7  Skipping matrix job %d of %d, the matrix has been cancelled due to fail-fast", i+1, len(jobs))
+				}
+				err := job(common.WithJobCancelContext(ctx, matrixCtx))
+				if (err != nil || common.JobError(ctx) != nil) && matrixCtx.Err() == nil {
+					common.Logger(ctx).Infof("\U0001F6A7  Matrix job %d of %d failed, cancelling the remaining matrix jobs due to fail-fast", i+1, len(jobs))
+					cancelMatrix(fmt.Errorf("matrix job %d of %d failed, fail-fast %w", i+1, len(jobs), errJobCancelled))
+				}
+				return err
+			})
+		}
+		return common.NewParallelExecutor(maxParallel, executors...)(ctx)
+	}
 }
 
 func handleFailure(plan *model.Plan) common.Executor {
 	return func(_ context.Context) error {
 		for _, stage := range plan.Stages {
 			for _, run := range stage.Runs {
-				if run.Job().Result == "failure" {
+				switch run.Job().Result {
+				case "failure":
 					return fmt.Errorf("Job '%s' failed", run.String())
+				case "cancelled":
+					return fmt.Errorf("Job '%s' was cancelled", run.String())
 				}
 			}
 		}
@@ -258,6 +350,7 @@ func (runner *runnerImpl) newRunContext(ctx context.Context, run *model.Run, mat
 		StepResults: make(map[string]*model.StepResult),
 		Matrix:      matrix,
 		caller:      runner.caller,
+		cancel:      &jobCancel{},
 	}
 	rc.ExprEval = rc.NewExpressionEvaluator(ctx)
 	rc.Name = rc.ExprEval.Interpolate(ctx, run.String())
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -13,14 +14,17 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/sirupsen/logrus"
 	log "github.com/sirupsen/logrus"
 	assert "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v3"
 
 	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/history"
 	"github.com/Leapfrog-DevOps/gha/pkg/model"
 )
 
@@ -817,3 +821,107 @@ func TestRunMatrixWithUserDefinedInclusions(t *testing.T) {
 
 	tjfi.runTest(context.Background(), t, &Config{Matrix: matrix})
 }
+
+func TestNewMatrixExecutorFailFast(t *testing.T) {
+	table := []struct {
+		name     string
+		failFast bool
+		executed []string
+	}{
+		{"fail-fast", true, []string{"job1", "job2 cancelled", "job3 cancelled"}},
+		{"no-fail-fast", false, []string{"job1", "job2", "job3"}},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.name, func(t *testing.T) {
+			executed := make([]string, 0)
+			newJob := func(name string, fail bool) common.Executor {
+				return func(ctx context.Context) error {
+					if err := jobCancellationError(ctx); errors.Is(err, errJobCancelled) {
+						// the queued jobs record their cancellation without running
+						executed = append(executed, name+" cancelled")
+						return nil
+					}
+					executed = append(executed, name)
+					if fail {
+						common.SetJobError(ctx, fmt.Errorf("%s failed", name))
+					}
+					return nil
+				}
+			}
+
+			err := newMatrixExecutor(tt.failFast, 1, newJob("job1", true), newJob("job2", false), newJob("job3", false))(context.Background())
+			assert.Nil(t, err)
+			assert.Equal(t, tt.executed, executed)
+		})
+	}
+}
+
+func TestNewMatrixExecutorFailFastCancelsRunningJobs(t *testing.T) {
+	started := make(chan struct{})
+	running := func(ctx context.Context) error {
+		close(started)
+		select {
+		case <-common.JobCancelContext(ctx).Done():
+			// the cancelled job gets the result cancelled
+			assert.ErrorIs(t, jobCancellationError(ctx), errJobCancelled)
+			return nil
+		case <-time.After(10 * time.Second):
+			return fmt.Errorf("the running job was not cancelled")
+		}
+	}
+	failing := func(ctx context.Context) error {
+		<-started
+		common.SetJobError(ctx, fmt.Errorf("failed"))
+		return nil
+	}
+
+	err := newMatrixExecutor(true, 2, running, failing)(context.Background())
+	assert.Nil(t, err)
+}
+
+func TestCancelledMatrixJobIsRecorded(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ci.yml"), []byte(`name: CI
+on: push
+jobs:
+  test:
+    runs-on: ubuntu-latest
+    strategy:
+      matrix:
+        node: [20, 22]
+    steps:
+      - run: exit 1
+`), 0o600))
+	planner, err := model.NewWorkflowPlanner(dir, true, false)
+	require.NoError(t, err)
+	plan, err := planner.PlanEvent("push")
+	require.NoError(t, err)
+
+	hooks := &recordingHooks{}
+	config := &Config{
+		Workdir:   dir,
+		EventName: "push",
+		Platforms: map[string]string{"ubuntu-latest": "node:16-buster-slim"},
+		Hooks:     hooks,
+		History:   &history.Store{Dir: t.TempDir()},
+	}
+	r, err := New(config)
+	require.NoError(t, err)
+
+	// the legs of a matrix cancelled by fail-fast before they started
+	cancelCtx, cancel := context.WithCancelCause(context.Background())
+	cancel(fmt.Errorf("matrix job 1 of 3 failed, fail-fast %w", errJobCancelled))
+	err = r.NewPlanExecutor(plan)(common.WithJobCancelContext(context.Background(), cancelCtx))
+	assert.EqualError(t, err, "Job 'test' was cancelled")
+	assert.Equal(t, "cancelled", plan.Stages[0].Runs[0].Job().Result)
+	assert.ElementsMatch(t, []string{"job end CI/test-1 cancelled", "job end CI/test-2 cancelled"}, hooks.calls[1:3])
+	run, err := config.History.Get(1)
+	require.NoError(t, err)
+	require.Len(t, run.Jobs, 2)
+	for _, job := range run.Jobs {
+		assert.Equal(t, history.StatusCompleted, job.Status, job.Name)
+		assert.Equal(t, "cancelled", job.Conclusion, job.Name)
+		assert.True(t, job.StartedAt.IsZero(), job.Name)
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/Leapfrog-DevOps/gha/pkg/container"
 	"github.com/Leapfrog-DevOps/gha/pkg/exprparser"
 	"github.com/Leapfrog-DevOps/gha/pkg/model"
+	"github.com/Leapfrog-DevOps/gha/pkg/tracing"
 	"github.com/sirupsen/logrus"
 )
 
@@ -76,7 +77,13 @@ func processRunnerSummaryCommand(ctx context.Context, fileName string, rc *RunCo
 		return nil
 	}
 	common.Logger(ctx).WithFields(logrus.Fields{"command": "summary", "content": string(summary)}).Infof("  \U00002699  Summary - %s", string(summary))
-	return nil
+
+	// the summaries of the job are reported in step order at the end of the run, without the secrets
+	// GitHub scrubs from them too
+	jobRC := rc.jobRunContext()
+	jobRC.summaries = append(jobRC.summaries, rc.mask(string(summary)))
+	// clear the file, so the step running a composite action doesn't report the summary of its last step again
+	return rc.JobContainer.Copy(rc.JobContainer.GetActPath(), &container.FileEntry{Name: fileName, Mode: 0o666})(ctx)
 }
 
 func processRunnerEnvFileCommand(ctx context.Context, fileName string, rc *RunContext, setter func(context.Context, map[string]string, string)) error {
@@ -108,6 +115,10 @@ func runStepExecutor(step step, stage stepStage, executor common.Executor) commo
 		if stage == stepStageMain {
 			rc.StepResults[rc.CurrentStep] = stepResult
 		}
+		rc.stageResult = stepResult
+		defer func() {
+			rc.stageResult = nil
+		}()
 
 		err := setupEnv(ctx, step)
 		if err != nil {
@@ -128,6 +139,9 @@ func runStepExecutor(step step, stage stepStage, executor common.Executor) commo
 			stepResult.Conclusion = model.StepStatusSkipped
 			stepResult.Outcome = model.StepStatusSkipped
 			logger.WithField("stepResult", stepResult.Outcome).Debugf("Skipping step '%s' due to '%s'", stepModel, ifExpression)
+			stepString := rc.ExprEval.Interpolate(ctx, stepModel.String())
+			rc.history.addStep(stepModel.ID, stepString, stage, stepResult, false)
+			rc.stepFinished(ctx, stepModel, stepString, stage, stepResult)
 			return nil
 		}
 
@@ -136,6 +150,30 @@ func runStepExecutor(step step, stage stepStage, executor common.Executor) commo
 			stepString = "add-mask command"
 		}
 		logger.Infof("\u2B50 Run %s %s", stage, stepString)
+		if substitute := rc.substitutes[stepModel.ID]; substitute != "" {
+			logger.WithField("substitute", substitute).Infof("  \U0001F3AD  %s", substitute)
+		}
+		rc.history.addStep(stepModel.ID, stepString, stage, stepResult, true)
+		defer rc.history.completeStep()
+		rc.stepStarted(ctx, stepModel, stepString, stage)
+		defer func() {
+			rc.stepFinished(ctx, stepModel, stepString, stage, stepResult)
+		}()
+
+		ctx, span := tracing.Start(ctx, fmt.Sprintf("%s %s", stage, stepString), append(rc.spanAttributes(),
+			tracing.String("gha.step.id", stepModel.ID),
+			tracing.String("gha.step.stage", strings.ToLower(stage.String())),
+		)...)
+		defer func() {
+			span.SetAttributes(
+				tracing.String("gha.step.outcome", stepResult.Outcome.String()),
+				tracing.String("gha.step.conclusion", stepResult.Conclusion.String()),
+			)
+			if stepResult.Outcome == model.StepStatusFailure {
+				span.SetError(fmt.Errorf("the step failed"))
+			}
+			span.End()
+		}()
 
 		// Prepare and clean Runner File Commands
 		actPath := rc.JobContainer.GetActPath()
@@ -172,15 +210,18 @@ func runStepExecutor(step step, stage stepStage, executor common.Executor) commo
 			Mode: 0o666,
 		})(ctx)
 
-		stepCtx, cancelStepCtx := context.WithCancel(ctx)
-		defer cancelStepCtx()
-		var cancelTimeOut context.CancelFunc
-		stepCtx, cancelTimeOut = evaluateStepTimeout(stepCtx, rc.ExprEval, stepModel)
-		defer cancelTimeOut()
-		monitorJobCancellation(ctx, stepCtx, cctx, rc, logger, ifExpression, step, stage, cancelStepCtx)
 		startTime := time.Now()
-		err = executor(stepCtx)
+		err = rc.debugStep(ctx, step, stage, func() error {
+			stepCtx, cancelStepCtx := context.WithCancel(ctx)
+			defer cancelStepCtx()
+			var cancelTimeOut context.CancelFunc
+			stepCtx, cancelTimeOut = evaluateStepTimeout(stepCtx, rc.ExprEval, stepModel)
+			defer cancelTimeOut()
+			monitorJobCancellation(ctx, stepCtx, cctx, rc, logger, ifExpression, step, stage, cancelStepCtx)
+			return executor(stepCtx)
+		})
 		executionTime := time.Since(startTime)
+		rc.endLogGroups(ctx)
 
 		if err == nil {
 			logger.WithFields(logrus.Fields{"executionTime": executionTime, "stepResult": stepResult.Outcome}).Infof("  \u2705  Success - %s %s [%s]", stage, stepString, executionTime)
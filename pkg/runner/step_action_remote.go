@@ -17,6 +17,7 @@ import (
 	"github.com/Leapfrog-DevOps/gha/pkg/common"
 	"github.com/Leapfrog-DevOps/gha/pkg/common/git"
 	"github.com/Leapfrog-DevOps/gha/pkg/model"
+	"github.com/Leapfrog-DevOps/gha/pkg/tracing"
 )
 
 type stepActionRemote struct {
@@ -70,7 +71,7 @@ func (sar *stepActionRemote) prepareActionExecutor() common.Executor {
 			sar.cacheDir = fmt.Sprintf("%s/%s", sar.remoteAction.Org, sar.remoteAction.Repo)
 			repoURL := sar.remoteAction.URL + "/" + sar.cacheDir
 			repoRef := sar.remoteAction.Ref
-			sar.resolvedSha, err = cache.Fetch(ctx, sar.cacheDir, repoURL, repoRef, github.Token)
+			sar.resolvedSha, err = fetchAction(ctx, cache, sar.cacheDir, repoURL, repoRef, github.Token)
 			if err != nil {
 				return fmt.Errorf("failed to fetch \"%s\" version \"%s\": %w", repoURL, repoRef, err)
 			}
@@ -107,13 +108,13 @@ func (sar *stepActionRemote) prepareActionExecutor() common.Executor {
 		}
 
 		actionDir := fmt.Sprintf("%s/%s", sar.RunContext.ActionCacheDir(), safeFilename(sar.Step.Uses))
-		gitClone := stepActionRemoteNewCloneExecutor(git.NewGitCloneExecutorInput{
+		gitClone := tracing.NewSpanExecutor("git clone "+sar.remoteAction.CloneURL(), stepActionRemoteNewCloneExecutor(git.NewGitCloneExecutorInput{
 			URL:         sar.remoteAction.CloneURL(),
 			Ref:         sar.remoteAction.Ref,
 			Dir:         actionDir,
 			Token:       github.Token,
 			OfflineMode: sar.RunContext.Config.ActionOfflineMode,
-		})
+		}), tracing.String("gha.action.url", sar.remoteAction.CloneURL()), tracing.String("gha.action.ref", sar.remoteAction.Ref))
 		var ntErr common.Executor
 		if err := gitClone(ctx); err != nil {
 			if errors.Is(err, git.ErrShortRef) {
@@ -13,6 +13,13 @@ type stepFactory interface {
 type stepFactoryImpl struct{}
 
 func (sf *stepFactoryImpl) newStep(stepModel *model.Step, rc *RunContext) (step, error) {
+	if s := rc.overrideStep(stepModel); s != nil {
+		return s, nil
+	}
+	// the mocked actions aren't fetched
+	if s := rc.mockAction(stepModel); s != nil {
+		return s, nil
+	}
 	switch stepModel.Type() {
 	case model.StepTypeInvalid:
 		return nil, fmt.Errorf("Invalid run/uses syntax for job:%s step:%+v", rc.Run, stepModel)
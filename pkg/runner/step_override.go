@@ -0,0 +1,128 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+// StepMock is the outputs and the outcome of a mocked step, it sets them without running
+type StepMock struct {
+	Outputs map[string]string
+	Outcome string // success or failure, success by default
+}
+
+// stepOverride replaces a step of the job skipped with SkipSteps or OnlySteps, or mocked with MockSteps. The
+// skipped step doesn't run, the mocked step sets its outputs and outcome when its `if` passes, so the
+// conditions and the `steps` context of the next steps see them like the outputs of a step that ran
+type stepOverride struct {
+	Step       *model.Step
+	RunContext *RunContext
+	mock       *StepMock
+	env        map[string]string
+}
+
+// stepRef returns the reference of the step in the job of the run context, <job-id>.<step-id>
+func stepRef(rc *RunContext, stepModel *model.Step) string {
+	return rc.Run.JobID + "." + stepModel.ID
+}
+
+// overrideStep returns the step replacing the step of the job when it's skipped or mocked, nil when it runs.
+// The steps of composite actions run like the actions.
+func (rc *RunContext) overrideStep(stepModel *model.Step) step {
+	if rc.Config == nil || rc.Run == nil || rc.Parent != nil {
+		return nil
+	}
+	ref := stepRef(rc, stepModel)
+	onlySteps := slices.ContainsFunc(rc.Config.OnlySteps, func(only string) bool {
+		return strings.HasPrefix(only, rc.Run.JobID+".")
+	})
+	if slices.Contains(rc.Config.SkipSteps, ref) || (onlySteps && !slices.Contains(rc.Config.OnlySteps, ref)) {
+		return &stepOverride{Step: stepModel, RunContext: rc}
+	}
+	if mock, ok := rc.Config.MockSteps[ref]; ok {
+		return &stepOverride{Step: stepModel, RunContext: rc, mock: &mock}
+	}
+	return nil
+}
+
+func (so *stepOverride) pre() common.Executor {
+	return func(_ context.Context) error {
+		return nil
+	}
+}
+
+func (so *stepOverride) main() common.Executor {
+	if so.mock == nil {
+		return so.skip()
+	}
+	so.env = map[string]string{}
+	return runStepExecutor(so, stepStageMain, func(ctx context.Context) error {
+		rc := so.RunContext
+		outputs := rc.StepResults[so.Step.ID].Outputs
+		for k, v := range so.mock.Outputs {
+			outputs[k] = v
+		}
+		common.Logger(ctx).Infof("  \U0001F3AD  Mocked with %d outputs and the outcome %s", len(so.mock.Outputs), so.outcome())
+		if so.outcome() == "failure" {
+			return fmt.Errorf("the step '%s' is mocked to fail", so.Step.ID)
+		}
+		return nil
+	})
+}
+
+func (so *stepOverride) post() common.Executor {
+	return func(_ context.Context) error {
+		return nil
+	}
+}
+
+// skip records the skipped result of the step without evaluating its `if`
+func (so *stepOverride) skip() common.Executor {
+	return func(ctx context.Context) error {
+		rc := so.RunContext
+		rc.CurrentStep = so.Step.ID
+		result := &model.StepResult{
+			Outcome:    model.StepStatusSkipped,
+			Conclusion: model.StepStatusSkipped,
+			Outputs:    map[string]string{},
+		}
+		rc.StepResults[so.Step.ID] = result
+		stepString := rc.ExprEval.Interpolate(ctx, so.Step.String())
+		common.Logger(ctx).WithField("stepResult", result.Outcome).Infof("⏭  Skipping step '%s', it's excluded from the run", stepString)
+		rc.history.addStep(so.Step.ID, stepString, stepStageMain, result, false)
+		rc.stepFinished(ctx, so.Step, stepString, stepStageMain, result)
+		return nil
+	}
+}
+
+func (so *stepOverride) outcome() string {
+	if so.mock.Outcome == "" {
+		return "success"
+	}
+	return so.mock.Outcome
+}
+
+func (so *stepOverride) getRunContext() *RunContext {
+	return so.RunContext
+}
+
+func (so *stepOverride) getGithubContext(ctx context.Context) *model.GithubContext {
+	return so.getRunContext().getGithubContext(ctx)
+}
+
+func (so *stepOverride) getStepModel() *model.Step {
+	return so.Step
+}
+
+func (so *stepOverride) getEnv() *map[string]string {
+	return &so.env
+}
+
+func (so *stepOverride) getIfExpression(_ context.Context, _ stepStage) string {
+	return so.Step.If.Value
+}
@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+func TestOverrideStep(t *testing.T) {
+	config := &Config{
+		SkipSteps: []string{"build.apply"},
+		OnlySteps: []string{"test.unit", "test.lint"},
+		MockSteps: map[string]StepMock{"build.plan": {Outputs: map[string]string{"changes": "true"}}},
+	}
+	sf := &stepFactoryImpl{}
+	for _, tt := range []struct {
+		job, step string
+		skipped   bool
+		mocked    bool
+	}{
+		{job: "build", step: "apply", skipped: true},
+		{job: "build", step: "plan", mocked: true},
+		{job: "build", step: "init"},
+		{job: "test", step: "unit"},
+		{job: "test", step: "integration", skipped: true},
+		// the other jobs run all their steps
+		{job: "deploy", step: "integration"},
+	} {
+		rc := &RunContext{Config: config, Run: &model.Run{JobID: tt.job}}
+		s, err := sf.newStep(&model.Step{ID: tt.step, Run: "make"}, rc)
+		require.NoError(t, err)
+		override, ok := s.(*stepOverride)
+		assert.Equal(t, tt.skipped || tt.mocked, ok, tt.job+"."+tt.step)
+		if ok {
+			assert.Equal(t, tt.mocked, override.mock != nil, tt.job+"."+tt.step)
+		}
+	}
+
+	// the steps of composite actions aren't overridden
+	rc := &RunContext{Config: config, Run: &model.Run{JobID: "build"}, Parent: &RunContext{}}
+	s, err := sf.newStep(&model.Step{ID: "apply", Run: "make"}, rc)
+	require.NoError(t, err)
+	assert.IsType(t, &stepRun{}, s)
+}
+
+func TestStepOverride(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		step    string
+		mock    *StepMock
+		outcome string
+		outputs map[string]string
+		err     string
+	}{
+		{name: "skipped", step: "{id: plan, run: make plan}", outcome: "skipped"},
+		{name: "mocked", step: "{id: plan, run: make plan}", mock: &StepMock{Outputs: map[string]string{"changes": "true"}}, outcome: "success", outputs: map[string]string{"changes": "true"}},
+		{name: "mocked failure", step: "{id: plan, run: make plan}", mock: &StepMock{Outcome: "failure"}, outcome: "failure", err: "the step 'plan' is mocked to fail"},
+		{name: "mocked failure continues on error", step: "{id: plan, run: make plan, continue-on-error: true}", mock: &StepMock{Outcome: "failure"}, outcome: "failure"},
+		{name: "mocked step with false if", step: "{id: plan, run: make plan, if: 'false'}", mock: &StepMock{}, outcome: "skipped"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := &containerMock{}
+			cm.On("Copy", "/var/run/gha", mock.AnythingOfType("[]*container.FileEntry")).Return(func(_ context.Context) error { return nil })
+			cm.On("UpdateFromEnv", mock.AnythingOfType("string"), mock.AnythingOfType("*map[string]string")).Return(func(_ context.Context) error { return nil })
+			cm.On("GetContainerArchive", mock.Anything, mock.AnythingOfType("string")).Return(io.NopCloser(&bytes.Buffer{}), nil)
+
+			var stepModel model.Step
+			require.NoError(t, yaml.Unmarshal([]byte(tt.step), &stepModel))
+			rc := &RunContext{
+				Config:       &Config{},
+				Run:          &model.Run{JobID: "build", Workflow: &model.Workflow{Jobs: map[string]*model.Job{"build": {}}}},
+				StepResults:  map[string]*model.StepResult{},
+				JobContainer: cm,
+			}
+			ctx := context.Background()
+			rc.ExprEval = rc.NewExpressionEvaluator(ctx)
+
+			err := (&stepOverride{Step: &stepModel, RunContext: rc, mock: tt.mock}).main()(ctx)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			require.Contains(t, rc.StepResults, "plan")
+			assert.Equal(t, tt.outcome, rc.StepResults["plan"].Outcome.String())
+			if tt.outputs == nil {
+				tt.outputs = map[string]string{}
+			}
+			assert.Equal(t, tt.outputs, rc.StepResults["plan"].Outputs)
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/russross/blackfriday/v2"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
+)
+
+const (
+	summaryMarkdownFile = "summary.md"
+	summaryHTMLFile     = "summary.html"
+)
+
+type jobSummariesContextKey string
+
+const jobSummariesContextKeyVal = jobSummariesContextKey("runner.jobSummaries")
+
+// jobSummaries collects the jobs of a run in plan order, to report their step summaries at its end
+type jobSummaries struct {
+	mu   sync.Mutex
+	jobs []*RunContext
+}
+
+func jobSummariesFromContext(ctx context.Context) *jobSummaries {
+	if summaries, ok := ctx.Value(jobSummariesContextKeyVal).(*jobSummaries); ok {
+		return summaries
+	}
+	return nil
+}
+
+func (s *jobSummaries) add(rc *RunContext) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, rc)
+}
+
+// newJobSummariesExecutor writes the step summaries of the jobs to the report directory once the
+// run finished, also if it failed
+func newJobSummariesExecutor(dir string, executor common.Executor) common.Executor {
+	return func(ctx context.Context) error {
+		summaries := &jobSummaries{}
+		err := executor(context.WithValue(ctx, jobSummariesContextKeyVal, summaries))
+		if writeErr := summaries.write(dir); writeErr != nil {
+			return errors.Join(err, fmt.Errorf("unable to write the job summaries: %w", writeErr))
+		}
+		log.Infof("\U0001F4DD  Job summaries written to %s", filepath.Join(dir, summaryMarkdownFile))
+		return err
+	}
+}
+
+// markdown returns the summaries with a section per job and matrix leg, like the run page of GitHub
+func (s *jobSummaries) markdown() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := &strings.Builder{}
+	b.WriteString("# Job summaries\n\n")
+	written := false
+	for _, rc := range s.jobs {
+		if len(rc.summaries) == 0 && rc.deploymentURL == "" {
+			continue
+		}
+		written = true
+		fmt.Fprintf(b, "## %s\n\n", summaryTitle(rc))
+		if rc.deploymentURL != "" {
+			fmt.Fprintf(b, "\U0001F310 Deployed to **%s**: %s\n\n", rc.DeploymentEnvironment.Name, rc.mask(rc.deploymentURL))
+		}
+		for _, summary := range rc.summaries {
+			b.WriteString(strings.TrimRight(summary, "\n"))
+			b.WriteString("\n\n")
+		}
+	}
+	if !written {
+		b.WriteString("No job wrote a summary to `GITHUB_STEP_SUMMARY`.\n")
+	}
+	return b.String()
+}
+
+func summaryTitle(rc *RunContext) string {
+	title := fmt.Sprintf("%s / %s", rc.Run.Workflow.Name, rc.JobName)
+	if rc.caller != nil {
+		title = fmt.Sprintf("%s / %s", rc.caller.runContext.JobName, title)
+	}
+	if len(rc.Matrix) > 0 {
+		title = fmt.Sprintf("%s (%s)", title, matrixString(rc.Matrix))
+	}
+	return title
+}
+
+// matrixString returns the values of the matrix sorted by key, e.g. "node: 20, os: ubuntu"
+func matrixString(matrix map[string]interface{}) string {
+	keys := make([]string, 0, len(matrix))
+	for k := range matrix {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	values := make([]string, 0, len(keys))
+	for _, k := range keys {
+		values = append(values, fmt.Sprintf("%s: %v", k, matrix[k]))
+	}
+	return strings.Join(values, ", ")
+}
+
+// summaryHTMLHeader and summaryHTMLFooter wrap the rendered summaries in summary.html
+const summaryHTMLHeader = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Job summaries</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; line-height: 1.5; max-width: 1012px; margin: 32px auto; padding: 0 16px; color: #1f2328; }
+h2 { border-bottom: 1px solid #d1d9e0; padding-bottom: .3em; margin-top: 32px; }
+table { border-collapse: collapse; margin: 16px 0; }
+th, td { border: 1px solid #d1d9e0; padding: 6px 13px; }
+tr:nth-child(2n) { background-color: #f6f8fa; }
+code, pre { background-color: #f6f8fa; border-radius: 6px; font-family: ui-monospace, SFMono-Regular, Menlo, monospace; font-size: 85%; }
+code { padding: .2em .4em; }
+pre { padding: 16px; overflow: auto; }
+pre code { padding: 0; }
+</style>
+</head>
+<body>
+`
+
+const summaryHTMLFooter = `</body>
+</html>
+`
+
+func (s *jobSummaries) write(dir string) error {
+	markdown := s.markdown()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, summaryMarkdownFile), []byte(markdown), 0o644); err != nil {
+		return err
+	}
+
+	// the summaries are markdown written by the steps of the workflow, any action can write them: their raw
+	// html is dropped and their links are kept only with safe protocols, so they can't run scripts
+	renderer := blackfriday.NewHTMLRenderer(blackfriday.HTMLRendererParameters{
+		Flags: blackfriday.CommonHTMLFlags | blackfriday.SkipHTML | blackfriday.Safelink,
+	})
+	rendered := blackfriday.Run([]byte(markdown), blackfriday.WithRenderer(renderer),
+		blackfriday.WithExtensions(blackfriday.CommonExtensions|blackfriday.AutoHeadingIDs))
+	html := &bytes.Buffer{}
+	html.WriteString(summaryHTMLHeader)
+	html.Write(rendered)
+	html.WriteString(summaryHTMLFooter)
+	return os.WriteFile(filepath.Join(dir, summaryHTMLFile), html.Bytes(), 0o644)
+}
@@ -0,0 +1,142 @@
+package runner
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/container"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+)
+
+func summaryArchive(t *testing.T, content string) io.ReadCloser {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "SUMMARY.md", Mode: 0o666, Size: int64(len(content))}))
+	_, err := tw.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	return io.NopCloser(buf)
+}
+
+func TestProcessRunnerSummaryCommand(t *testing.T) {
+	ctx := context.Background()
+	cm := &containerMock{}
+	cm.On("GetContainerArchive", ctx, "/var/run/gha/workflow/SUMMARY.md").Return(summaryArchive(t, "### Tests\n\n| passed | failed |\n|---|---|\n| 10 | 0 |\n"), nil).Once()
+	cm.On("Copy", "/var/run/gha", mock.Anything).Return(func(_ context.Context) error { return nil }).Once()
+
+	job := &RunContext{JobContainer: cm, Config: &Config{}}
+	composite := &RunContext{JobContainer: cm, Config: &Config{}, Parent: job}
+	require.NoError(t, processRunnerSummaryCommand(ctx, "workflow/SUMMARY.md", composite))
+
+	assert.Equal(t, []string{"### Tests\n\n| passed | failed |\n|---|---|\n| 10 | 0 |\n"}, job.summaries)
+	assert.Empty(t, composite.summaries)
+	// the file is cleared, the step of the composite action reads it next
+	assert.Equal(t, []*container.FileEntry{{Name: "workflow/SUMMARY.md", Mode: 0o666}}, cm.Calls[1].Arguments.Get(1))
+	cm.AssertExpectations(t)
+
+	cm.On("GetContainerArchive", ctx, "/var/run/gha/workflow/SUMMARY.md").Return(io.NopCloser(&bytes.Buffer{}), nil).Once()
+	require.NoError(t, processRunnerSummaryCommand(ctx, "workflow/SUMMARY.md", job))
+	assert.Len(t, job.summaries, 1)
+
+	// the secrets are scrubbed from the summaries, like GitHub does
+	cm.On("GetContainerArchive", ctx, "/var/run/gha/workflow/SUMMARY.md").Return(summaryArchive(t, "token: s3cr3t, key: hidden\n"), nil).Once()
+	cm.On("Copy", "/var/run/gha", mock.Anything).Return(func(_ context.Context) error { return nil }).Once()
+	secret := &RunContext{JobContainer: cm, Config: &Config{Secrets: map[string]string{"TOKEN": "s3cr3t"}}, Masks: []string{"hidden"}}
+	require.NoError(t, processRunnerSummaryCommand(ctx, "workflow/SUMMARY.md", secret))
+	assert.Equal(t, []string{"token: ***, key: ***\n"}, secret.summaries)
+}
+
+func TestJobSummaries(t *testing.T) {
+	workflow := &model.Workflow{Name: "CI"}
+	summaries := &jobSummaries{}
+	summaries.add(&RunContext{Run: &model.Run{Workflow: workflow}, JobName: "lint"})
+	summaries.add(&RunContext{Run: &model.Run{Workflow: workflow}, JobName: "test", Matrix: map[string]interface{}{"os": "ubuntu", "node": 20},
+		summaries: []string{"### Tests\n\n| passed | failed |\n|---|---|\n| 10 | 0 |\n", "Coverage: 80%"}})
+	summaries.add(&RunContext{Run: &model.Run{Workflow: workflow}, Config: &Config{}, JobName: "deploy", summaries: []string{"Deployed :rocket:\n"},
+		DeploymentEnvironment: &model.DeploymentEnvironment{Name: "production"}, deploymentURL: "https://example.com"})
+	// the url of the environment is reported without a summary too
+	summaries.add(&RunContext{Run: &model.Run{Workflow: workflow}, Config: &Config{}, JobName: "preview",
+		DeploymentEnvironment: &model.DeploymentEnvironment{Name: "preview"}, deploymentURL: "https://preview.example.com"})
+
+	assert.Equal(t, `# Job summaries
+
+## CI / test (node: 20, os: ubuntu)
+
+### Tests
+
+| passed | failed |
+|---|---|
+| 10 | 0 |
+
+Coverage: 80%
+
+## CI / deploy
+
+🌐 Deployed to **production**: https://example.com
+
+Deployed :rocket:
+
+## CI / preview
+
+🌐 Deployed to **preview**: https://preview.example.com
+
+`, summaries.markdown())
+
+	dir := filepath.Join(t.TempDir(), "report")
+	require.NoError(t, summaries.write(dir))
+	html, err := os.ReadFile(filepath.Join(dir, "summary.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(html), "<h2 id=\"ci-test-node-20-os-ubuntu\">CI / test (node: 20, os: ubuntu)</h2>")
+	assert.Contains(t, string(html), "<td>10</td>")
+	assert.Contains(t, string(html), `<a href="https://example.com">https://example.com</a>`)
+	markdown, err := os.ReadFile(filepath.Join(dir, "summary.md"))
+	require.NoError(t, err)
+	assert.Equal(t, summaries.markdown(), string(markdown))
+
+	// the summaries of the actions can't run scripts in the report
+	injected := &jobSummaries{}
+	injected.add(&RunContext{Run: &model.Run{Workflow: workflow}, JobName: "notify",
+		summaries: []string{"<script>alert(1)</script>\n\nSent <img src=x onerror=alert(1)> [details](javascript:alert(1)) [run](https://example.com/run)\n"}})
+	require.NoError(t, injected.write(dir))
+	html, err = os.ReadFile(filepath.Join(dir, "summary.html"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(html), "<script>")
+	assert.NotContains(t, string(html), "onerror")
+	assert.NotContains(t, string(html), `href="javascript:`)
+	assert.Contains(t, string(html), `<a href="https://example.com/run">run</a>`)
+
+	// the url of the environment is written without the secrets it contains
+	masked := &jobSummaries{}
+	masked.add(&RunContext{Run: &model.Run{Workflow: workflow}, Config: &Config{Secrets: map[string]string{"TOKEN": "s3cr3t"}}, JobName: "deploy",
+		DeploymentEnvironment: &model.DeploymentEnvironment{Name: "production"}, deploymentURL: "https://example.com/?token=s3cr3t"})
+	assert.Contains(t, masked.markdown(), "🌐 Deployed to **production**: https://example.com/?token=***\n")
+	assert.NotContains(t, masked.markdown(), "s3cr3t")
+
+	assert.Contains(t, (&jobSummaries{}).markdown(), "No job wrote a summary")
+}
+
+func TestJobSummariesExecutor(t *testing.T) {
+	dir := t.TempDir()
+	executor := newJobSummariesExecutor(dir, func(ctx context.Context) error {
+		summaries := jobSummariesFromContext(ctx)
+		require.NotNil(t, summaries)
+		summaries.add(&RunContext{Run: &model.Run{Workflow: &model.Workflow{Name: "CI"}}, JobName: "build", summaries: []string{"built"}})
+		return assert.AnError
+	})
+
+	// the summaries of failed runs are written too
+	assert.ErrorIs(t, executor(common.WithDryrun(context.Background(), false)), assert.AnError)
+	markdown, err := os.ReadFile(filepath.Join(dir, "summary.md"))
+	require.NoError(t, err)
+	assert.Contains(t, string(markdown), "## CI / build\n\nbuilt\n")
+}
@@ -0,0 +1,77 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+	"github.com/Leapfrog-DevOps/gha/pkg/tracing"
+)
+
+// newTraceExecutor records the spans of the run in a trace, which is written to the trace file and
+// exported to the OTLP endpoint of the config once the run finished
+func newTraceExecutor(config *Config, plan *model.Plan, executor common.Executor) common.Executor {
+	return func(ctx context.Context) error {
+		if common.Dryrun(ctx) {
+			return executor(ctx)
+		}
+
+		workflows := []string{}
+		for _, stage := range plan.Stages {
+			for _, r := range stage.Runs {
+				if !slices.Contains(workflows, r.Workflow.Name) {
+					workflows = append(workflows, r.Workflow.Name)
+				}
+			}
+		}
+		tracer := tracing.NewTracer("gha")
+		ctx, span := tracing.Start(tracing.WithTracer(ctx, tracer), "gha "+config.EventName,
+			tracing.String("gha.event", config.EventName),
+			tracing.String("gha.workflows", strings.Join(workflows, ",")),
+			tracing.String("gha.workdir", config.Workdir),
+		)
+		err := executor(ctx)
+		span.SetError(err)
+		span.End()
+
+		if config.TraceFile != "" {
+			if traceErr := tracer.WriteFile(config.TraceFile); traceErr != nil {
+				err = errors.Join(err, fmt.Errorf("unable to write the trace: %w", traceErr))
+			} else {
+				log.Infof("\U0001F50D  Trace %s written to %s", tracer.TraceID(), config.TraceFile)
+			}
+		}
+		if config.TraceEndpoint != nil {
+			// the run may have been cancelled, the spans are exported anyway within the timeout of the endpoint
+			if traceErr := tracer.Export(context.WithoutCancel(ctx), config.TraceEndpoint); traceErr != nil {
+				log.Warnf("Unable to export the trace to %s: %v", config.TraceEndpoint.URL, traceErr)
+			} else {
+				log.Infof("\U0001F50D  Trace %s exported to %s", tracer.TraceID(), config.TraceEndpoint.URL)
+			}
+		}
+		return err
+	}
+}
+
+// spanAttributes returns the attributes of the spans of the job, with an attribute per value of its matrix
+func (rc *RunContext) spanAttributes() []tracing.Attribute {
+	attrs := []tracing.Attribute{
+		tracing.String("gha.workflow", rc.Run.Workflow.Name),
+		tracing.String("gha.job.id", rc.JobName),
+		tracing.String("gha.job.name", rc.String()),
+	}
+	if rc.caller != nil {
+		attrs = append(attrs, tracing.String("gha.job.caller", rc.caller.runContext.JobName))
+	}
+	for _, k := range slices.Sorted(maps.Keys(rc.Matrix)) {
+		attrs = append(attrs, tracing.Any("gha.matrix."+k, rc.Matrix[k]))
+	}
+	return attrs
+}
@@ -0,0 +1,49 @@
+package runner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+	"github.com/Leapfrog-DevOps/gha/pkg/tracing"
+)
+
+func TestTraceExecutor(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	traceFile := filepath.Join(t.TempDir(), "traces", "trace.json")
+	config := &Config{
+		EventName:     "push",
+		TraceFile:     traceFile,
+		TraceEndpoint: &tracing.Endpoint{URL: server.URL + "/v1/traces"},
+	}
+	workflow := &model.Workflow{Name: "CI", Jobs: map[string]*model.Job{"test": {}}}
+	plan := &model.Plan{Stages: []*model.Stage{{Runs: []*model.Run{{Workflow: workflow, JobID: "test"}}}}}
+	executor := newTraceExecutor(config, plan, func(ctx context.Context) error {
+		rc := &RunContext{Config: config, Run: plan.Stages[0].Runs[0], Name: "test-2", JobName: "test", Matrix: map[string]interface{}{"os": "ubuntu", "node": 20}}
+		_, span := tracing.Start(ctx, rc.String(), rc.spanAttributes()...)
+		span.End()
+		return assert.AnError
+	})
+
+	assert.ErrorIs(t, executor(context.Background()), assert.AnError)
+	assert.Equal(t, 1, requests)
+	trace, err := os.ReadFile(traceFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(trace), `"name":"gha push"`)
+	assert.Contains(t, string(trace), `{"key":"gha.workflows","value":{"stringValue":"CI"}}`)
+	assert.Contains(t, string(trace), `"status":{"code":2,"message":"assert.AnError general error for testing"}`)
+	assert.Contains(t, string(trace), `"name":"CI/test-2"`)
+	assert.Contains(t, string(trace), `{"key":"gha.job.id","value":{"stringValue":"test"}},{"key":"gha.job.name","value":{"stringValue":"CI/test-2"}},{"key":"gha.matrix.node","value":{"intValue":"20"}},{"key":"gha.matrix.os","value":{"stringValue":"ubuntu"}}`)
+}
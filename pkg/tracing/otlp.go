@@ -0,0 +1,235 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	scopeName = "github.com/Leapfrog-DevOps/gha"
+
+	// the codes of the status of a span, see https://opentelemetry.io/docs/specs/otel/trace/api/#set-status
+	statusCodeOK    = 1
+	statusCodeError = 2
+
+	spanKindInternal = 1
+
+	// an unreachable collector doesn't hold the end of the run for longer
+	defaultExportTimeout = 5 * time.Second
+)
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+// otlpValue is an AnyValue, the int64 values are strings in OTLP/JSON
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func otlpAttributes(attrs []Attribute) []otlpAttribute {
+	converted := make([]otlpAttribute, 0, len(attrs))
+	for _, attr := range attrs {
+		value := otlpValue{}
+		switch v := attr.Value.(type) {
+		case bool:
+			value.BoolValue = &v
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			value.IntValue = &s
+		case float64:
+			value.DoubleValue = &v
+		case string:
+			value.StringValue = &v
+		default:
+			s := fmt.Sprint(v)
+			value.StringValue = &s
+		}
+		converted = append(converted, otlpAttribute{Key: attr.Key, Value: value})
+	}
+	return converted
+}
+
+// WriteJSON writes the ended spans as an OTLP/JSON ExportTraceServiceRequest
+func (t *Tracer) WriteJSON(w io.Writer) error {
+	spans := make([]otlpSpan, 0)
+	for _, s := range t.Spans() {
+		s.mu.Lock()
+		span := otlpSpan{
+			TraceID:           t.TraceID(),
+			SpanID:            hex.EncodeToString(s.SpanID[:]),
+			Name:              s.Name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.EndTime.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.Attributes),
+			Status:            otlpStatus{Code: statusCodeOK},
+		}
+		if s.ParentSpanID != [8]byte{} {
+			span.ParentSpanID = hex.EncodeToString(s.ParentSpanID[:])
+		}
+		if s.Failed {
+			span.Status = otlpStatus{Code: statusCodeError, Message: s.StatusMessage}
+		}
+		s.mu.Unlock()
+		spans = append(spans, span)
+	}
+
+	return json.NewEncoder(w).Encode(otlpTraces{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource:   otlpResource{Attributes: otlpAttributes(t.resource)},
+			ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: scopeName}, Spans: spans}},
+		}},
+	})
+}
+
+// WriteFile writes the ended spans to the file as OTLP/JSON, on a single line like the file
+// exporter of the OpenTelemetry Collector
+func (t *Tracer) WriteFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return t.WriteJSON(file)
+}
+
+// Endpoint is the OTLP HTTP endpoint of the traces of a collector
+type Endpoint struct {
+	URL     string
+	Headers map[string]string
+	Timeout time.Duration // the timeout of the export, 5 seconds if it's not set
+}
+
+// EndpointFromEnv returns the endpoint configured with the OTEL_EXPORTER_OTLP_* environment
+// variables of the OpenTelemetry SDKs, nil if there is none
+func EndpointFromEnv(getenv func(string) string) *Endpoint {
+	endpoint := &Endpoint{URL: getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")}
+	if endpoint.URL == "" {
+		base := getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if base == "" {
+			return nil
+		}
+		endpoint.URL = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	headers := getenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS")
+	if headers == "" {
+		headers = getenv("OTEL_EXPORTER_OTLP_HEADERS")
+	}
+	endpoint.Headers = parseHeaders(headers)
+	timeout := getenv("OTEL_EXPORTER_OTLP_TRACES_TIMEOUT")
+	if timeout == "" {
+		timeout = getenv("OTEL_EXPORTER_OTLP_TIMEOUT")
+	}
+	// the timeout is in milliseconds
+	if ms, err := strconv.Atoi(timeout); err == nil && ms > 0 {
+		endpoint.Timeout = time.Duration(ms) * time.Millisecond
+	}
+	return endpoint
+}
+
+// parseHeaders parses a list of headers like "api-key=key,other=value", the values are URL encoded
+func parseHeaders(headers string) map[string]string {
+	parsed := map[string]string{}
+	for _, header := range strings.Split(headers, ",") {
+		k, v, ok := strings.Cut(header, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			continue
+		}
+		if unescaped, err := url.QueryUnescape(v); err == nil {
+			v = unescaped
+		}
+		parsed[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return parsed
+}
+
+// Export sends the ended spans to the endpoint as OTLP/JSON, it gives up after the timeout of the endpoint
+func (t *Tracer) Export(ctx context.Context, endpoint *Endpoint) error {
+	timeout := endpoint.Timeout
+	if timeout <= 0 {
+		timeout = defaultExportTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	body := &bytes.Buffer{}
+	if err := t.WriteJSON(body); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range endpoint.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("the endpoint %s responded with %s: %s", endpoint.URL, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
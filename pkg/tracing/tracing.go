@@ -0,0 +1,200 @@
+// Package tracing records the spans of a run of gha and exports them as OTLP/JSON, to a file or to
+// the OTLP HTTP endpoint of a collector.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
+)
+
+type tracerContextKey string
+
+const tracerContextKeyVal = tracerContextKey("tracing.tracer")
+
+type spanContextKey string
+
+const spanContextKeyVal = spanContextKey("tracing.span")
+
+// Tracer collects the ended spans of a trace
+type Tracer struct {
+	mu       sync.Mutex
+	traceID  [16]byte
+	resource []Attribute
+	spans    []*Span
+}
+
+// NewTracer returns a tracer of a new trace, the attributes describe the resource of its spans
+func NewTracer(serviceName string, resource ...Attribute) *Tracer {
+	t := &Tracer{resource: append([]Attribute{String("service.name", serviceName)}, resource...)}
+	_, _ = rand.Read(t.traceID[:])
+	return t
+}
+
+// TraceID returns the ID of the trace in hex, like in the exported spans
+func (t *Tracer) TraceID() string {
+	return hex.EncodeToString(t.traceID[:])
+}
+
+// Spans returns the ended spans in the order they ended
+func (t *Tracer) Spans() []*Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*Span{}, t.spans...)
+}
+
+// WithTracer adds the tracer to the context, the spans started with the context are recorded in it
+func WithTracer(ctx context.Context, t *Tracer) context.Context {
+	return context.WithValue(ctx, tracerContextKeyVal, t)
+}
+
+func tracerFromContext(ctx context.Context) *Tracer {
+	if t, ok := ctx.Value(tracerContextKeyVal).(*Tracer); ok {
+		return t
+	}
+	return nil
+}
+
+// SpanFromContext returns the running span of the context, nil if there is none
+func SpanFromContext(ctx context.Context) *Span {
+	if s, ok := ctx.Value(spanContextKeyVal).(*Span); ok {
+		return s
+	}
+	return nil
+}
+
+// Span is a timed operation of the trace, its methods do nothing if it is nil, i.e. if the
+// context it was started with has no tracer
+type Span struct {
+	mu            sync.Mutex
+	tracer        *Tracer
+	Name          string
+	SpanID        [8]byte
+	ParentSpanID  [8]byte // zero for the root span
+	StartTime     time.Time
+	EndTime       time.Time
+	Attributes    []Attribute
+	Failed        bool
+	StatusMessage string
+}
+
+// Start starts a span, a child of the running span of the context. The returned context has the
+// new span as its running span.
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	t := tracerFromContext(ctx)
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{
+		tracer:     t,
+		Name:       name,
+		StartTime:  time.Now(),
+		Attributes: attrs,
+	}
+	_, _ = rand.Read(s.SpanID[:])
+	if parent := SpanFromContext(ctx); parent != nil {
+		s.ParentSpanID = parent.SpanID
+	}
+	return context.WithValue(ctx, spanContextKeyVal, s), s
+}
+
+// SetAttributes adds the attributes to the span, or replaces the ones with the same keys
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, attr := range attrs {
+		replaced := false
+		for i := range s.Attributes {
+			if s.Attributes[i].Key == attr.Key {
+				s.Attributes[i] = attr
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			s.Attributes = append(s.Attributes, attr)
+		}
+	}
+}
+
+// SetError marks the span as failed with the error, if it is not nil
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Failed = true
+	s.StatusMessage = err.Error()
+}
+
+// End ends the span and records it in the trace
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.EndTime.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.EndTime = time.Now()
+	s.mu.Unlock()
+
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.tracer.spans = append(s.tracer.spans, s)
+}
+
+// NewSpanExecutor runs the executor in a span, which fails if the executor returns an error
+func NewSpanExecutor(name string, executor common.Executor, attrs ...Attribute) common.Executor {
+	return func(ctx context.Context) error {
+		ctx, span := Start(ctx, name, attrs...)
+		defer span.End()
+		err := executor(ctx)
+		span.SetError(err)
+		return err
+	}
+}
+
+// Attribute is a key and a value of a span or resource, the value is a string, an int64, a
+// float64 or a bool
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key string, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: int64(value)}
+}
+
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Any returns an attribute of the value, e.g. of a matrix value, the values of other types than
+// strings, numbers and bools are formatted as strings
+func Any(key string, value interface{}) Attribute {
+	switch v := value.(type) {
+	case string, int64, float64, bool:
+		return Attribute{Key: key, Value: v}
+	case int:
+		return Int(key, v)
+	case float32:
+		return Attribute{Key: key, Value: float64(v)}
+	default:
+		return String(key, fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,173 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartWithoutTracer(t *testing.T) {
+	ctx, span := Start(context.Background(), "span")
+	assert.Nil(t, span)
+	assert.Nil(t, SpanFromContext(ctx))
+
+	// the methods of a nil span do nothing
+	span.SetAttributes(String("key", "value"))
+	span.SetError(errors.New("error"))
+	span.End()
+
+	err := NewSpanExecutor("span", func(_ context.Context) error {
+		return errors.New("error")
+	})(ctx)
+	assert.EqualError(t, err, "error")
+}
+
+func TestSpans(t *testing.T) {
+	tracer := NewTracer("gha")
+	ctx, root := Start(WithTracer(context.Background(), tracer), "root", String("event", "push"))
+	err := NewSpanExecutor("child", func(ctx context.Context) error {
+		SpanFromContext(ctx).SetAttributes(Bool("pulled", true), String("event", "pull_request"))
+		return errors.New("pull failed")
+	}, String("event", "push"))(ctx)
+	assert.Error(t, err)
+	root.End()
+	root.End()
+
+	spans := tracer.Spans()
+	require.Len(t, spans, 2)
+	child := spans[0]
+	assert.Equal(t, "child", child.Name)
+	assert.Equal(t, root.SpanID, child.ParentSpanID)
+	assert.Equal(t, [8]byte{}, root.ParentSpanID)
+	assert.Equal(t, []Attribute{String("event", "pull_request"), Bool("pulled", true)}, child.Attributes)
+	assert.True(t, child.Failed)
+	assert.Equal(t, "pull failed", child.StatusMessage)
+	assert.False(t, root.Failed)
+	assert.False(t, child.EndTime.Before(child.StartTime))
+}
+
+func TestAny(t *testing.T) {
+	assert.Equal(t, Attribute{Key: "k", Value: int64(20)}, Any("k", 20))
+	assert.Equal(t, Attribute{Key: "k", Value: float64(1.5)}, Any("k", 1.5))
+	assert.Equal(t, Attribute{Key: "k", Value: true}, Any("k", true))
+	assert.Equal(t, Attribute{Key: "k", Value: "ubuntu"}, Any("k", "ubuntu"))
+	assert.Equal(t, Attribute{Key: "k", Value: "[1 2]"}, Any("k", []int{1, 2}))
+}
+
+func TestWriteJSON(t *testing.T) {
+	tracer := NewTracer("gha", String("host.name", "local"))
+	ctx, root := Start(WithTracer(context.Background(), tracer), "root")
+	_, child := Start(ctx, "child", String("s", "v"), Int("i", 2), Bool("b", true), Any("f", 0.5))
+	child.SetError(errors.New("failed"))
+	child.End()
+	root.End()
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, tracer.WriteJSON(buf))
+
+	var traces map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &traces))
+	resourceSpans := traces["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "gha"}},
+		map[string]interface{}{"key": "host.name", "value": map[string]interface{}{"stringValue": "local"}},
+	}, resourceSpans["resource"].(map[string]interface{})["attributes"])
+
+	scopeSpans := resourceSpans["scopeSpans"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"name": "github.com/Leapfrog-DevOps/gha"}, scopeSpans["scope"])
+	spans := scopeSpans["spans"].([]interface{})
+	require.Len(t, spans, 2)
+
+	first := spans[0].(map[string]interface{})
+	assert.Equal(t, "child", first["name"])
+	assert.Equal(t, tracer.TraceID(), first["traceId"])
+	assert.Len(t, first["traceId"], 32)
+	assert.Len(t, first["spanId"], 16)
+	assert.Equal(t, spans[1].(map[string]interface{})["spanId"], first["parentSpanId"])
+	assert.Equal(t, float64(1), first["kind"])
+	assert.IsType(t, "", first["startTimeUnixNano"])
+	assert.Equal(t, map[string]interface{}{"code": float64(2), "message": "failed"}, first["status"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"key": "s", "value": map[string]interface{}{"stringValue": "v"}},
+		map[string]interface{}{"key": "i", "value": map[string]interface{}{"intValue": "2"}},
+		map[string]interface{}{"key": "b", "value": map[string]interface{}{"boolValue": true}},
+		map[string]interface{}{"key": "f", "value": map[string]interface{}{"doubleValue": 0.5}},
+	}, first["attributes"])
+
+	second := spans[1].(map[string]interface{})
+	assert.NotContains(t, second, "parentSpanId")
+	assert.Equal(t, map[string]interface{}{"code": float64(1)}, second["status"])
+}
+
+func TestEndpointFromEnv(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(k string) string { return vars[k] }
+	}
+
+	assert.Nil(t, EndpointFromEnv(env(nil)))
+	assert.Equal(t, &Endpoint{URL: "http://localhost:4318/v1/traces", Headers: map[string]string{}},
+		EndpointFromEnv(env(map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318/"})))
+	assert.Equal(t, &Endpoint{URL: "http://collector/traces", Headers: map[string]string{"api-key": "a key", "team": "ci"}},
+		EndpointFromEnv(env(map[string]string{
+			"OTEL_EXPORTER_OTLP_ENDPOINT":        "http://localhost:4318",
+			"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://collector/traces",
+			"OTEL_EXPORTER_OTLP_HEADERS":         "api-key=a%20key, team=ci,invalid",
+		})))
+	assert.Equal(t, &Endpoint{URL: "http://localhost:4318/v1/traces", Headers: map[string]string{}, Timeout: 2 * time.Second},
+		EndpointFromEnv(env(map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318", "OTEL_EXPORTER_OTLP_TIMEOUT": "2000"})))
+}
+
+func TestExport(t *testing.T) {
+	var body []byte
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = io.ReadAll(r.Body)
+		if r.URL.Path != "/v1/traces" {
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tracer := NewTracer("gha")
+	_, span := Start(WithTracer(context.Background(), tracer), "root")
+	span.End()
+
+	endpoint := &Endpoint{URL: server.URL + "/v1/traces", Headers: map[string]string{"Api-Key": "key"}}
+	require.NoError(t, tracer.Export(context.Background(), endpoint))
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, "key", header.Get("Api-Key"))
+	expected := &bytes.Buffer{}
+	require.NoError(t, tracer.WriteJSON(expected))
+	assert.Equal(t, expected.String(), string(body))
+
+	endpoint.URL = server.URL + "/traces"
+	assert.ErrorContains(t, tracer.Export(context.Background(), endpoint), "404 Not Found: not found")
+}
+
+func TestExportTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	tracer := NewTracer("gha")
+	_, span := Start(WithTracer(context.Background(), tracer), "root")
+	span.End()
+
+	// an unresponsive endpoint doesn't block the end of the run
+	endpoint := &Endpoint{URL: server.URL + "/v1/traces", Timeout: 50 * time.Millisecond}
+	err := tracer.Export(context.Background(), endpoint)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,358 @@
+// Package tui draws a live dashboard of a run of gha in the terminal: the tree of the stages, jobs
+// and steps of the plan, and the log of the selected job.
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/term"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+	"github.com/Leapfrog-DevOps/gha/pkg/runner"
+)
+
+const (
+	statusQueued  = "queued"
+	statusRunning = "running"
+
+	// maxLogLines is the number of lines of the log of a job that are kept, the oldest lines are dropped
+	maxLogLines = 10000
+)
+
+// runNode is a job of the plan, with the legs of its matrix and the jobs of its reusable workflow
+type runNode struct {
+	workflow string
+	jobID    string
+	jobs     []*jobNode
+}
+
+type jobNode struct {
+	name      string
+	hook      *runner.HookJob
+	status    string // queued, running or the result of the job
+	cancelled bool
+	started   time.Time
+	finished  time.Time
+	steps     []*stepNode
+	log       []string
+}
+
+type stepNode struct {
+	key      string
+	name     string
+	status   string // running or the conclusion of the step
+	started  time.Time
+	finished time.Time
+}
+
+// Dashboard shows the progress of the run in the terminal. It implements the runner.Hooks to follow
+// the jobs and steps, and the runner.JobLoggerFactory to collect the logs of the jobs.
+type Dashboard struct {
+	runner.NoopHooks
+
+	mu       sync.Mutex
+	title    string
+	started  time.Time
+	stages   [][]*runNode
+	jobs     map[string]*jobNode
+	selected string // the name of the selected job, the running job started last until a job is selected
+	pinned   bool   // a job was selected with the keyboard
+	logOpen  bool   // the log of the selected job fills the screen
+	scroll   int    // the lines the open log is scrolled up from its end
+	message  string // the last line logged outside of the jobs
+	ended    time.Time
+	err      error
+
+	interrupts  int
+	cancel      func()
+	forceCancel func()
+
+	in       *os.File
+	out      *os.File
+	state    *term.State
+	logOut   io.Writer
+	logHooks log.LevelHooks
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// New returns a dashboard of the run titled title, that reads the keys from in and draws to out
+func New(in *os.File, out *os.File, title string) *Dashboard {
+	return &Dashboard{
+		title:   title,
+		started: time.Now(),
+		jobs:    map[string]*jobNode{},
+		in:      in,
+		out:     out,
+	}
+}
+
+// OnPlanStart lists the jobs of the plan as queued
+func (d *Dashboard) OnPlanStart(_ context.Context, plan *model.Plan) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.started = time.Now()
+	for _, stage := range plan.Stages {
+		runs := make([]*runNode, 0, len(stage.Runs))
+		for _, r := range stage.Runs {
+			runs = append(runs, &runNode{workflow: r.Workflow.Name, jobID: r.JobID})
+		}
+		d.stages = append(d.stages, runs)
+	}
+}
+
+// OnPlanEnd marks the run as ended
+func (d *Dashboard) OnPlanEnd(_ context.Context, _ *model.Plan, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.ended = time.Now()
+	d.err = err
+}
+
+// OnJobStart marks the job as running
+func (d *Dashboard) OnJobStart(_ context.Context, job *runner.HookJob) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	node := d.job(job)
+	node.status = statusRunning
+	node.started = time.Now()
+	if !d.pinned {
+		d.selected = node.name
+	}
+	return nil
+}
+
+// OnJobEnd sets the result of the job
+func (d *Dashboard) OnJobEnd(_ context.Context, job *runner.HookJob, result string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	node := d.job(job)
+	node.status = result
+	node.finished = time.Now()
+}
+
+// OnStepStart marks the step as running
+func (d *Dashboard) OnStepStart(_ context.Context, job *runner.HookJob, step *runner.HookStep) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	node := d.step(d.job(job), step)
+	node.status = statusRunning
+	node.started = time.Now()
+}
+
+// OnStepEnd sets the conclusion of the step
+func (d *Dashboard) OnStepEnd(_ context.Context, job *runner.HookJob, step *runner.HookStep, result *model.StepResult) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	node := d.step(d.job(job), step)
+	node.status = result.Conclusion.String()
+	node.finished = time.Now()
+}
+
+// job returns the node of the job, it is added to the tree the first time the job is seen by a hook
+func (d *Dashboard) job(job *runner.HookJob) *jobNode {
+	node := d.jobNode(job.Name)
+	if node.hook == nil {
+		node.hook = job
+		r := d.run(job)
+		r.jobs = append(r.jobs, node)
+	}
+	return node
+}
+
+// jobNode returns the node of the job with the name, the logs of a job may come before its hooks
+func (d *Dashboard) jobNode(name string) *jobNode {
+	node, ok := d.jobs[name]
+	if !ok {
+		node = &jobNode{name: name, status: statusQueued}
+		d.jobs[name] = node
+	}
+	return node
+}
+
+// run returns the job of the plan of the job, the job calling it for the jobs of a reusable workflow
+func (d *Dashboard) run(job *runner.HookJob) *runNode {
+	for _, runs := range d.stages {
+		for _, r := range runs {
+			if job.Caller != "" && r.jobID == job.Caller || job.Caller == "" && r.jobID == job.ID && r.workflow == job.Workflow {
+				return r
+			}
+		}
+	}
+	// a job that is not in the plan is shown in a stage of its own
+	r := &runNode{workflow: job.Workflow, jobID: job.ID}
+	d.stages = append(d.stages, []*runNode{r})
+	return r
+}
+
+func (d *Dashboard) step(job *jobNode, step *runner.HookStep) *stepNode {
+	key := fmt.Sprintf("%s/%s/%s", step.Stage, step.ID, step.Name)
+	for _, s := range job.steps {
+		if s.key == key {
+			return s
+		}
+	}
+	name := step.Name
+	if step.Stage != "main" {
+		name = fmt.Sprintf("%s%s %s", strings.ToUpper(step.Stage[:1]), step.Stage[1:], step.Name)
+	}
+	node := &stepNode{key: key, name: name, status: statusQueued}
+	job.steps = append(job.steps, node)
+	return node
+}
+
+// WithJobLogger returns a logger that collects the log lines of the jobs instead of printing them
+func (d *Dashboard) WithJobLogger() *log.Logger {
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	logger.SetLevel(log.GetLevel())
+	logger.SetFormatter(&logRecorder{dashboard: d})
+	return logger
+}
+
+// escapes matches the ANSI escape sequences of the output of the steps
+var escapes = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b[@-Z\\-_]`)
+
+// logRecorder records the log lines of a job, it formats the entries after the secrets are masked
+type logRecorder struct {
+	dashboard *Dashboard
+}
+
+func (r *logRecorder) Format(entry *log.Entry) ([]byte, error) {
+	job := strings.TrimSpace(fmt.Sprint(entry.Data["job"]))
+	for _, line := range strings.Split(strings.TrimSuffix(entry.Message, "\n"), "\n") {
+		// a carriage return overwrites the line, e.g. for progress bars
+		line = strings.TrimSuffix(line, "\r")
+		line = escapes.ReplaceAllString(line[strings.LastIndex(line, "\r")+1:], "")
+		if entry.Data["raw_output"] == true {
+			line = "  | " + line
+		} else if entry.Level == log.DebugLevel {
+			line = "[DEBUG] " + line
+		}
+		r.dashboard.addLog(job, line)
+	}
+	return nil, nil
+}
+
+func (d *Dashboard) addLog(job string, line string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	node := d.jobNode(job)
+	node.log = append(node.log, line)
+	if len(node.log) > maxLogLines {
+		node.log = node.log[len(node.log)-maxLogLines:]
+	}
+}
+
+// Levels returns the levels of the standard logger the dashboard shows, to be used as a hook
+func (d *Dashboard) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire shows the last line of the standard logger at the bottom of the dashboard
+func (d *Dashboard) Fire(entry *log.Entry) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.message = strings.TrimSpace(escapes.ReplaceAllString(entry.Message, ""))
+	if entry.Level <= log.WarnLevel {
+		d.message = fmt.Sprintf("%s: %s", strings.ToUpper(entry.Level.String()), d.message)
+	}
+	return nil
+}
+
+// cancelJob cancels the selected job
+func (d *Dashboard) cancelJob() {
+	node := d.jobs[d.selected]
+	if node == nil || node.hook == nil || node.status != statusRunning && node.status != statusQueued {
+		return
+	}
+	node.cancelled = true
+	node.hook.Cancel()
+	d.message = fmt.Sprintf("Cancelling %s", node.name)
+}
+
+// interrupt cancels the run like Ctrl+C does, the second interrupt cancels it without waiting for the cleanup
+func (d *Dashboard) interrupt() {
+	d.interrupts++
+	if d.interrupts == 1 {
+		d.message = "Cancelling the run, press q again to stop it right away"
+		if d.cancel != nil {
+			d.cancel()
+		}
+	} else if d.forceCancel != nil {
+		d.forceCancel()
+	}
+}
+
+// order returns the names of the jobs of the tree, from top to bottom
+func (d *Dashboard) order() []string {
+	var names []string
+	for _, runs := range d.stages {
+		for _, r := range runs {
+			for _, j := range r.jobs {
+				names = append(names, j.name)
+			}
+		}
+	}
+	return names
+}
+
+// key handles a key pressed by the user
+func (d *Dashboard) key(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch key {
+	case "q", "ctrl+c":
+		d.interrupt()
+	case "c":
+		d.cancelJob()
+	case "enter", "o":
+		d.logOpen = !d.logOpen
+		d.scroll = 0
+	case "esc":
+		d.logOpen = false
+		d.scroll = 0
+	case "up", "k":
+		if d.logOpen {
+			d.scroll++
+		} else {
+			d.move(-1)
+		}
+	case "down", "j":
+		if d.logOpen {
+			d.scroll = max(d.scroll-1, 0)
+		} else {
+			d.move(1)
+		}
+	case "pgup":
+		d.scroll += 10
+	case "pgdown":
+		d.scroll = max(d.scroll-10, 0)
+	case "end", "G":
+		d.scroll = 0
+	}
+}
+
+// move selects the job delta jobs below the selected job
+func (d *Dashboard) move(delta int) {
+	names := d.order()
+	if len(names) == 0 {
+		return
+	}
+	i := 0
+	for n, name := range names {
+		if name == d.selected {
+			i = n + delta
+		}
+	}
+	d.selected = names[min(max(i, 0), len(names)-1)]
+	d.pinned = true
+}
@@ -0,0 +1,138 @@
+package tui
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Leapfrog-DevOps/gha/pkg/common"
+	"github.com/Leapfrog-DevOps/gha/pkg/model"
+	"github.com/Leapfrog-DevOps/gha/pkg/runner"
+)
+
+func plain(lines []string) string {
+	return escapes.ReplaceAllString(strings.Join(lines, "\n"), "")
+}
+
+func newTestDashboard() (*Dashboard, *runner.HookJob, *runner.HookJob) {
+	ci := &model.Workflow{Name: "CI"}
+	d := New(nil, nil, "push")
+	d.OnPlanStart(context.Background(), &model.Plan{Stages: []*model.Stage{
+		{Runs: []*model.Run{{Workflow: ci, JobID: "build"}}},
+		{Runs: []*model.Run{{Workflow: ci, JobID: "test"}, {Workflow: ci, JobID: "deploy"}}},
+	}})
+	return d, &runner.HookJob{ID: "build", Name: "CI/build", Workflow: "CI"}, &runner.HookJob{ID: "test", Name: "CI/test-1", Workflow: "CI", Matrix: map[string]interface{}{"os": "ubuntu"}}
+}
+
+func TestDashboardTree(t *testing.T) {
+	ctx := context.Background()
+	d, build, test := newTestDashboard()
+
+	require.NoError(t, d.OnJobStart(ctx, build))
+	checkout := &runner.HookStep{ID: "0", Name: "actions/checkout@v4", Stage: "main"}
+	d.OnStepStart(ctx, build, checkout)
+	d.OnStepEnd(ctx, build, checkout, &model.StepResult{Outcome: model.StepStatusSuccess, Conclusion: model.StepStatusSuccess})
+	d.OnStepEnd(ctx, build, &runner.HookStep{ID: "1", Name: "actions/checkout@v4", Stage: "post"}, &model.StepResult{Outcome: model.StepStatusSkipped, Conclusion: model.StepStatusSkipped})
+	d.OnJobEnd(ctx, build, "success")
+	require.NoError(t, d.OnJobStart(ctx, test))
+	d.OnStepStart(ctx, test, &runner.HookStep{ID: "make", Name: "make test", Stage: "main"})
+
+	out := plain(d.render(60, 20, time.Now()))
+	assert.Contains(t, out, "Stage 1\n  ✓ CI/build")
+	assert.Contains(t, out, "Stage 2\n")
+	// the steps of the running job are shown, the steps of the succeeded job are not
+	assert.NotContains(t, out, "actions/checkout@v4")
+	assert.Regexp(t, `  [⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏] CI/test-1 +0s\n      [⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏] make test +0s\n`, out)
+	assert.Contains(t, out, "  · CI/deploy\n")
+	// the running job started last is selected
+	assert.Contains(t, out, "── CI/test-1 ─")
+	assert.Len(t, d.render(60, 20, time.Now()), 20)
+
+	// the steps of the selected job are shown
+	d.key("up")
+	out = plain(d.render(60, 20, time.Now()))
+	assert.Contains(t, out, "      ✓ actions/checkout@v4")
+	assert.Contains(t, out, "      - Post actions/checkout@v4")
+	assert.Contains(t, out, "── CI/build ─")
+
+	d.OnJobEnd(ctx, test, "failure")
+	d.OnPlanEnd(ctx, nil, assert.AnError)
+	out = plain(d.summary(80, time.Now()))
+	assert.Contains(t, out, "failed: 0 running, 1 succeeded, 1 failed, 0 skipped")
+	assert.Contains(t, out, "  ✗ CI/test-1")
+	assert.Contains(t, out, "make test")
+	assert.NotContains(t, out, "checkout")
+}
+
+func TestDashboardLog(t *testing.T) {
+	d, build, _ := newTestDashboard()
+	require.NoError(t, d.OnJobStart(context.Background(), build))
+
+	config := &runner.Config{Secrets: map[string]string{"TOKEN": "s3cr3t"}}
+	ctx := runner.WithJobLoggerFactory(context.Background(), d)
+	ctx = runner.WithJobLogger(ctx, "build", "CI/build  ", config, &[]string{}, nil)
+	logger := common.Logger(ctx)
+	logger.Infof("⭐ Run Main make")
+	logger.WithField("raw_output", true).Infof("token s3cr3t\r\n")
+	logger.WithField("raw_output", true).Infof("\x1b[32mdownloading 10%%\rdownloading 100%%\x1b[0m")
+
+	assert.Equal(t, []string{"⭐ Run Main make", "  | token ***", "  | downloading 100%"}, d.jobs["CI/build"].log)
+
+	// the open log fills the screen and is scrolled from its end
+	d.key("enter")
+	lines := d.render(40, 4, time.Now())
+	assert.Equal(t, "── CI/build "+strings.Repeat("─", 28), plain(lines[1:2]))
+	assert.Equal(t, "  | downloading 100%", plain(lines[2:3]))
+	d.key("up")
+	lines = d.render(40, 4, time.Now())
+	assert.Equal(t, "  | token ***", plain(lines[2:3]))
+	d.key("esc")
+	assert.False(t, d.logOpen)
+}
+
+func TestDashboardKeys(t *testing.T) {
+	ctx := context.Background()
+	d, build, test := newTestDashboard()
+	require.NoError(t, d.OnJobStart(ctx, build))
+	require.NoError(t, d.OnJobStart(ctx, test))
+	assert.Equal(t, "CI/test-1", d.selected)
+
+	d.key("k")
+	assert.Equal(t, "CI/build", d.selected)
+	d.key("up")
+	assert.Equal(t, "CI/build", d.selected)
+	// the selected job stays selected when a job starts
+	require.NoError(t, d.OnJobStart(ctx, &runner.HookJob{ID: "deploy", Name: "CI/deploy", Workflow: "CI"}))
+	assert.Equal(t, "CI/build", d.selected)
+
+	d.key("c")
+	assert.True(t, d.jobs["CI/build"].cancelled)
+	assert.Equal(t, "Cancelling CI/build", d.message)
+	assert.Contains(t, plain(d.render(80, 20, time.Now())), "CI/build (cancelling)")
+	// a finished job can't be cancelled
+	d.OnJobEnd(ctx, test, "success")
+	d.key("down")
+	d.key("c")
+	assert.False(t, d.jobs["CI/test-1"].cancelled)
+
+	cancelled, forced := 0, 0
+	d.cancel = func() { cancelled++ }
+	d.forceCancel = func() { forced++ }
+	d.key("q")
+	assert.Equal(t, 1, cancelled)
+	assert.Equal(t, 0, forced)
+	d.key("ctrl+c")
+	assert.Equal(t, 1, cancelled)
+	assert.Equal(t, 1, forced)
+}
+
+func TestParseKeys(t *testing.T) {
+	assert.Equal(t, []string{"up", "down", "pgup", "pgdown", "end", "enter", "esc", "ctrl+c", "q"},
+		parseKeys([]byte("\x1b[A\x1bOB\x1b[5~\x1b[6~\x1b[F\r\x1b\x03q")))
+	// the unknown sequences are ignored
+	assert.Equal(t, []string{"c"}, parseKeys([]byte("\x1b[1;5Cc")))
+}
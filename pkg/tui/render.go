@@ -0,0 +1,200 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	red    = 31
+	green  = 32
+	yellow = 33
+	cyan   = 36
+	gray   = 90
+)
+
+var spinner = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+func colored(color int, s string) string {
+	return fmt.Sprintf("\x1b[%dm%s\x1b[0m", color, s)
+}
+
+func icon(status string, now time.Time) string {
+	switch status {
+	case statusRunning:
+		return colored(cyan, spinner[now.UnixMilli()/100%int64(len(spinner))])
+	case "success":
+		return colored(green, "✓")
+	case "failure":
+		return colored(red, "✗")
+	case "cancelled":
+		return colored(yellow, "⊘")
+	case "skipped":
+		return colored(gray, "-")
+	}
+	return colored(gray, "·")
+}
+
+func duration(started time.Time, finished time.Time, now time.Time) string {
+	if started.IsZero() {
+		return ""
+	}
+	if finished.IsZero() {
+		finished = now
+	}
+	return finished.Sub(started).Round(time.Second).String()
+}
+
+// row returns a line of the tree with the label fitted between the icon and the right-aligned text
+func row(indent int, icon string, label string, right string, width int, selected bool) string {
+	available := width - indent - 2 - runewidth.StringWidth(right)
+	if right != "" {
+		available--
+	}
+	label = runewidth.Truncate(label, max(available, 1), "…")
+	if right != "" || selected {
+		label = runewidth.FillRight(label, max(available, 1))
+	}
+	if selected {
+		label = "\x1b[7m" + label + "\x1b[0m"
+	}
+	line := strings.Repeat(" ", indent) + icon + " " + label
+	if right != "" {
+		line += " " + colored(gray, right)
+	}
+	return line
+}
+
+// treeLine is a line of the tree, job is the name of the job of the line of a job
+type treeLine struct {
+	text string
+	job  string
+}
+
+// tree returns the lines of the tree of the stages, jobs and steps. The steps are shown for the running
+// and failed jobs, and for the selected job.
+func (d *Dashboard) tree(width int, now time.Time, selected string) []treeLine {
+	var lines []treeLine
+	for i, runs := range d.stages {
+		lines = append(lines, treeLine{text: fmt.Sprintf("\x1b[1mStage %d\x1b[0m", i+1)})
+		for _, r := range runs {
+			if len(r.jobs) == 0 {
+				lines = append(lines, treeLine{text: row(2, icon(statusQueued, now), fmt.Sprintf("%s/%s", r.workflow, r.jobID), "", width, false)})
+			}
+			for _, j := range r.jobs {
+				label := j.name
+				if j.cancelled && j.status == statusRunning {
+					label += " (cancelling)"
+				} else if j.cancelled {
+					label += " (cancelled)"
+				}
+				lines = append(lines, treeLine{text: row(2, icon(j.status, now), label, duration(j.started, j.finished, now), width, j.name == selected), job: j.name})
+				if j.status != statusRunning && j.status != "failure" && j.name != selected {
+					continue
+				}
+				for _, s := range j.steps {
+					lines = append(lines, treeLine{text: row(6, icon(s.status, now), s.name, duration(s.started, s.finished, now), width, false)})
+				}
+			}
+		}
+	}
+	return lines
+}
+
+// header returns the first line of the dashboard, with the time and the state of the run
+func (d *Dashboard) header(width int, now time.Time) string {
+	end := now
+	if !d.ended.IsZero() {
+		end = d.ended
+	}
+	counts := map[string]int{}
+	for _, j := range d.jobs {
+		if j.hook != nil {
+			counts[j.status]++
+		}
+	}
+	state := fmt.Sprintf("%d running, %d succeeded, %d failed, %d skipped", counts[statusRunning], counts["success"], counts["failure"], counts["skipped"])
+	if !d.ended.IsZero() && d.err != nil {
+		state = "failed: " + state
+	} else if !d.ended.IsZero() {
+		state = "done: " + state
+	}
+	return row(0, "\x1b[1mgha\x1b[0m", d.title, fmt.Sprintf("%s  %s", state, end.Sub(d.started).Round(time.Second)), width, false)
+}
+
+// logLines returns the last lines of the log of the job that fit in height lines, scrolled up by the scroll
+func (d *Dashboard) logLines(job *jobNode, width int, height int) []string {
+	if height <= 0 {
+		return nil
+	}
+	title := "── no job selected "
+	var log []string
+	if job != nil {
+		title = fmt.Sprintf("── %s ", job.name)
+		log = job.log
+	}
+	title = runewidth.Truncate(title, width, "…")
+	lines := []string{colored(gray, title+strings.Repeat("─", max(width-runewidth.StringWidth(title), 0)))}
+	height--
+	d.scroll = min(d.scroll, max(len(log)-height, 0))
+	end := len(log) - d.scroll
+	for _, line := range log[max(end-height, 0):end] {
+		lines = append(lines, runewidth.Truncate(strings.ReplaceAll(line, "\t", "    "), width, ""))
+	}
+	return lines
+}
+
+// render returns the lines of the dashboard that fit the terminal of width by height
+func (d *Dashboard) render(width int, height int, now time.Time) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	lines := []string{d.header(width, now)}
+	help := "↑/↓ select · enter log · c cancel job · q quit"
+	if d.logOpen {
+		help = "↑/↓ scroll · esc back · c cancel job · q quit"
+	}
+	footer := help
+	if d.message != "" {
+		footer = help + " · " + d.message
+	}
+	footer = colored(gray, runewidth.Truncate(footer, width, "…"))
+	body := max(height-2, 2)
+
+	if d.logOpen {
+		lines = append(lines, d.logLines(d.jobs[d.selected], width, body)...)
+		return append(lines, footer)
+	}
+
+	tree := d.tree(width, now, d.selected)
+	treeHeight := min(len(tree), body-body/3)
+	// the tree is scrolled to show the selected job
+	offset := 0
+	for i, line := range tree {
+		if line.job != "" && line.job == d.selected && i >= treeHeight {
+			offset = i - treeHeight + 1
+		}
+	}
+	for _, line := range tree[offset : offset+treeHeight] {
+		lines = append(lines, line.text)
+	}
+	lines = append(lines, d.logLines(d.jobs[d.selected], width, body-treeHeight)...)
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+	return append(lines, footer)
+}
+
+// summary returns the tree of the run, that is printed when the dashboard stops
+func (d *Dashboard) summary(width int, now time.Time) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	lines := []string{d.header(width, now)}
+	for _, line := range d.tree(width, now, "") {
+		lines = append(lines, line.text)
+	}
+	return lines
+}
@@ -0,0 +1,156 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/term"
+)
+
+// IsTerminal returns whether the dashboard can be shown, the keys are read from in and it is drawn to out
+func IsTerminal(in *os.File, out *os.File) bool {
+	isTerminal := func(f *os.File) bool {
+		return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+	}
+	return isTerminal(in) && isTerminal(out)
+}
+
+// Start draws the dashboard until Stop is called. The first q or Ctrl+C calls cancel to cancel the run,
+// the second calls forceCancel. The lines of the standard logger are shown at the bottom of the dashboard.
+func (d *Dashboard) Start(cancel func(), forceCancel func()) error {
+	state, err := term.MakeRaw(int(d.in.Fd()))
+	if err != nil {
+		return fmt.Errorf("unable to read the keys of the terminal: %w", err)
+	}
+	d.state = state
+	d.cancel = cancel
+	d.forceCancel = forceCancel
+
+	// the standard logger would draw over the dashboard
+	std := log.StandardLogger()
+	hooks := log.LevelHooks{}
+	for level, levelHooks := range std.Hooks {
+		hooks[level] = slices.Clone(levelHooks)
+	}
+	hooks.Add(d)
+	d.logOut = std.Out
+	d.logHooks = std.ReplaceHooks(hooks)
+	std.SetOutput(io.Discard)
+
+	// the alternate screen keeps the scrollback of the terminal, the cursor is hidden
+	_, _ = io.WriteString(d.out, "\x1b[?1049h\x1b[?25l")
+	d.stop = make(chan struct{})
+	d.stopped = make(chan struct{})
+	go d.draw()
+	go d.readKeys()
+	return nil
+}
+
+// Stop stops drawing the dashboard, restores the terminal and prints the tree of the run
+func (d *Dashboard) Stop() {
+	if d.stop == nil {
+		return
+	}
+	close(d.stop)
+	<-d.stopped
+	_, _ = io.WriteString(d.out, "\x1b[?25h\x1b[?1049l")
+	_ = term.Restore(int(d.in.Fd()), d.state)
+
+	std := log.StandardLogger()
+	std.SetOutput(d.logOut)
+	std.ReplaceHooks(d.logHooks)
+
+	width, _ := d.size()
+	_, _ = io.WriteString(d.out, strings.Join(d.summary(width, time.Now()), "\n")+"\n")
+}
+
+func (d *Dashboard) size() (int, int) {
+	width, height, err := term.GetSize(int(d.out.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// draw redraws the dashboard ten times a second, for the spinners and the durations
+func (d *Dashboard) draw() {
+	defer close(d.stopped)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		width, height := d.size()
+		// the raw terminal doesn't return the carriage on a new line
+		frame := "\x1b[H" + strings.Join(d.render(width, height, time.Now()), "\x1b[K\r\n") + "\x1b[K\x1b[J"
+		_, _ = io.WriteString(d.out, frame)
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// readKeys handles the keys pressed by the user. The read of the terminal can't be interrupted, the
+// goroutine returns on the first key pressed after the dashboard stopped.
+func (d *Dashboard) readKeys() {
+	buf := make([]byte, 64)
+	for {
+		n, err := d.in.Read(buf)
+		if err != nil {
+			return
+		}
+		select {
+		case <-d.stop:
+			return
+		default:
+		}
+		for _, key := range parseKeys(buf[:n]) {
+			d.key(key)
+		}
+	}
+}
+
+// sequences are the names of the keys of the escape sequences, without the leading ESC [ or ESC O
+var sequences = map[string]string{
+	"A":  "up",
+	"B":  "down",
+	"F":  "end",
+	"4~": "end",
+	"5~": "pgup",
+	"6~": "pgdown",
+}
+
+// parseKeys returns the names of the keys read from the raw terminal
+func parseKeys(b []byte) []string {
+	var keys []string
+	for len(b) > 0 {
+		switch {
+		case b[0] == 0x1b && len(b) > 2 && (b[1] == '[' || b[1] == 'O'):
+			i := 2
+			for i < len(b)-1 && (b[i] < 0x40 || b[i] > 0x7e) {
+				i++
+			}
+			if key, ok := sequences[string(b[2:i+1])]; ok {
+				keys = append(keys, key)
+			}
+			b = b[i+1:]
+			continue
+		case b[0] == 0x1b:
+			keys = append(keys, "esc")
+		case b[0] == 0x03:
+			keys = append(keys, "ctrl+c")
+		case b[0] == '\r' || b[0] == '\n':
+			keys = append(keys, "enter")
+		default:
+			keys = append(keys, string(b[0]))
+		}
+		b = b[1:]
+	}
+	return keys
+}